@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/compress"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/throttle"
+	"p2pfs/internal/transfer"
+)
+
+// runCLI ejecuta un subcomando y devuelve el código de salida
+func runCLI(command string, args []string) int {
+	switch command {
+	case "audit":
+		return runAudit(args)
+	case "bandwidth":
+		return runBandwidth(args)
+	case "transfers":
+		return runTransfers(args)
+	case "files":
+		return runFiles(args)
+	default:
+		fmt.Println("Uso: p2pfs [audit|bandwidth|transfers|files] [opciones]")
+		fmt.Println("Sin subcomando se inicia el nodo con la interfaz gráfica.")
+		return 2
+	}
+}
+
+// runAudit consulta el historial: p2pfs audit -peer 3 -path docs/ -action DELETE -since 2024-05-01
+func runAudit(args []string) int {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	peerID := flags.Int("peer", 0, "ID del nodo origen o destino")
+	path := flags.String("path", "", "archivo o carpeta dentro de shared")
+	action := flags.String("action", "", "CREATE, TRANSFER, DELETE, RELAY, RENAME, MKDIR o COPY")
+	since := flags.String("since", "", "fecha inicial (2006-01-02 o RFC3339)")
+	until := flags.String("until", "", "fecha final (2006-01-02 o RFC3339)")
+	all := flags.Bool("all", false, "incluir el historial de los peers en línea")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	filter := audit.Filter{PeerID: *peerID, PathPrefix: *path, Action: *action}
+	var err error
+	if filter.Since, err = parseCLITime(*since, false); err != nil {
+		fmt.Println("❌ -since inválido:", err)
+		return 2
+	}
+	if filter.Until, err = parseCLITime(*until, true); err != nil {
+		fmt.Println("❌ -until inválido:", err)
+		return 2
+	}
+
+	var lines []string
+	if *all {
+		p, err := peer.LoadPeers(filepath.Join("config", "peers.json"))
+		if err != nil || p == nil {
+			fmt.Println("❌ No se pudo cargar la configuración de peers")
+			return 1
+		}
+		for _, e := range audit.QueryAll(p.Peers, p.Local.ID, filter) {
+			lines = append(lines, audit.Format(e))
+		}
+	} else {
+		local, err := audit.Query(filter)
+		if err != nil {
+			fmt.Println("❌", err)
+			return 1
+		}
+		for _, e := range local {
+			lines = append(lines, audit.Format(e))
+		}
+	}
+
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Printf("%d entrada(s)\n", len(lines))
+	return 0
+}
+
+// runBandwidth muestra o cambia los límites de ancho de banda del nodo local:
+// p2pfs bandwidth -upload 512 -peer 2 -windows pending=20:00-07:00
+func runBandwidth(args []string) int {
+	flags := flag.NewFlagSet("bandwidth", flag.ContinueOnError)
+	upload := flags.Int("upload", -1, "límite de subida en KB/s (0 = sin límite)")
+	download := flags.Int("download", -1, "límite de bajada en KB/s (0 = sin límite)")
+	peerID := flags.Int("peer", 0, "aplicar -upload/-download solo a este nodo")
+	windows := flags.String("windows", "", "ventanas horarias, ej. pending=20:00-07:00,bulk=22:00-06:00")
+	clearWindows := flags.Bool("clear-windows", false, "quitar todas las ventanas horarias")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	resp, err := localControl(map[string]interface{}{"type": "GET_BANDWIDTH"})
+	running := err == nil
+	var cfg throttle.Config
+	if running {
+		raw, _ := json.Marshal(resp["config"])
+		_ = json.Unmarshal(raw, &cfg)
+	} else if cfg, err = throttle.Load(); err != nil {
+		fmt.Println("❌", err)
+		return 1
+	}
+
+	changed := false
+	if *upload >= 0 || *download >= 0 {
+		limit := cfg.Limit
+		if *peerID != 0 {
+			if cfg.Peers == nil {
+				cfg.Peers = make(map[int]throttle.Limit)
+			}
+			limit = cfg.Peers[*peerID]
+		}
+		if *upload >= 0 {
+			limit.UploadKBps = *upload
+		}
+		if *download >= 0 {
+			limit.DownloadKBps = *download
+		}
+		if *peerID != 0 {
+			cfg.Peers[*peerID] = limit
+		} else {
+			cfg.Limit = limit
+		}
+		changed = true
+	}
+	if *clearWindows {
+		cfg.Windows = nil
+		changed = true
+	}
+	if *windows != "" {
+		parsed, err := throttle.ParseWindows(*windows)
+		if err != nil {
+			fmt.Println("❌", err)
+			return 2
+		}
+		cfg.Windows = parsed
+		changed = true
+	}
+
+	if changed {
+		if running {
+			resp, err = localControl(map[string]interface{}{"type": "SET_BANDWIDTH", "config": cfg})
+			if err == nil {
+				raw, _ := json.Marshal(resp["config"])
+				_ = json.Unmarshal(raw, &cfg)
+			}
+		} else {
+			err = throttle.Apply(cfg)
+			fmt.Println("ℹ️ El nodo no está en ejecución: se actualizó la configuración para el próximo inicio")
+		}
+		if err != nil {
+			fmt.Println("❌", err)
+			return 1
+		}
+	}
+	fmt.Println(cfg.Format())
+	if running {
+		var stats compress.Stats
+		raw, _ := json.Marshal(resp["compression"])
+		if json.Unmarshal(raw, &stats) == nil {
+			fmt.Println("Compresión:", stats)
+		}
+	}
+	return 0
+}
+
+// localControl envía un mensaje de control al nodo que corre en esta máquina
+func localControl(req map[string]interface{}) (map[string]interface{}, error) {
+	conn, err := dialLocalControl()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var resp map[string]interface{}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp["type"] == "ERROR" {
+		return nil, fmt.Errorf("%v", resp["error"])
+	}
+	return resp, nil
+}
+
+// runTransfers muestra las transferencias del nodo en ejecución; con -watch
+// sigue mostrando cada cambio hasta que se interrumpa
+func runTransfers(args []string) int {
+	flags := flag.NewFlagSet("transfers", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "seguir mostrando el avance")
+	active := flags.Bool("active", false, "ocultar las transferencias terminadas")
+	pause := flags.Int64("pause", 0, "pausar la transferencia con este ID")
+	resume := flags.Int64("resume", 0, "reanudar la transferencia con este ID")
+	cancel := flags.Int64("cancel", 0, "cancelar la transferencia con este ID")
+	retry := flags.Int64("retry", 0, "reintentar la transferencia con este ID")
+	clear := flags.Bool("clear", false, "quitar de la lista las transferencias terminadas")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Las acciones se revisan en orden fijo y solo se admite una por llamada
+	actions := []struct {
+		name string
+		id   int64
+	}{{"pause", *pause}, {"resume", *resume}, {"cancel", *cancel}, {"retry", *retry}}
+	req := map[string]interface{}{"type": "GET_TRANSFERS"}
+	chosen := 0
+	for _, a := range actions {
+		if a.id != 0 {
+			req = map[string]interface{}{"type": "TRANSFER_ACTION", "action": a.name, "id": a.id}
+			chosen++
+		}
+	}
+	if *clear {
+		req = map[string]interface{}{"type": "TRANSFER_ACTION", "action": "clear"}
+		chosen++
+	}
+	if *watch {
+		req = map[string]interface{}{"type": "WATCH_TRANSFERS"}
+		chosen++
+	}
+	if chosen > 1 {
+		fmt.Println("❌ Usa solo una de -pause, -resume, -cancel, -retry, -clear o -watch")
+		return 2
+	}
+
+	conn, err := dialLocalControl()
+	if err != nil {
+		fmt.Println("❌ El nodo no está en ejecución:", err)
+		return 1
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		fmt.Println("❌", err)
+		return 1
+	}
+
+	dec := json.NewDecoder(conn)
+	var list struct {
+		Type      string              `json:"type"`
+		Transfers []transfer.Transfer `json:"transfers"`
+		Error     string              `json:"error"`
+	}
+	if err := dec.Decode(&list); err != nil {
+		fmt.Println("❌", err)
+		return 1
+	}
+	if list.Type != "TRANSFERS" {
+		fmt.Println("❌", list.Error)
+		return 1
+	}
+	shown := 0
+	for _, t := range list.Transfers {
+		if *active && t.Finished() {
+			continue
+		}
+		fmt.Println(t)
+		shown++
+	}
+	if shown == 0 && !*watch {
+		fmt.Println("No hay transferencias")
+	}
+
+	for *watch {
+		var event struct {
+			Transfer transfer.Transfer `json:"transfer"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			fmt.Println("🔌 Conexión con el nodo cerrada:", err)
+			return 1
+		}
+		if event.Transfer.Removed || (*active && event.Transfer.Finished()) {
+			continue
+		}
+		fmt.Println(event.Transfer)
+	}
+	return 0
+}
+
+// runFiles aplica operaciones sobre shared de cualquier peer a través del nodo en
+// ejecución: p2pfs files -peer 2 -mkdir docs/nueva, -copy a.txt -to b.txt o
+// -rename a.txt -to docs/a.txt
+func runFiles(args []string) int {
+	flags := flag.NewFlagSet("files", flag.ContinueOnError)
+	peerID := flags.Int("peer", 0, "nodo sobre el que se opera (0 = este nodo)")
+	mkdir := flags.String("mkdir", "", "crear esta carpeta")
+	copyFrom := flags.String("copy", "", "duplicar este archivo o carpeta (requiere -to)")
+	rename := flags.String("rename", "", "renombrar o mover este archivo o carpeta (requiere -to)")
+	to := flags.String("to", "", "ruta de destino para -copy y -rename")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	req := map[string]interface{}{"type": "FILE_OP", "peer": *peerID}
+	switch {
+	case *mkdir != "":
+		req["op"], req["name"] = "mkdir", *mkdir
+	case *copyFrom != "" && *to != "":
+		req["op"], req["name"], req["newName"] = "copy", *copyFrom, *to
+	case *rename != "" && *to != "":
+		req["op"], req["name"], req["newName"] = "rename", *rename, *to
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	resp, err := localControl(req)
+	if err != nil {
+		fmt.Println("❌", err)
+		return 1
+	}
+	switch resp["status"] {
+	case "ok":
+		fmt.Println("✅ Operación completada")
+	case "pending":
+		fmt.Println("⏳", resp["error"])
+	default:
+		fmt.Println("❌", resp["error"])
+		return 1
+	}
+	return 0
+}
+
+// dialLocalControl se conecta al nodo que corre en esta máquina
+func dialLocalControl() (net.Conn, error) {
+	p, err := peer.LoadPeers(filepath.Join("config", "peers.json"))
+	if err != nil || p == nil {
+		return nil, fmt.Errorf("no se pudo cargar la configuración de peers")
+	}
+	return net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", p.Local.Port), 2*time.Second)
+}
+
+// parseCLITime acepta fechas simples o RFC3339; endOfDay extiende una fecha simple hasta las 23:59:59
+func parseCLITime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
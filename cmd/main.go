@@ -1,16 +1,51 @@
 package main
 
 import (
-	"p2pfs/internal/peer"
+	"fmt"
+	"os"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/fs"
 	"p2pfs/internal/gui"
+	"p2pfs/internal/index"
+	"p2pfs/internal/log"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+	"p2pfs/internal/throttle"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1], os.Args[2:]))
+	}
+
 	peerSystem := peer.InitPeer()
 	if peerSystem == nil {
 		return
 	}
 
+	audit.Init()
+	fs.Init()
+	log.Init()
+	if _, err := throttle.Load(); err != nil {
+		fmt.Println("⚠️ Configuración de ancho de banda inválida, se transfiere sin límites:", err)
+	}
+	index.Local.StartAutosave(30 * time.Second)
+	chunk.Local.StartAutosave(30 * time.Second)
+	state.StartVersionAutosave(5 * time.Second)
 	go peer.StartServer(peerSystem.Local.Port)
+	log.StartCompaction(peerSystem, 30*time.Second)
+	if _, err := fs.StartWatcher(500*time.Millisecond, time.Minute); err != nil {
+		fmt.Println("⚠️", err, "— se usará el escaneo periódico")
+	}
+	fs.StartFolderSync(peerSystem)
 	gui.Run(peerSystem)
+	if err := state.SaveVersions(); err != nil {
+		fmt.Println("⚠️ No se pudieron guardar los vectores de versión:", err)
+	}
+	if err := log.Flush(); err != nil {
+		fmt.Println("⚠️ No se pudo guardar el log de operaciones:", err)
+	}
 }
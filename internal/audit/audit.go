@@ -0,0 +1,330 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"p2pfs/internal/log"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// auditFile guarda una entrada JSON por línea. A diferencia del log de
+// operaciones, nunca se compacta ni se reenvía para ser aplicado.
+var auditFile = filepath.Join("data", "audit.jsonl")
+
+var mutex sync.Mutex
+
+// Filter describe una consulta sobre el historial. Los campos vacíos no filtran.
+type Filter struct {
+	PeerID     int    // Coincide con el origen o el destino
+	PathPrefix string // Carpeta o archivo, relativo a shared: incluye todo lo que hay dentro
+	Action     string // "CREATE", "DELETE", "TRANSFER", "RELAY", "RENAME", "MKDIR", "COPY"
+	Since      time.Time
+	Until      time.Time
+}
+
+// underPath indica si p es prefix o está dentro de la carpeta prefix
+func underPath(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// Match indica si una entrada cumple el filtro
+func (f Filter) Match(e log.LogEntry) bool {
+	if f.PeerID != 0 && e.OriginID != f.PeerID && e.TargetID != f.PeerID {
+		return false
+	}
+	if prefix := strings.Trim(f.PathPrefix, "/"); prefix != "" &&
+		!underPath(e.FileName, prefix) && (e.NewName == "" || !underPath(e.NewName, prefix)) {
+		return false
+	}
+	if f.Action != "" && !strings.EqualFold(e.Action, f.Action) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Init conecta el historial con el servidor: registra las operaciones aplicadas
+// por pedido de otros nodos y responde consultas GET_AUDIT
+func Init() {
+	peer.AuditFunc = func(action, name string, originID int, size int64, outcome string) {
+		Record(log.LogEntry{
+			Action:   action,
+			FileName: name,
+			OriginID: originID,
+			TargetID: peer.Local.ID,
+			Size:     size,
+			Outcome:  outcome,
+		})
+	}
+	peer.AuditPathsFunc = func(action, name, newName string, originID int, outcome string) {
+		Record(log.LogEntry{
+			Action:   action,
+			FileName: name,
+			NewName:  newName,
+			OriginID: originID,
+			TargetID: peer.Local.ID,
+			Outcome:  outcome,
+		})
+	}
+	peer.RegisterHandler("GET_AUDIT", handleGetAudit)
+}
+
+// Record agrega una entrada al historial
+func Record(e log.LogEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(auditFile), 0755); err != nil {
+		fmt.Println("❌ No se pudo crear la carpeta del historial:", err)
+		return
+	}
+	f, err := os.OpenFile(auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Println("❌ No se pudo abrir el historial:", err)
+		return
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		fmt.Println("❌ No se pudo registrar en el historial:", err)
+	}
+}
+
+// recordOperation registra una operación hecha por este nodo en el historial y,
+// si se completó o quedó pendiente, en el log de operaciones que se intercambia
+// con los peers. Los errores solo quedan en el historial.
+func recordOperation(e log.LogEntry) {
+	e.Time = time.Now()
+	Record(e)
+	if e.Outcome != "ok" && e.Outcome != "pending" && e.Outcome != OutcomeUnconfirmed {
+		return
+	}
+	if err := log.AppendLog(e); err != nil {
+		fmt.Println("⚠️ No se pudo agregar al log de operaciones:", err)
+	}
+}
+
+// RecordOp registra una operación con el resultado derivado de err
+func RecordOp(action, name string, originID, targetID int, size int64, hash string, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error: " + err.Error()
+	}
+	recordOperation(log.LogEntry{
+		Action:   action,
+		FileName: name,
+		OriginID: originID,
+		TargetID: targetID,
+		Size:     size,
+		Hash:     hash,
+		Outcome:  outcome,
+	})
+}
+
+// RecordRename registra un renombre o movimiento con el resultado derivado de err
+func RecordRename(oldName, newName string, originID, targetID int, err error) {
+	RecordPaths("RENAME", oldName, newName, originID, targetID, err)
+}
+
+// RecordPaths registra una operación con ruta de origen y destino ("RENAME", "COPY")
+func RecordPaths(action, oldName, newName string, originID, targetID int, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error: " + err.Error()
+	}
+	recordOperation(log.LogEntry{
+		Action:   action,
+		FileName: oldName,
+		NewName:  newName,
+		OriginID: originID,
+		TargetID: targetID,
+		Outcome:  outcome,
+	})
+}
+
+// OutcomeUnconfirmed es el resultado de un envío que el receptor no confirmó
+// (nodos anteriores al acuse de SEND_FILE)
+const OutcomeUnconfirmed = "sent (unconfirmed)"
+
+// RecordUnconfirmed registra un envío completo del que no hubo confirmación
+func RecordUnconfirmed(action, name string, originID, targetID int, size int64, hash string) {
+	recordOperation(log.LogEntry{
+		Action:   action,
+		FileName: name,
+		OriginID: originID,
+		TargetID: targetID,
+		Size:     size,
+		Hash:     hash,
+		Outcome:  OutcomeUnconfirmed,
+	})
+}
+
+// RecordPendingPaths registra una operación con ruta de origen y destino que
+// quedó diferida hasta la reconexión
+func RecordPendingPaths(action, oldName, newName string, originID, targetID int) {
+	recordOperation(log.LogEntry{
+		Action:   action,
+		FileName: oldName,
+		NewName:  newName,
+		OriginID: originID,
+		TargetID: targetID,
+		Outcome:  "pending",
+	})
+}
+
+// RecordPending registra una operación que quedó diferida hasta la reconexión
+func RecordPending(action, name string, originID, targetID int) {
+	recordOperation(log.LogEntry{
+		Action:   action,
+		FileName: name,
+		OriginID: originID,
+		TargetID: targetID,
+		Outcome:  "pending",
+	})
+}
+
+// HashBytes calcula el hash SHA-256 usado en el historial
+func HashBytes(data []byte) string {
+	return state.HashBytes(data)
+}
+
+// Query devuelve las entradas locales que cumplen el filtro, ordenadas por fecha
+func Query(f Filter) ([]log.LogEntry, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	file, err := os.Open(auditFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el historial: %w", err)
+	}
+	defer file.Close()
+
+	var result []log.LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e log.LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.Match(e) {
+			result = append(result, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("error leyendo el historial: %w", err)
+	}
+	return result, nil
+}
+
+// QueryAll consulta el historial local y el de cada peer en línea, y devuelve
+// todas las entradas mezcladas por fecha. Los peers que no responden se omiten.
+func QueryAll(peers []peer.PeerInfo, localID int, f Filter) []log.LogEntry {
+	all, err := Query(f)
+	if err != nil {
+		fmt.Println("⚠️", err)
+	}
+	for _, p := range peers {
+		if p.ID == localID {
+			continue
+		}
+		remote, err := queryRemote(p, f)
+		if err != nil {
+			fmt.Printf("⚠️ No se pudo consultar el historial de Maq%d: %v\n", p.ID, err)
+			continue
+		}
+		all = append(all, remote...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+	return all
+}
+
+func queryRemote(p peer.PeerInfo, f Filter) ([]log.LogEntry, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := map[string]interface{}{
+		"type":   "GET_AUDIT",
+		"filter": f,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var resp struct {
+		Type    string         `json:"type"`
+		Entries []log.LogEntry `json:"entries"`
+		Error   string         `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Type != "AUDIT_LIST" {
+		return nil, fmt.Errorf("respuesta inesperada: %v %s", resp.Type, resp.Error)
+	}
+	return resp.Entries, nil
+}
+
+func handleGetAudit(conn net.Conn, request map[string]interface{}) {
+	var f Filter
+	raw, _ := json.Marshal(request["filter"])
+	_ = json.Unmarshal(raw, &f)
+
+	entries, err := Query(f)
+	resp := map[string]interface{}{
+		"type":    "AUDIT_LIST",
+		"entries": entries,
+	}
+	if err != nil {
+		resp = map[string]interface{}{
+			"type":  "ERROR",
+			"error": err.Error(),
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// Format devuelve una línea legible para la CLI y la GUI
+func Format(e log.LogEntry) string {
+	line := fmt.Sprintf("%s  %-8s Maq%d → Maq%d  %s", e.Time.Format("2006-01-02 15:04:05"), e.Action, e.OriginID, e.TargetID, e.FileName)
+	if e.NewName != "" {
+		line += " → " + e.NewName
+	}
+	if e.Size > 0 {
+		line += fmt.Sprintf("  %d B", e.Size)
+	}
+	if e.Hash != "" && len(e.Hash) >= 12 {
+		line += "  " + e.Hash[:12]
+	}
+	if e.Outcome != "" {
+		line += "  [" + e.Outcome + "]"
+	}
+	return line
+}
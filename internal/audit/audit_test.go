@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"testing"
+
+	"p2pfs/internal/log"
+)
+
+func TestFilterPathPrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		file    string
+		newName string
+		want    bool
+	}{
+		{"", "cualquiera.txt", "", true},
+		{"docs", "docs", "", true},
+		{"docs", "docs/a.txt", "", true},
+		{"docs/", "docs/sub/a.txt", "", true},
+		{"/docs", "docs/a.txt", "", true},
+		{"docs", "docsx/a.txt", "", false},
+		{"docs", "docs.txt", "", false},
+		{"docs/a.txt", "docs/a.txt", "", true},
+		{"docs", "a.txt", "docs/a.txt", true},
+		{"docs", "a.txt", "docsx/a.txt", false},
+	}
+	for _, tt := range tests {
+		f := Filter{PathPrefix: tt.prefix}
+		if got := f.Match(log.LogEntry{FileName: tt.file, NewName: tt.newName}); got != tt.want {
+			t.Errorf("PathPrefix %q con %q → %q: %v, quería %v", tt.prefix, tt.file, tt.newName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Los archivos se cortan en fragmentos según su contenido (gear hash, como
+// FastCDC): insertar o borrar bytes solo cambia los fragmentos cercanos, así
+// el mismo contenido produce los mismos fragmentos aunque esté en otro archivo
+// o desplazado.
+
+const (
+	MinSize = 16 * 1024
+	AvgSize = 64 * 1024
+	MaxSize = 256 * 1024
+
+	// cutMask tiene tantos bits como log2(AvgSize)
+	cutMask = AvgSize - 1
+)
+
+// Chunk identifica un fragmento por el hash de su contenido
+type Chunk struct {
+	Hash   string `json:"hash"`
+	Offset int64  `json:"offset"`
+	Size   int    `json:"size"`
+}
+
+var gear = func() [256]uint64 {
+	// Tabla fija: todos los nodos deben cortar en los mismos puntos
+	var t [256]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range t {
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// Split corta data en fragmentos definidos por su contenido
+func Split(data []byte) []Chunk {
+	var chunks []Chunk
+	start := 0
+	for start < len(data) {
+		end := cutPoint(data[start:]) + start
+		chunks = append(chunks, Chunk{
+			Hash:   Hash(data[start:end]),
+			Offset: int64(start),
+			Size:   end - start,
+		})
+		start = end
+	}
+	return chunks
+}
+
+// cutPoint devuelve la longitud del primer fragmento de data
+func cutPoint(data []byte) int {
+	n := len(data)
+	if n <= MinSize {
+		return n
+	}
+	if n > MaxSize {
+		n = MaxSize
+	}
+	var fp uint64
+	for i := MinSize; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&cutMask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Hash calcula el identificador de un fragmento
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,174 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestSplitBoundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"vacío", 0},
+		{"menor al mínimo", MinSize - 1},
+		{"exacto al mínimo", MinSize},
+		{"varios fragmentos", 3 * MaxSize},
+		{"grande", 2*1024*1024 + 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := randomData(1, tt.size)
+			chunks := Split(data)
+
+			var offset int64
+			for i, c := range chunks {
+				if c.Offset != offset {
+					t.Fatalf("fragmento %d empieza en %d, quería %d", i, c.Offset, offset)
+				}
+				if c.Size > MaxSize {
+					t.Errorf("fragmento %d mide %d, más que MaxSize", i, c.Size)
+				}
+				if c.Size < MinSize && i != len(chunks)-1 {
+					t.Errorf("fragmento %d mide %d, menos que MinSize", i, c.Size)
+				}
+				if c.Hash != Hash(data[c.Offset:c.Offset+int64(c.Size)]) {
+					t.Errorf("fragmento %d tiene un hash que no corresponde", i)
+				}
+				offset += int64(c.Size)
+			}
+			if offset != int64(tt.size) {
+				t.Errorf("los fragmentos cubren %d bytes de %d", offset, tt.size)
+			}
+		})
+	}
+}
+
+func TestSplitInsertKeepsLaterChunks(t *testing.T) {
+	data := randomData(2, 2*1024*1024)
+	edited := append(append(append([]byte(nil), data[:1000]...), []byte("insertado")...), data[1000:]...)
+
+	before := make(map[string]bool)
+	for _, c := range Split(data) {
+		before[c.Hash] = true
+	}
+	after := Split(edited)
+	shared := 0
+	for _, c := range after {
+		if before[c.Hash] {
+			shared++
+		}
+	}
+	// Solo deberían cambiar los fragmentos cercanos a la inserción
+	if shared < len(after)-2 {
+		t.Errorf("se conservaron %d de %d fragmentos tras insertar bytes", shared, len(after))
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return Open(filepath.Join(dir, "shared"), filepath.Join(dir, "chunks.json"))
+}
+
+func writeShared(t *testing.T, s *Store, rel string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(s.root, rel), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.AddData(rel, data)
+}
+
+func TestAssemble(t *testing.T) {
+	s := newTestStore(t)
+	data := randomData(3, 3*MaxSize)
+	chunks := Split(data)
+	writeShared(t, s, "original.bin", data[:chunks[1].Offset+int64(chunks[1].Size)])
+
+	// Lo que el almacén no tiene llega en provided
+	provided := make(map[string][]byte)
+	for _, c := range chunks[2:] {
+		provided[c.Hash] = data[c.Offset : c.Offset+int64(c.Size)]
+	}
+
+	tests := []struct {
+		name     string
+		provided map[string][]byte
+		expected string
+		wantErr  error
+	}{
+		{"completo", provided, Hash(data), nil},
+		{"sin hash esperado", provided, "", nil},
+		{"falta un fragmento", nil, Hash(data), ErrMissingChunk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "sub", "copia.bin")
+			size, err := s.Assemble(out, chunks, tt.provided, tt.expected)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, quería %v", err, tt.wantErr)
+				}
+				if _, err := os.Stat(out); !os.IsNotExist(err) {
+					t.Errorf("quedó un archivo a medio armar: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(out)
+			if size != int64(len(data)) || !bytes.Equal(got, data) {
+				t.Errorf("archivo reconstruido de %d bytes no coincide con el original", size)
+			}
+		})
+	}
+
+	if _, err := s.Assemble(filepath.Join(t.TempDir(), "x.bin"), chunks, provided, Hash([]byte("otro"))); err == nil {
+		t.Error("se aceptó un archivo que no coincide con el hash esperado")
+	}
+}
+
+func TestStoreSharedChunks(t *testing.T) {
+	s := newTestStore(t)
+	data := randomData(4, MinSize)
+	hash := Hash(data)
+	writeShared(t, s, "a.bin", data)
+	writeShared(t, s, "b.bin", data)
+
+	// Quitar uno de los archivos no debe perder el fragmento que el otro sigue teniendo
+	s.Remove("a.bin")
+	if got, ok := s.Get(hash); !ok || !bytes.Equal(got, data) {
+		t.Fatal("el fragmento compartido se perdió al quitar a.bin")
+	}
+
+	// Un archivo modificado deja de servir el fragmento
+	if err := os.WriteFile(filepath.Join(s.root, "b.bin"), randomData(5, MinSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s.Has(hash) {
+		t.Error("Has confía en un fragmento que ya no está en disco")
+	}
+
+	// Se conserva al guardar y volver a abrir
+	writeShared(t, s, "c.bin", data)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if !Open(s.root, s.file).Has(hash) {
+		t.Error("el fragmento no se recuperó desde disco")
+	}
+}
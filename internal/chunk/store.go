@@ -0,0 +1,289 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Location indica dónde está guardado un fragmento dentro de shared
+type Location struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Size   int    `json:"size"`
+}
+
+// Store es el almacén local de fragmentos, direccionado por contenido. Los
+// fragmentos no se copian: cada hash apunta a los archivos de shared que lo
+// contienen, y se verifica al leerlo por si el archivo cambió desde entonces.
+type Store struct {
+	root   string
+	file   string
+	mutex  sync.Mutex
+	byHash map[string][]Location
+	byPath map[string][]string
+	dirty  bool
+	once   sync.Once // carga el archivo en el primer uso
+}
+
+// Local es el almacén de fragmentos de shared, guardado en data/chunks.json
+var Local = Open("shared", filepath.Join("data", "chunks.json"))
+
+// Open prepara un almacén para la carpeta root guardado en file; el archivo se
+// lee recién en el primer uso
+func Open(root, file string) *Store {
+	return &Store{
+		root:   root,
+		file:   file,
+		byHash: make(map[string][]Location),
+		byPath: make(map[string][]string),
+	}
+}
+
+// load carga el almacén desde disco o lo deja vacío
+func (s *Store) load() {
+	data, err := os.ReadFile(s.file)
+	if err == nil {
+		var stored map[string][]Location
+		if json.Unmarshal(data, &stored) != nil {
+			// Formato anterior: una sola ubicación por fragmento
+			var single map[string]Location
+			if json.Unmarshal(data, &single) == nil {
+				stored = make(map[string][]Location, len(single))
+				for hash, loc := range single {
+					stored[hash] = []Location{loc}
+				}
+			}
+		}
+		for hash, locs := range stored {
+			for _, loc := range locs {
+				s.addLocked(hash, loc)
+			}
+		}
+	}
+}
+
+// lock toma mutex después de asegurar que el almacén está cargado
+func (s *Store) lock() {
+	s.once.Do(s.load)
+	s.mutex.Lock()
+}
+
+// Has indica si el fragmento está disponible localmente. Se lee y verifica
+// igual que en Get, porque el archivo que lo contenía pudo cambiar.
+func (s *Store) Has(hash string) bool {
+	_, ok := s.Get(hash)
+	return ok
+}
+
+// Get lee un fragmento y verifica su contenido. Se prueban todas las ubicaciones
+// conocidas y se descartan las de archivos que cambiaron.
+func (s *Store) Get(hash string) ([]byte, bool) {
+	s.lock()
+	locs := append([]Location(nil), s.byHash[hash]...)
+	s.mutex.Unlock()
+
+	for _, loc := range locs {
+		data, err := s.read(loc)
+		if err == nil && Hash(data) == hash {
+			return data, true
+		}
+		s.lock()
+		s.dropLocked(hash, loc)
+		s.mutex.Unlock()
+	}
+	return nil, false
+}
+
+// AddData registra los fragmentos del contenido actual de un archivo de shared
+func (s *Store) AddData(rel string, data []byte) []Chunk {
+	chunks := Split(data)
+	s.AddChunks(rel, chunks)
+	return chunks
+}
+
+// AddFile lee un archivo de shared y registra sus fragmentos
+func (s *Store) AddFile(rel string) error {
+	data, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	s.AddData(rel, data)
+	return nil
+}
+
+// AddChunks registra fragmentos ya calculados del contenido actual de rel
+func (s *Store) AddChunks(rel string, chunks []Chunk) {
+	rel = filepath.ToSlash(rel)
+	s.lock()
+	defer s.mutex.Unlock()
+	s.removeLocked(rel)
+	for _, c := range chunks {
+		s.addLocked(c.Hash, Location{Path: rel, Offset: c.Offset, Size: c.Size})
+	}
+	s.dirty = true
+}
+
+// Remove olvida los fragmentos que apuntaban a rel
+func (s *Store) Remove(rel string) {
+	s.lock()
+	defer s.mutex.Unlock()
+	s.removeLocked(filepath.ToSlash(rel))
+}
+
+// Save escribe el almacén a disco si cambió desde la última vez
+func (s *Store) Save() error {
+	s.lock()
+	if !s.dirty {
+		s.mutex.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.byHash)
+	s.dirty = false
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), 0755); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// StartAutosave guarda el almacén periódicamente
+func (s *Store) StartAutosave(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Save(); err != nil {
+				fmt.Println("⚠️ No se pudo guardar el almacén de fragmentos:", err)
+			}
+		}
+	}()
+}
+
+// addLocked agrega una ubicación del fragmento; se asume que mutex está tomado
+func (s *Store) addLocked(hash string, loc Location) {
+	for _, l := range s.byHash[hash] {
+		if l == loc {
+			return
+		}
+	}
+	s.byHash[hash] = append(s.byHash[hash], loc)
+	s.byPath[loc.Path] = append(s.byPath[loc.Path], hash)
+}
+
+// dropLocked quita una ubicación que ya no contiene el fragmento; se asume que
+// mutex está tomado
+func (s *Store) dropLocked(hash string, loc Location) {
+	locs := s.byHash[hash]
+	for i, l := range locs {
+		if l == loc {
+			locs = append(locs[:i:i], locs[i+1:]...)
+			s.dirty = true
+			break
+		}
+	}
+	if len(locs) == 0 {
+		delete(s.byHash, hash)
+	} else {
+		s.byHash[hash] = locs
+	}
+}
+
+// removeLocked quita las ubicaciones dentro de rel; los fragmentos que también
+// están en otros archivos siguen disponibles. Se asume que mutex está tomado.
+func (s *Store) removeLocked(rel string) {
+	for _, hash := range s.byPath[rel] {
+		var keep []Location
+		for _, l := range s.byHash[hash] {
+			if l.Path != rel {
+				keep = append(keep, l)
+			}
+		}
+		if len(keep) == 0 {
+			delete(s.byHash, hash)
+		} else {
+			s.byHash[hash] = keep
+		}
+		s.dirty = true
+	}
+	delete(s.byPath, rel)
+}
+
+func (s *Store) read(loc Location) ([]byte, error) {
+	f, err := os.Open(filepath.Join(s.root, filepath.FromSlash(loc.Path)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, loc.Size)
+	if _, err := f.ReadAt(buf, loc.Offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ErrMissingChunk indica que un fragmento no llegó ni está ya en el almacén local
+var ErrMissingChunk = errors.New("falta el fragmento")
+
+// Assemble escribe en path el archivo formado por chunks, tomando cada fragmento
+// de provided o del almacén local. El resultado se escribe en un temporal, se
+// verifica contra expected y recién entonces reemplaza a path.
+func (s *Store) Assemble(path string, chunks []Chunk, provided map[string][]byte, expected string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo crear el temporal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.New()
+	var size int64
+	for _, c := range chunks {
+		data, ok := provided[c.Hash]
+		if !ok || Hash(data) != c.Hash {
+			data, ok = s.Get(c.Hash)
+		}
+		if !ok {
+			tmp.Close()
+			return size, fmt.Errorf("%w %.12s", ErrMissingChunk, c.Hash)
+		}
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return size, err
+		}
+		sum.Write(data)
+		size += int64(len(data))
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return size, err
+	}
+	if err := tmp.Close(); err != nil {
+		return size, err
+	}
+	if expected != "" && hex.EncodeToString(sum.Sum(nil)) != expected {
+		return size, fmt.Errorf("el archivo reconstruido no coincide con el original")
+	}
+	_ = os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return size, fmt.Errorf("no se pudo reemplazar %s: %w", path, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,131 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"p2pfs/internal/state"
+)
+
+// Gzip es la única codificación soportada por ahora
+const Gzip = "gzip"
+
+// Supported es la lista que se anuncia a los peers
+var Supported = []string{Gzip}
+
+// minSize evita comprimir archivos tan chicos que la cabecera no compensa
+const minSize = 1024
+
+// Encode comprime data si el peer acepta gzip y el tipo de archivo lo justifica.
+// Devuelve el contenido a enviar y su codificación ("" si va sin comprimir).
+func Encode(name string, data []byte, accepted []string) ([]byte, string) {
+	if !accepts(accepted, Gzip) || len(data) < minSize || state.IsCompressedFormat(name) {
+		record(name, len(data), len(data), false)
+		return data, ""
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.DefaultCompression)
+	if _, err := zw.Write(data); err != nil || zw.Close() != nil {
+		record(name, len(data), len(data), false)
+		return data, ""
+	}
+	// Si no se gana al menos un 10% se envía tal cual
+	if buf.Len() > len(data)*9/10 {
+		record(name, len(data), len(data), false)
+		return data, ""
+	}
+	record(name, len(data), buf.Len(), true)
+	return buf.Bytes(), Gzip
+}
+
+// Decode devuelve el contenido original de un payload recibido
+func Decode(encoding string, payload []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return payload, nil
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("contenido gzip inválido: %w", err)
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("codificación no soportada: %s", encoding)
+	}
+}
+
+// ParseAccepted interpreta la lista de codificaciones de un mensaje recibido
+func ParseAccepted(raw interface{}) []string {
+	list, _ := raw.([]interface{})
+	var result []string
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func accepts(list []string, encoding string) bool {
+	for _, e := range list {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// ===============================
+// Estadísticas de compresión
+// ===============================
+
+// Stats acumula cuánto se ahorró comprimiendo desde que arrancó el nodo
+type Stats struct {
+	Compressed int   // Archivos enviados comprimidos
+	Skipped    int   // Archivos enviados sin comprimir
+	RawBytes   int64 // Tamaño original de los comprimidos
+	WireBytes  int64 // Tamaño comprimido
+}
+
+// Ratio devuelve el tamaño comprimido como fracción del original (0.25 = 75% de ahorro)
+func (s Stats) Ratio() float64 {
+	if s.RawBytes == 0 {
+		return 1
+	}
+	return float64(s.WireBytes) / float64(s.RawBytes)
+}
+
+// String resume las estadísticas en una línea
+func (s Stats) String() string {
+	return fmt.Sprintf("%d comprimido(s), %d sin comprimir, %d → %d bytes (%.0f%% del original)",
+		s.Compressed, s.Skipped, s.RawBytes, s.WireBytes, s.Ratio()*100)
+}
+
+var (
+	statsMutex sync.Mutex
+	stats      Stats
+)
+
+// CurrentStats devuelve una copia de las estadísticas acumuladas
+func CurrentStats() Stats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	return stats
+}
+
+func record(name string, raw, wire int, compressed bool) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	if !compressed {
+		stats.Skipped++
+		return
+	}
+	stats.Compressed++
+	stats.RawBytes += int64(raw)
+	stats.WireBytes += int64(wire)
+	fmt.Printf("🗜️ %s comprimido: %d → %d bytes (%.0f%%)\n", name, raw, wire, float64(wire)*100/float64(raw))
+}
@@ -0,0 +1,231 @@
+package delta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Transferencia diferencial al estilo rsync: el receptor describe su copia con
+// una firma por bloque (suma débil rodante + hash fuerte) y el emisor envía solo
+// referencias a los bloques que el receptor ya tiene más los datos literales.
+
+const (
+	MinBlockSize = 2 * 1024
+	MaxBlockSize = 64 * 1024
+)
+
+// BlockSignature describe un bloque de la copia del receptor
+type BlockSignature struct {
+	Weak   uint32 `json:"weak"`
+	Strong string `json:"strong"`
+}
+
+// Signature es la firma completa de un archivo
+type Signature struct {
+	BlockSize int              `json:"blockSize"`
+	Size      int64            `json:"size"`
+	Blocks    []BlockSignature `json:"blocks"`
+}
+
+// Op es una instrucción para reconstruir el archivo: copiar Count bloques de la
+// copia del receptor a partir de Block, o escribir Data tal cual
+type Op struct {
+	Block int    `json:"block,omitempty"`
+	Count int    `json:"count,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// BlockSizeFor elige un tamaño de bloque proporcional a la raíz del tamaño del archivo
+func BlockSizeFor(size int64) int {
+	bs := int(math.Sqrt(float64(size)))
+	if bs < MinBlockSize {
+		return MinBlockSize
+	}
+	if bs > MaxBlockSize {
+		return MaxBlockSize
+	}
+	return bs
+}
+
+// ComputeSignature lee r por bloques y calcula la firma de cada uno
+func ComputeSignature(r io.Reader, blockSize int) (Signature, error) {
+	if blockSize <= 0 {
+		return Signature{}, fmt.Errorf("tamaño de bloque inválido: %d", blockSize)
+	}
+	sig := Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sig.Blocks = append(sig.Blocks, BlockSignature{
+				Weak:   weakSum(buf[:n]),
+				Strong: strongSum(buf[:n]),
+			})
+			sig.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return sig, err
+		}
+	}
+}
+
+// ComputeDelta compara data con la firma del receptor y devuelve las
+// instrucciones para reconstruir data a partir de la copia firmada
+func ComputeDelta(sig Signature, data []byte) []Op {
+	bs := sig.BlockSize
+	n := len(data)
+	if bs <= 0 || len(sig.Blocks) == 0 {
+		return literal(nil, data)
+	}
+
+	// Solo los bloques completos se buscan con la suma rodante; el último,
+	// si es más corto, solo puede coincidir con el final de data
+	table := make(map[uint32][]int)
+	full := len(sig.Blocks)
+	lastLen := int(sig.Size - int64(bs)*int64(len(sig.Blocks)-1))
+	if lastLen < bs {
+		full--
+	}
+	for i := 0; i < full; i++ {
+		w := sig.Blocks[i].Weak
+		table[w] = append(table[w], i)
+	}
+
+	var ops []Op
+	litStart := 0
+	i := 0
+	var a, b uint32
+	rolling := false
+	for i+bs <= n {
+		if !rolling {
+			a, b = weakParts(data[i : i+bs])
+			rolling = true
+		}
+		if block, ok := findBlock(sig, table, a|b<<16, data[i:i+bs]); ok {
+			ops = literal(ops, data[litStart:i])
+			ops = appendCopy(ops, block)
+			i += bs
+			litStart = i
+			rolling = false
+			continue
+		}
+		// Avanzar un byte actualizando la suma sin recorrer el bloque
+		if i+bs < n {
+			out, in := uint32(data[i]), uint32(data[i+bs])
+			a = (a - out + in) & 0xffff
+			b = (b - uint32(bs)*out + a) & 0xffff
+		} else {
+			rolling = false
+		}
+		i++
+	}
+
+	if lastLen > 0 && lastLen < bs && n-litStart >= lastLen {
+		tail := data[n-lastLen:]
+		last := len(sig.Blocks) - 1
+		if weakSum(tail) == sig.Blocks[last].Weak && strongSum(tail) == sig.Blocks[last].Strong {
+			ops = literal(ops, data[litStart:n-lastLen])
+			ops = appendCopy(ops, last)
+			return ops
+		}
+	}
+	return literal(ops, data[litStart:])
+}
+
+// Apply reconstruye el archivo en w a partir de la copia base y las instrucciones
+func Apply(base io.ReaderAt, baseSize int64, blockSize int, ops []Op, w io.Writer) error {
+	for _, op := range ops {
+		if op.Count == 0 {
+			if _, err := w.Write(op.Data); err != nil {
+				return err
+			}
+			continue
+		}
+		offset := int64(op.Block) * int64(blockSize)
+		length := int64(op.Count) * int64(blockSize)
+		if op.Block < 0 || offset >= baseSize {
+			return fmt.Errorf("bloque %d fuera de la copia local", op.Block)
+		}
+		if offset+length > baseSize {
+			length = baseSize - offset
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(base, offset, length)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LiteralBytes devuelve cuántos bytes de datos viajan en las instrucciones
+func LiteralBytes(ops []Op) int64 {
+	var total int64
+	for _, op := range ops {
+		total += int64(len(op.Data))
+	}
+	return total
+}
+
+func findBlock(sig Signature, table map[uint32][]int, weak uint32, window []byte) (int, bool) {
+	candidates, ok := table[weak]
+	if !ok {
+		return 0, false
+	}
+	strong := strongSum(window)
+	for _, idx := range candidates {
+		if sig.Blocks[idx].Strong == strong {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// appendCopy agrega una copia de bloque, uniéndola a la anterior si es contigua
+func appendCopy(ops []Op, block int) []Op {
+	if len(ops) > 0 {
+		last := &ops[len(ops)-1]
+		if last.Count > 0 && last.Block+last.Count == block {
+			last.Count++
+			return ops
+		}
+	}
+	return append(ops, Op{Block: block, Count: 1})
+}
+
+// literal agrega datos literales, uniéndolos a los anteriores si los hay
+func literal(ops []Op, data []byte) []Op {
+	if len(data) == 0 {
+		return ops
+	}
+	if len(ops) > 0 && ops[len(ops)-1].Count == 0 {
+		last := &ops[len(ops)-1]
+		last.Data = append(last.Data, data...)
+		return ops
+	}
+	return append(ops, Op{Data: append([]byte(nil), data...)})
+}
+
+// weakParts calcula las dos mitades de la suma de Adler usada por rsync
+func weakParts(block []byte) (a, b uint32) {
+	l := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (l - uint32(i)) * uint32(c)
+	}
+	return a & 0xffff, b & 0xffff
+}
+
+func weakSum(block []byte) uint32 {
+	a, b := weakParts(block)
+	return a | b<<16
+}
+
+func strongSum(block []byte) string {
+	sum := sha256.Sum256(block)
+	return hex.EncodeToString(sum[:16])
+}
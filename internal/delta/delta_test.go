@@ -0,0 +1,88 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func join(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	const bs = MinBlockSize
+	base := randomData(1, 10*bs+123)
+	other := randomData(2, 4*bs)
+
+	tests := []struct {
+		name       string
+		base, data []byte
+		maxLiteral int64 // Bytes literales admitidos como máximo
+	}{
+		{"idéntico", base, base, 0},
+		{"agregado al final", base, join(base, []byte("cola nueva")), int64(len("cola nueva")) + bs},
+		{"insertado al inicio", base, join([]byte("cabecera"), base), int64(len("cabecera")) + bs},
+		{"editado en el medio", base, join(base[:5*bs], []byte("XYZ"), base[5*bs+3:]), 2 * bs},
+		{"recortado", base, base[:3*bs+7], bs},
+		{"base vacía", nil, other, int64(len(other))},
+		{"resultado vacío", base, nil, 0},
+		{"todo distinto", base, other, int64(len(other))},
+		{"más chico que un bloque", base[:100], base[:50], 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := ComputeSignature(bytes.NewReader(tt.base), bs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops := ComputeDelta(sig, tt.data)
+
+			var out bytes.Buffer
+			if err := Apply(bytes.NewReader(tt.base), int64(len(tt.base)), bs, ops, &out); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), tt.data) {
+				t.Fatalf("reconstruidos %d bytes que no coinciden con los %d esperados", out.Len(), len(tt.data))
+			}
+			if lit := LiteralBytes(ops); lit > tt.maxLiteral {
+				t.Errorf("viajan %d bytes literales, se esperaban a lo sumo %d", lit, tt.maxLiteral)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsBlockOutsideBase(t *testing.T) {
+	base := randomData(3, MinBlockSize)
+	ops := []Op{{Block: 5, Count: 1}}
+	if err := Apply(bytes.NewReader(base), int64(len(base)), MinBlockSize, ops, &bytes.Buffer{}); err == nil {
+		t.Error("se aceptó un bloque que la copia local no tiene")
+	}
+}
+
+func TestBlockSizeFor(t *testing.T) {
+	tests := []struct {
+		size int64
+		want int
+	}{
+		{0, MinBlockSize},
+		{1024 * 1024, MinBlockSize},
+		{100 * 1024 * 1024, 10240},
+		{1 << 40, MaxBlockSize},
+	}
+	for _, tt := range tests {
+		if got := BlockSizeFor(tt.size); got != tt.want {
+			t.Errorf("BlockSizeFor(%d) = %d, quería %d", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"p2pfs/internal/peer"
+)
+
+// batchParallel limita cuántos elementos de una selección se procesan a la vez.
+// Las carpetas reparten sus archivos en el planificador, por eso no se usa aquí.
+const batchParallel = 4
+
+// BatchResult es el resultado de una operación sobre un elemento de la selección
+type BatchResult struct {
+	Item  SelectedFile
+	Count int // Destinos alcanzados, para transferencias
+	Err   error
+}
+
+// BatchReport agrupa los resultados de una operación sobre varios elementos
+type BatchReport struct {
+	Results []BatchResult
+}
+
+// Failed devuelve los elementos que fallaron
+func (r BatchReport) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Summary resume el reporte en una línea, ej. "28 de 30 elementos; 2 con errores"
+func (r BatchReport) Summary() string {
+	failed := len(r.Failed())
+	line := fmt.Sprintf("%d de %d elemento(s)", len(r.Results)-failed, len(r.Results))
+	if failed > 0 {
+		line += fmt.Sprintf("; %d con errores", failed)
+	}
+	return line
+}
+
+// Details lista los errores de cada elemento, uno por línea
+func (r BatchReport) Details() string {
+	var b strings.Builder
+	for _, res := range r.Failed() {
+		fmt.Fprintf(&b, "%s (Maq%d): %v\n", res.Item.FileName, res.Item.PeerID, res.Err)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// TransferFiles aplica TransferFile a cada elemento de la selección. Como en
+// DeleteFiles, lo que está dentro de una carpeta seleccionada viaja con ella.
+func TransferFiles(peerSystem *peer.Peer, selected []SelectedFile, checkedPeers map[int]bool) BatchReport {
+	return runBatch(withoutNested(selected), func(item SelectedFile) (int, error) {
+		return TransferFile(peerSystem, item, checkedPeers)
+	})
+}
+
+// DeleteFiles aplica DeleteFile a cada elemento de la selección. Los elementos
+// dentro de una carpeta también seleccionada se omiten: se borran con ella.
+func DeleteFiles(peerSystem *peer.Peer, selected []SelectedFile) BatchReport {
+	return runBatch(withoutNested(selected), func(item SelectedFile) (int, error) {
+		return 1, DeleteFile(peerSystem, item)
+	})
+}
+
+func runBatch(items []SelectedFile, op func(SelectedFile) (int, error)) BatchReport {
+	report := BatchReport{Results: make([]BatchResult, len(items))}
+	sem := make(chan struct{}, batchParallel)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item SelectedFile) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			count, err := op(item)
+			report.Results[i] = BatchResult{Item: item, Count: count, Err: err}
+		}(i, item)
+	}
+	wg.Wait()
+	return report
+}
+
+// withoutNested quita los elementos que están dentro de otro elemento seleccionado del mismo peer
+func withoutNested(items []SelectedFile) []SelectedFile {
+	var out []SelectedFile
+	for _, item := range items {
+		nested := false
+		for _, other := range items {
+			if other.PeerID == item.PeerID && strings.HasPrefix(item.FileName, other.FileName+"/") {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			out = append(out, item)
+		}
+	}
+	return out
+}
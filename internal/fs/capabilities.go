@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"p2pfs/internal/peer"
+)
+
+var (
+	capsMutex     sync.Mutex
+	peerEncodings = make(map[int][]string)
+)
+
+// encodingsFor devuelve las codificaciones que acepta un peer para SEND_FILE.
+// Se consulta una vez por peer; uno antiguo que no entiende GET_CAPABILITIES
+// cierra la conexión y queda registrado sin compresión.
+func encodingsFor(p peer.PeerInfo) []string {
+	capsMutex.Lock()
+	encodings, ok := peerEncodings[p.ID]
+	capsMutex.Unlock()
+	if ok {
+		return encodings
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 2*time.Second)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(map[string]interface{}{"type": "GET_CAPABILITIES"}); err != nil {
+		return nil
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp struct {
+		Type      string   `json:"type"`
+		Encodings []string `json:"encodings"`
+	}
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	encodings = resp.Encodings
+	if encodings == nil {
+		encodings = []string{}
+	}
+
+	capsMutex.Lock()
+	peerEncodings[p.ID] = encodings
+	capsMutex.Unlock()
+	return encodings
+}
@@ -0,0 +1,197 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+	"p2pfs/internal/transfer"
+)
+
+// chunkMinSize es el tamaño a partir del cual se negocian fragmentos antes de
+// transferir; un archivo más chico cabe en pocos fragmentos y no compensa
+const chunkMinSize = 4 * chunk.MinSize
+
+// sendChunked consulta qué fragmentos del archivo ya tiene el peer y envía solo
+// los que le faltan. Devuelve false (sin error) si el peer no tiene ninguno o no
+// soporta el protocolo, en cuyo caso conviene enviar el archivo completo.
+func sendChunked(p peer.PeerInfo, h *transfer.Handle, name string, data []byte, modTime time.Time, version state.VersionVector) (bool, error) {
+	chunks := chunk.Split(data)
+	hashes := make([]string, len(chunks))
+	for i, c := range chunks {
+		hashes[i] = c.Hash
+	}
+
+	var have struct {
+		Type  string `json:"type"`
+		Have  []bool `json:"have"`
+		Error string `json:"error"`
+	}
+	err := chunkRequest(p, h, map[string]interface{}{
+		"type":   "CHUNK_QUERY",
+		"hashes": hashes,
+	}, &have)
+	if err == errUnsupported {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if have.Type != "CHUNK_HAVE" || len(have.Have) != len(chunks) {
+		return false, fmt.Errorf("respuesta inesperada: %v %s", have.Type, have.Error)
+	}
+
+	missing := make(map[string][]byte)
+	var sent int64
+	for i, c := range chunks {
+		if !have.Have[i] {
+			missing[c.Hash] = data[c.Offset : c.Offset+int64(c.Size)]
+			sent += int64(c.Size)
+		}
+	}
+	if len(missing) == len(chunks) {
+		return false, nil
+	}
+
+	var ack struct {
+		Type  string `json:"type"`
+		Error string `json:"error"`
+	}
+	err = chunkRequest(p, h, map[string]interface{}{
+		"type":    "SEND_CHUNKED",
+		"name":    name,
+		"hash":    state.HashBytes(data),
+		"chunks":  chunks,
+		"data":    missing,
+		"modTime": modTime,
+		"version": version,
+	}, &ack)
+	if err != nil {
+		return false, err
+	}
+	if ack.Type != "CHUNKED_ACK" {
+		return false, fmt.Errorf("el peer rechazó los fragmentos: %s", ack.Error)
+	}
+	fmt.Printf("🧱 %s enviado por fragmentos: %d de %d bytes (%d/%d fragmentos)\n", name, sent, len(data), len(missing), len(chunks))
+	return true, nil
+}
+
+// fetchChunked descarga un archivo pidiendo solo los fragmentos que no están en
+// el almacén local. Devuelve errUnsupported si el peer no conoce el protocolo.
+func fetchChunked(p peer.PeerInfo, h *transfer.Handle, filename, path string) (int64, string, error) {
+	var list struct {
+		Type    string              `json:"type"`
+		Chunks  []chunk.Chunk       `json:"chunks"`
+		Hash    string              `json:"hash"`
+		ModTime time.Time           `json:"modTime"`
+		Version state.VersionVector `json:"version"`
+		Error   string              `json:"error"`
+	}
+	err := chunkRequest(p, h, map[string]interface{}{
+		"type": "GET_CHUNK_LIST",
+		"name": filename,
+	}, &list)
+	if err != nil {
+		return 0, "", err
+	}
+	if list.Type != "CHUNK_LIST" {
+		return 0, "", fmt.Errorf("respuesta inesperada del peer: %v", list.Error)
+	}
+
+	var missing []chunk.Chunk
+	seen := make(map[string]bool)
+	for _, c := range list.Chunks {
+		if !seen[c.Hash] && !chunk.Local.Has(c.Hash) {
+			missing = append(missing, c)
+		}
+		seen[c.Hash] = true
+	}
+
+	var provided map[string][]byte
+	if len(missing) > 0 {
+		// Si otros peers tienen el mismo contenido se reparte la descarga entre todos
+		sources := swarmSources(p, filename, list.Hash)
+		if len(sources) > 1 {
+			fmt.Printf("🐝 Descargando %s desde %d fuentes\n", filename, len(sources))
+			provided, err = fetchSwarm(h, sources, missing)
+		} else {
+			provided, err = fetchMissingChunks(p, h, filename, missing)
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("error al recibir fragmentos: %w", err)
+		}
+	}
+
+	defer beginReceive(path)()
+	size, err := chunk.Local.Assemble(path, list.Chunks, provided, list.Hash)
+	if err != nil {
+		return size, list.Hash, err
+	}
+	recordReceivedMetadata(path, map[string]interface{}{
+		"modTime": list.ModTime.Format(time.RFC3339Nano),
+		"version": list.Version,
+	})
+	if rel, err := filepath.Rel("shared", path); err == nil {
+		chunk.Local.AddChunks(rel, list.Chunks)
+		if info, err := os.Stat(path); err == nil {
+			state.StoreHash(rel, info, list.Hash)
+		}
+	}
+	if len(missing) < len(seen) {
+		fmt.Printf("🧱 %s: %d de %d fragmentos ya estaban disponibles localmente\n", filename, len(seen)-len(missing), len(seen))
+	}
+	return size, list.Hash, nil
+}
+
+// fetchMissingChunks pide a un solo peer los fragmentos indicados
+func fetchMissingChunks(p peer.PeerInfo, h *transfer.Handle, filename string, missing []chunk.Chunk) (map[string][]byte, error) {
+	hashes := make([]string, len(missing))
+	for i, c := range missing {
+		hashes[i] = c.Hash
+	}
+	var got struct {
+		Type  string            `json:"type"`
+		Data  map[string][]byte `json:"data"`
+		Error string            `json:"error"`
+	}
+	err := chunkRequest(p, h, map[string]interface{}{
+		"type":   "GET_CHUNKS",
+		"name":   filename,
+		"hashes": hashes,
+	}, &got)
+	if err != nil {
+		return nil, err
+	}
+	if got.Type != "CHUNKS" {
+		return nil, fmt.Errorf("respuesta inesperada del peer: %v", got.Error)
+	}
+	return got.Data, nil
+}
+
+// chunkRequest envía un mensaje y decodifica la respuesta en resp
+func chunkRequest(p peer.PeerInfo, h *transfer.Handle, req map[string]interface{}, resp interface{}) error {
+	conn, err := dialTransfer(p, h)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		if err == io.EOF {
+			// Un peer antiguo cierra la conexión sin responder a un tipo desconocido
+			return errUnsupported
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/log"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// DetectConflicts devuelve los archivos presentes en ambos lados cuyas versiones
+// son concurrentes: cada nodo modificó su copia sin conocer la del otro
+func DetectConflicts(localFiles, remoteFiles []state.FileInfo) []state.FileInfo {
+	remoteMap := toFileMap(remoteFiles)
+	var result []state.FileInfo
+	for _, lf := range localFiles {
+		rf, ok := remoteMap[lf.Name]
+		if !ok || len(lf.Version) == 0 || len(rf.Version) == 0 {
+			continue
+		}
+		// El hash local se calcula solo para los candidatos a conflicto
+		if rf.Hash != "" && lf.Hash == "" {
+			lf.Hash, _ = state.EnsureHash(lf.Name)
+		}
+		if lf.Version.Compare(rf.Version) == state.Concurrent && !sameContent(lf, rf) {
+			result = append(result, rf)
+		}
+	}
+	return result
+}
+
+// ConflictCopyName arma el nombre name.conflict-<nodo>-<fecha>.ext para la versión de otro nodo
+func ConflictCopyName(name string, nodeID int, t time.Time) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	return fmt.Sprintf("%s.conflict-%d-%s%s", base, nodeID, t.Format("20060102-150405"), ext)
+}
+
+// resolvedCopyName propone un nombre libre para conservar la versión de otro
+// nodo como archivo normal: "a (Maq2).txt", "a (Maq2 2).txt"...
+func resolvedCopyName(name string, nodeID int) string {
+	ext := filepath.Ext(name)
+	if ext == filepath.Base(name) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	candidate := fmt.Sprintf("%s (Maq%d)%s", base, nodeID, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join("shared", candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (Maq%d %d)%s", base, nodeID, i, ext)
+	}
+}
+
+// isConflictCopy indica si el nombre corresponde a una copia de conflicto
+func isConflictCopy(name string) bool {
+	return strings.Contains(filepath.Base(name), ".conflict-")
+}
+
+// keepConflict descarga la versión remota junto a la local y registra el conflicto.
+// La ruta queda excluida de la sincronización hasta que se resuelva.
+func keepConflict(p peer.PeerInfo, local, remote state.FileInfo) error {
+	copyName := ConflictCopyName(remote.Name, p.ID, time.Now())
+	size, hash, err := fetchFileTo(p, nil, remote.Name, filepath.Join("shared", copyName))
+	audit.RecordOp("TRANSFER", copyName, p.ID, peer.Local.ID, size, hash, err)
+	if err != nil {
+		return err
+	}
+	state.AddConflict(state.Conflict{
+		Path:          remote.Name,
+		PeerID:        p.ID,
+		ConflictCopy:  copyName,
+		Detected:      time.Now(),
+		LocalVersion:  local.Version,
+		RemoteVersion: remote.Version,
+	})
+	fmt.Printf("⚠️ Conflicto en %s: versión de Maq%d guardada como %s\n", remote.Name, p.ID, copyName)
+	return nil
+}
+
+// ResolveConflict aplica la decisión del usuario sobre un conflicto:
+// "mine" conserva la versión local, "theirs" la remota y "both" conserva
+// ambas, dejando la remota como archivo separado. Las copias de conflicto no se
+// sincronizan, así que con "both" la copia pasa a tener un nombre normal.
+func ResolveConflict(path, choice string) error {
+	var conflict *state.Conflict
+	for _, c := range state.GetConflicts() {
+		if c.Path == path {
+			c := c
+			conflict = &c
+			break
+		}
+	}
+	if conflict == nil {
+		return fmt.Errorf("no hay conflicto registrado para %s", path)
+	}
+
+	localPath := filepath.Join("shared", conflict.Path)
+	copyPath := filepath.Join("shared", conflict.ConflictCopy)
+	keptName := ""
+
+	switch choice {
+	case "mine":
+		if err := os.Remove(copyPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("no se pudo eliminar la copia de conflicto: %w", err)
+		}
+		state.ForgetVersion(conflict.ConflictCopy)
+	case "theirs":
+		defer beginReceive(localPath)()
+		if err := os.Rename(copyPath, localPath); err != nil {
+			return fmt.Errorf("no se pudo reemplazar la versión local: %w", err)
+		}
+		state.ForgetVersion(conflict.ConflictCopy)
+	case "both":
+		keptName = resolvedCopyName(conflict.Path, conflict.PeerID)
+		if err := os.Rename(copyPath, filepath.Join("shared", keptName)); err != nil {
+			return fmt.Errorf("no se pudo conservar la versión remota: %w", err)
+		}
+		state.RenameVersion(conflict.ConflictCopy, keptName)
+		fmt.Printf("📄 Versión de Maq%d de %s conservada como %s\n", conflict.PeerID, conflict.Path, keptName)
+	default:
+		return fmt.Errorf("opción de resolución desconocida: %s", choice)
+	}
+
+	// La versión que queda domina a ambas ramas, así se propaga al resto de los nodos
+	info, err := os.Stat(localPath)
+	if err == nil {
+		state.RecordReceivedVersion(conflict.Path, conflict.LocalVersion, info.ModTime())
+	}
+	state.BumpVersion(conflict.Path, conflict.RemoteVersion)
+	state.RemoveConflict(conflict.Path)
+
+	audit.Record(log.LogEntry{
+		Action:   "RESOLVE",
+		FileName: conflict.Path,
+		NewName:  filepath.ToSlash(keptName),
+		OriginID: conflict.PeerID,
+		TargetID: peer.Local.ID,
+		Outcome:  "ok: " + choice,
+	})
+	TriggerFolderSync()
+	return nil
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"p2pfs/internal/state"
+)
+
+// chdirShared ejecuta la prueba en una carpeta temporal con shared/ vacía
+func chdirShared(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.MkdirAll("shared", 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	path := filepath.Join("shared", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvedCopyName(t *testing.T) {
+	chdirShared(t)
+	writeFile(t, "docs/b (Maq3).txt", "")
+
+	tests := []struct {
+		name   string
+		nodeID int
+		want   string
+	}{
+		{"a.txt", 2, "a (Maq2).txt"},
+		{"docs/b.txt", 3, "docs/b (Maq3 2).txt"},
+		{"Makefile", 2, "Makefile (Maq2)"},
+		{"archivo.tar.gz", 2, "archivo.tar (Maq2).gz"},
+	}
+	for _, tt := range tests {
+		got := resolvedCopyName(tt.name, tt.nodeID)
+		if got != tt.want {
+			t.Errorf("resolvedCopyName(%q, %d) = %q, quería %q", tt.name, tt.nodeID, got, tt.want)
+		}
+		if isConflictCopy(got) {
+			t.Errorf("%q quedaría excluido de la sincronización", got)
+		}
+	}
+}
+
+func TestResolveConflictBoth(t *testing.T) {
+	chdirShared(t)
+	copyName := ConflictCopyName("a.txt", 2, time.Now())
+	writeFile(t, "a.txt", "mía")
+	writeFile(t, copyName, "suya")
+	state.AddConflict(state.Conflict{Path: "a.txt", PeerID: 2, ConflictCopy: copyName, Detected: time.Now()})
+	t.Cleanup(func() { state.RemoveConflict("a.txt") })
+
+	if err := ResolveConflict("a.txt", "both"); err != nil {
+		t.Fatal(err)
+	}
+	if state.HasConflict("a.txt") {
+		t.Error("el conflicto sigue registrado")
+	}
+	if _, err := os.Stat(filepath.Join("shared", copyName)); !os.IsNotExist(err) {
+		t.Errorf("la copia de conflicto sigue existiendo: %v", err)
+	}
+	for name, want := range map[string]string{"a.txt": "mía", "a (Maq2).txt": "suya"} {
+		if got, err := os.ReadFile(filepath.Join("shared", name)); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; quería %q", name, got, err, want)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
-	"strings"
+	"p2pfs/internal/audit"
 	"p2pfs/internal/peer"
 	"p2pfs/internal/state"
 )
@@ -28,6 +28,7 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 		} else {
 			delErr = os.Remove(path)
 		}
+		audit.RecordOp("DELETE", selected.FileName, localID, localID, 0, "", delErr)
 		if delErr != nil {
 			return fmt.Errorf("error al eliminar localmente: %w", delErr)
 		}
@@ -47,11 +48,11 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 		return fmt.Errorf("peer no encontrado")
 	}
 
-	if !state.OnlineStatus[remotePeer.IP] {
+	if !state.IsOnline(remotePeer.IP) {
 		// 🔴 Nodo desconectado → eliminación diferida (archivo o carpeta)
 		// Verificar si es un directorio
 		isDir := false
-		for _, f := range state.FileCache[remotePeer.IP] {
+		for _, f := range state.CachedFiles(remotePeer.IP) {
 			if f.Name == selected.FileName && f.IsDir {
 				isDir = true
 				break
@@ -60,21 +61,16 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 
 		if isDir {
 			// Eliminar visualmente y registrar cada archivo dentro del directorio
-			var nuevosCache []state.FileInfo
-			for _, f := range state.FileCache[remotePeer.IP] {
-				if strings.HasPrefix(f.Name, selected.FileName+"/") {
-					state.AddPendingOp(remotePeer.ID, state.PendingOperation{
-						Type:     "delete",
-						FilePath: f.Name,
-						TargetID: remotePeer.ID,
-						SourceID: localID,
-					})
-					peer.SendSyncLog("DELETE", f.Name, localID, remotePeer.ID)
-				} else {
-					nuevosCache = append(nuevosCache, f)
-				}
+			for _, f := range state.RemoveTreeFromCache(remotePeer.IP, selected.FileName) {
+				state.AddPendingOp(remotePeer.ID, state.PendingOperation{
+					Type:     "delete",
+					FilePath: f.Name,
+					TargetID: remotePeer.ID,
+					SourceID: localID,
+				})
+				peer.SendSyncLog("DELETE", f.Name, localID, remotePeer.ID)
+				audit.RecordPending("DELETE", f.Name, localID, remotePeer.ID)
 			}
-			state.FileCache[remotePeer.IP] = nuevosCache
 		} else {
 			// Eliminar archivo individual
 			state.RemoveFileFromCache(remotePeer.IP, selected.FileName)
@@ -85,6 +81,7 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 				SourceID: localID,
 			})
 			peer.SendSyncLog("DELETE", selected.FileName, localID, remotePeer.ID)
+			audit.RecordPending("DELETE", selected.FileName, localID, remotePeer.ID)
 		}
 
 		return fmt.Errorf("nodo desconectado, eliminación registrada como pendiente")
@@ -92,7 +89,8 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 
 	// Nodo conectado → enviar solicitud de eliminación
 	go func() {
-		sendDeleteRequest(*remotePeer, selected.FileName)
+		err := sendDeleteRequest(*remotePeer, selected.FileName)
+		audit.RecordOp("DELETE", selected.FileName, localID, remotePeer.ID, 0, "", err)
 		peer.SendSyncLog("DELETE", selected.FileName, localID, remotePeer.ID)
 	}()
 	return nil
@@ -101,11 +99,11 @@ func DeleteFile(peerSystem *peer.Peer, selected SelectedFile) error {
 
 
 // sendDeleteRequest envía DELETE_FILE a un nodo remoto, que debe decidir si es archivo o carpeta
-func sendDeleteRequest(p peer.PeerInfo, path string) {
+func sendDeleteRequest(p peer.PeerInfo, path string) error {
 	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", p.IP, p.Port))
 	if err != nil {
 		fmt.Println("❌ No se pudo conectar para eliminar:", err)
-		return
+		return err
 	}
 	defer conn.Close()
 
@@ -113,5 +111,16 @@ func sendDeleteRequest(p peer.PeerInfo, path string) {
 		"type": "DELETE_FILE",
 		"name": path,
 	}
-	json.NewEncoder(conn).Encode(msg)
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return err
+	}
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("sin confirmación de eliminación: %w", err)
+	}
+	if resp["status"] != "ok" {
+		return fmt.Errorf("el nodo remoto no pudo eliminar %s", path)
+	}
+	return nil
 }
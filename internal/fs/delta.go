@@ -0,0 +1,106 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"p2pfs/internal/delta"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+	"p2pfs/internal/transfer"
+)
+
+// deltaMinSize es el tamaño a partir del cual conviene intentar una transferencia
+// diferencial; para archivos chicos el intercambio de firmas no compensa
+const deltaMinSize = 256 * 1024
+
+// sendDelta intenta enviar solo los bloques modificados de un archivo. Devuelve
+// false (sin error) si el receptor no tiene una copia previa o no soporta el
+// protocolo, en cuyo caso hay que enviar el archivo completo.
+func sendDelta(p peer.PeerInfo, h *transfer.Handle, sendAsName string, data []byte, modTime time.Time, version state.VersionVector) (bool, error) {
+	sig, ok, err := requestSignature(p, sendAsName, delta.BlockSizeFor(int64(len(data))))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	ops := delta.ComputeDelta(sig, data)
+	literal := delta.LiteralBytes(ops)
+	if literal >= int64(len(data)) {
+		// Nada en común con la copia remota
+		return false, nil
+	}
+
+	conn, err := dialTransfer(p, h)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
+	}
+	defer conn.Close()
+
+	msg := map[string]interface{}{
+		"type":      "SEND_DELTA",
+		"name":      sendAsName,
+		"blockSize": sig.BlockSize,
+		"ops":       ops,
+		"hash":      state.HashBytes(data),
+		"modTime":   modTime,
+		"version":   version,
+	}
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return false, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+	var resp struct {
+		Type  string `json:"type"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return false, fmt.Errorf("sin confirmación del delta: %w", err)
+	}
+	if resp.Type != "DELTA_ACK" {
+		return false, fmt.Errorf("el peer rechazó el delta: %s", resp.Error)
+	}
+	fmt.Printf("🧩 %s enviado por delta: %d de %d bytes\n", sendAsName, literal, len(data))
+	return true, nil
+}
+
+// requestSignature pide la firma de la copia que el peer tiene de name
+func requestSignature(p peer.PeerInfo, name string, blockSize int) (delta.Signature, bool, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 2*time.Second)
+	if err != nil {
+		return delta.Signature{}, false, fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
+	}
+	defer conn.Close()
+
+	req := map[string]interface{}{
+		"type":      "GET_SIGNATURE",
+		"name":      name,
+		"blockSize": blockSize,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return delta.Signature{}, false, err
+	}
+
+	// Calcular la firma de un archivo grande puede tardar
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+	var resp struct {
+		Type      string          `json:"type"`
+		Exists    bool            `json:"exists"`
+		Signature delta.Signature `json:"signature"`
+		Error     string          `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if err == io.EOF {
+			// Peer sin soporte para deltas
+			return delta.Signature{}, false, nil
+		}
+		return delta.Signature{}, false, err
+	}
+	if resp.Type != "SIGNATURE" {
+		return delta.Signature{}, false, fmt.Errorf("respuesta inesperada: %v %s", resp.Type, resp.Error)
+	}
+	return resp.Signature, resp.Exists, nil
+}
@@ -0,0 +1,245 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// MakeDir crea una carpeta vacía en cualquier peer; si está desconectado la
+// creación queda pendiente hasta que vuelva
+func MakeDir(peerSystem *peer.Peer, peerID int, name string) error {
+	name = filepath.ToSlash(filepath.Clean(strings.TrimSpace(name)))
+	if name == "" || name == "." {
+		return fmt.Errorf("el nombre de la carpeta está vacío")
+	}
+	localID := peerSystem.Local.ID
+
+	if peerID == localID {
+		err := peer.MakeDirShared(name)
+		audit.RecordOp("MKDIR", name, localID, localID, 0, "", err)
+		return err
+	}
+
+	target, ok := findPeer(peerID)
+	if !ok {
+		return fmt.Errorf("peer no encontrado")
+	}
+	return makeRemoteDir(target, name, localID)
+}
+
+// errDirPending indica que el peer estaba desconectado y la carpeta quedó pendiente
+var errDirPending = fmt.Errorf("nodo desconectado, carpeta registrada como pendiente")
+
+// makeRemoteDir crea la carpeta name en target a pedido de localID, o la deja
+// pendiente si target está desconectado
+func makeRemoteDir(target peer.PeerInfo, name string, localID int) error {
+	if !state.IsOnline(target.IP) {
+		state.AddToFileCache(target.IP, state.FileInfo{Name: name, IsDir: true, ModTime: time.Now()})
+		state.AddPendingOp(target.ID, state.PendingOperation{
+			Type:     "mkdir",
+			FilePath: name,
+			TargetID: target.ID,
+			SourceID: localID,
+		})
+		audit.RecordPending("MKDIR", name, localID, target.ID)
+		return errDirPending
+	}
+
+	err := sendMakeDirRequest(target, name)
+	audit.RecordOp("MKDIR", name, localID, target.ID, 0, "", err)
+	if err != nil {
+		return err
+	}
+	state.AddToFileCache(target.IP, state.FileInfo{Name: name, IsDir: true, ModTime: time.Now()})
+	peer.SendSyncOp("MKDIR", name, "", localID, target.ID)
+	return nil
+}
+
+// CopyFile duplica un archivo o carpeta dentro del mismo peer. La copia la hace
+// el propio peer, así que el contenido no viaja por la red; si está desconectado
+// queda pendiente.
+func CopyFile(peerSystem *peer.Peer, selected SelectedFile, newName string) error {
+	name := filepath.ToSlash(filepath.Clean(selected.FileName))
+	newName = filepath.ToSlash(filepath.Clean(strings.TrimSpace(newName)))
+	if newName == "" || newName == "." {
+		return fmt.Errorf("el nombre de la copia está vacío")
+	}
+	localID := peerSystem.Local.ID
+
+	if selected.PeerID == localID {
+		err := peer.CopyShared(name, newName)
+		audit.RecordPaths("COPY", name, newName, localID, localID, err)
+		return err
+	}
+
+	target, ok := findPeer(selected.PeerID)
+	if !ok {
+		return fmt.Errorf("peer no encontrado")
+	}
+	if !state.IsOnline(target.IP) {
+		state.CopyInCache(target.IP, name, newName)
+		state.AddPendingOp(target.ID, state.PendingOperation{
+			Type:     "copy",
+			FilePath: name,
+			NewPath:  newName,
+			TargetID: target.ID,
+			SourceID: localID,
+		})
+		audit.RecordPendingPaths("COPY", name, newName, localID, target.ID)
+		return fmt.Errorf("nodo desconectado, copia registrada como pendiente")
+	}
+
+	err := sendCopyRequest(target, name, newName)
+	audit.RecordPaths("COPY", name, newName, localID, target.ID, err)
+	if err != nil {
+		return err
+	}
+	state.CopyInCache(target.IP, name, newName)
+	peer.SendSyncOp("COPY", name, newName, localID, target.ID)
+	return nil
+}
+
+// CopyName propone un nombre libre para duplicar name en un peer: "a (copia).txt",
+// "a (copia 2).txt"... según el listado conocido
+func CopyName(peerSystem *peer.Peer, peerID int, name string) string {
+	files := ListSharedFiles()
+	if peerID != peerSystem.Local.ID {
+		files = nil
+		if p, ok := findPeer(peerID); ok {
+			files = state.CachedFiles(p.IP)
+		}
+	}
+	taken := make(map[string]bool, len(files))
+	for _, f := range files {
+		taken[f.Name] = true
+	}
+	ext := filepath.Ext(name)
+	if ext == filepath.Base(name) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	candidate := base + " (copia)" + ext
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (copia %d)%s", base, i, ext)
+	}
+	return candidate
+}
+
+func sendMakeDirRequest(p peer.PeerInfo, name string) error {
+	err := sendFileOp(p, map[string]string{"type": "MAKE_DIR", "name": name})
+	if err == errUnsupported {
+		// Los nodos anteriores solo saben crear carpetas recibidas con SEND_FILE
+		return sendLegacyDir(p, name)
+	}
+	return err
+}
+
+func sendCopyRequest(p peer.PeerInfo, name, newName string) error {
+	err := sendFileOp(p, map[string]string{"type": "COPY_FILE", "name": name, "newName": newName})
+	if err == errUnsupported {
+		return fmt.Errorf("Maq%d no admite copias internas: %w", p.ID, err)
+	}
+	return err
+}
+
+// sendFileOp envía una operación sobre shared (RENAME_FILE, MAKE_DIR,
+// COPY_FILE) y espera la confirmación. Un peer que no conoce el mensaje cierra
+// la conexión sin responder: se informa como errUnsupported.
+func sendFileOp(p peer.PeerInfo, msg map[string]string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a Maq%d: %w", p.ID, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return err
+	}
+
+	// Copiar una carpeta grande puede demorar del lado remoto
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+	var resp struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if err == io.EOF {
+			return errUnsupported
+		}
+		return fmt.Errorf("sin confirmación de Maq%d: %w", p.ID, err)
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("Maq%d no pudo completar la operación: %s", p.ID, resp.Error)
+	}
+	return nil
+}
+
+// sendLegacyDir crea la carpeta con SEND_FILE {isDir}; no hay confirmación
+func sendLegacyDir(p peer.PeerInfo, name string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a Maq%d: %w", p.ID, err)
+	}
+	defer conn.Close()
+	return json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":  "SEND_FILE",
+		"name":  name,
+		"isDir": true,
+	})
+}
+
+// handleFileOp atiende FILE_OP {op, peer, name, newName} desde el nodo local (CLI
+// u otras herramientas): op es "mkdir", "copy" o "rename" sobre el peer indicado.
+// Responde FILE_OP_DONE con status "ok", "pending" (peer desconectado) o "error".
+func handleFileOp(conn net.Conn, request map[string]interface{}) {
+	enc := json.NewEncoder(conn)
+	if !peer.IsLocalControl(conn) {
+		_ = enc.Encode(map[string]interface{}{"type": "ERROR", "error": "Solo se acepta desde el nodo local"})
+		return
+	}
+	op, _ := request["op"].(string)
+	name, _ := request["name"].(string)
+	newName, _ := request["newName"].(string)
+	peerID := peer.Local.ID
+	if id, ok := request["peer"].(float64); ok && id != 0 {
+		peerID = int(id)
+	}
+
+	// Con el peer desconectado la operación se encola y devuelve error: se
+	// distingue de un fallo real porque aparece una operación pendiente nueva
+	queued := len(state.PeekPendingOps(peerID))
+
+	system := &peer.Peer{Local: peer.Local, Peers: peer.GetPeers()}
+	var err error
+	switch op {
+	case "mkdir":
+		err = MakeDir(system, peerID, name)
+	case "copy":
+		err = CopyFile(system, SelectedFile{FileName: name, PeerID: peerID}, newName)
+	case "rename":
+		err = RenameFile(system, SelectedFile{FileName: name, PeerID: peerID}, newName)
+	default:
+		_ = enc.Encode(map[string]interface{}{"type": "ERROR", "error": "Operación desconocida: " + op})
+		return
+	}
+
+	resp := map[string]interface{}{"type": "FILE_OP_DONE", "status": "ok"}
+	if err != nil {
+		resp["status"] = "error"
+		if len(state.PeekPendingOps(peerID)) > queued {
+			resp["status"] = "pending"
+		}
+		resp["error"] = err.Error()
+	}
+	_ = enc.Encode(resp)
+}
@@ -0,0 +1,429 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+var (
+	folderSyncConfigFile = filepath.Join("config", "sync.json")
+	folderSyncStateFile  = filepath.Join("data", "foldersync.json")
+)
+
+// FolderSyncConfig define la carpeta que se mantiene idéntica entre los peers elegidos
+type FolderSyncConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Folder          string `json:"folder"` // Relativa a shared; "." sincroniza todo
+	Peers           []int  `json:"peers"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+// FileChange describe un cambio local detectado entre dos escaneos
+type FileChange struct {
+	Type string // "create", "modify", "delete"
+	Name string
+}
+
+// FolderSync mantiene una carpeta sincronizada en ambos sentidos.
+// Para cada peer guarda la base acordada en la última ronda: la ruta y su fecha
+// de modificación. Comparar cada lado contra esa base distingue una eliminación
+// de un archivo que el otro lado todavía no tiene, aun después de una partición.
+type FolderSync struct {
+	cfg     FolderSyncConfig
+	system  *peer.Peer
+	mutex   sync.Mutex
+	base    map[int]map[string]time.Time
+	last    map[string]state.FileInfo
+	trigger chan struct{}
+}
+
+var folderSync *FolderSync
+
+// LoadFolderSyncConfig lee config/sync.json
+func LoadFolderSyncConfig() (FolderSyncConfig, error) {
+	var cfg FolderSyncConfig
+	data, err := os.ReadFile(folderSyncConfigFile)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error al decodificar %s: %w", folderSyncConfigFile, err)
+	}
+	if cfg.Folder == "" {
+		cfg.Folder = "."
+	}
+	cfg.Folder = filepath.ToSlash(filepath.Clean(cfg.Folder))
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 10
+	}
+	return cfg, nil
+}
+
+// StartFolderSync inicia el motor de sincronización si está habilitado en la configuración
+func StartFolderSync(peerSystem *peer.Peer) {
+	cfg, err := LoadFolderSyncConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println("⚠️ Sincronización de carpeta deshabilitada:", err)
+		}
+		return
+	}
+	if !cfg.Enabled {
+		return
+	}
+
+	s := &FolderSync{
+		cfg:     cfg,
+		system:  peerSystem,
+		base:    loadFolderSyncState(),
+		trigger: make(chan struct{}, 1),
+	}
+	folderSync = s
+	if localWatcher != nil {
+		localWatcher.Subscribe(func(events []WatchEvent) {
+			TriggerFolderSync()
+		})
+	}
+	fmt.Printf("🔁 Sincronizando carpeta '%s' con los nodos %v\n", cfg.Folder, cfg.Peers)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.IntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			s.RunOnce()
+			select {
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// TriggerFolderSync adelanta la próxima ronda de sincronización, si el motor está activo
+func TriggerFolderSync() {
+	if folderSync == nil {
+		return
+	}
+	select {
+	case folderSync.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunOnce ejecuta una ronda completa contra todos los peers configurados en línea
+func (s *FolderSync) RunOnce() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	local := s.scanLocal()
+	for _, c := range s.detectLocalChanges(local) {
+		fmt.Printf("🔁 Cambio local (%s): %s\n", c.Type, c.Name)
+	}
+
+	for _, p := range s.system.Peers {
+		if p.ID == s.system.Local.ID || !s.includesPeer(p.ID) {
+			continue
+		}
+		if err := s.syncWithPeer(p, local); err != nil {
+			fmt.Printf("⚠️ Sincronización con Maq%d pospuesta: %v\n", p.ID, err)
+			continue
+		}
+		// Lo aplicado con este peer puede haber cambiado la copia local
+		local = s.scanLocal()
+	}
+	s.last = toFileMap(local)
+
+	if err := saveFolderSyncState(s.base); err != nil {
+		fmt.Println("⚠️ No se pudo guardar el estado de sincronización:", err)
+	}
+}
+
+// detectLocalChanges compara el escaneo actual con el anterior
+func (s *FolderSync) detectLocalChanges(current []state.FileInfo) []FileChange {
+	if s.last == nil {
+		return nil
+	}
+	var changes []FileChange
+	seen := make(map[string]bool)
+	for _, f := range current {
+		seen[f.Name] = true
+		prev, ok := s.last[f.Name]
+		if !ok {
+			changes = append(changes, FileChange{Type: "create", Name: f.Name})
+		} else if !prev.ModTime.Equal(f.ModTime) {
+			changes = append(changes, FileChange{Type: "modify", Name: f.Name})
+		}
+	}
+	for name := range s.last {
+		if !seen[name] {
+			changes = append(changes, FileChange{Type: "delete", Name: name})
+		}
+	}
+	return changes
+}
+
+func (s *FolderSync) syncWithPeer(p peer.PeerInfo, localList []state.FileInfo) error {
+	if !state.IsOnline(p.IP) {
+		return fmt.Errorf("nodo desconectado")
+	}
+	remoteAll, err := fetchPeerListing(p)
+	if err != nil {
+		return err
+	}
+	remoteList := s.filterFolder(remoteAll)
+
+	local := toFileMap(localList)
+	remote := toFileMap(remoteList)
+	base := s.base[p.ID]
+	if base == nil {
+		base = make(map[string]time.Time)
+	}
+	localID := s.system.Local.ID
+
+	// 0. Renombres: se aplican del otro lado en lugar de eliminar y volver a copiar
+	s.propagateRenames(p, local, remote, base)
+
+	// 1. Eliminaciones: el archivo estaba en la base y falta en un solo lado.
+	// Si el otro lado lo modificó después de la base, se conserva la modificación.
+	for name, baseMod := range base {
+		lf, inL := local[name]
+		rf, inR := remote[name]
+		switch {
+		case inL && !inR && lf.ModTime.Equal(baseMod):
+			err := os.Remove(filepath.Join("shared", name))
+			audit.RecordOp("DELETE", name, p.ID, localID, 0, "", err)
+			if err == nil {
+				fmt.Println("🔁 Eliminado por sincronización:", name)
+				state.ForgetVersion(name)
+				delete(local, name)
+			}
+		case !inL && inR && rf.ModTime.Equal(baseMod):
+			err := sendDeleteRequest(p, name)
+			audit.RecordOp("DELETE", name, localID, p.ID, 0, "", err)
+			if err == nil {
+				delete(remote, name)
+			}
+		case !inL && !inR:
+			delete(base, name)
+		}
+	}
+
+	// 2. Modificaciones concurrentes: se conservan ambas versiones y la ruta
+	// queda fuera de la sincronización hasta que el usuario la resuelva
+	failed := make(map[string]bool)
+	for _, rf := range DetectConflicts(fromFileMap(local), fromFileMap(remote)) {
+		if state.HasConflict(rf.Name) {
+			continue
+		}
+		if err := keepConflict(p, local[rf.Name], rf); err != nil {
+			fmt.Printf("❌ No se pudo guardar la versión en conflicto de %s: %v\n", rf.Name, err)
+		}
+	}
+	for name := range local {
+		if state.HasConflict(name) {
+			delete(local, name)
+			delete(remote, name)
+			failed[name] = true
+		}
+	}
+	for name := range remote {
+		if state.HasConflict(name) {
+			delete(remote, name)
+			failed[name] = true
+		}
+	}
+
+	// 3. Creaciones y modificaciones en ambos sentidos
+	toSend := CompararArchivos(fromFileMap(local), fromFileMap(remote))
+	toGet := CompararArchivos(fromFileMap(remote), fromFileMap(local))
+
+	for _, f := range toSend {
+		if err := sendSingleFile(p, filepath.Join("shared", f.Name), f.Name); err != nil {
+			fmt.Printf("❌ No se pudo enviar %s a Maq%d: %v\n", f.Name, p.ID, err)
+			failed[f.Name] = true
+			continue
+		}
+		remote[f.Name] = f
+	}
+	for _, f := range toGet {
+		if err := RequestFileFromPeer(p, f.Name, false); err != nil {
+			fmt.Printf("❌ No se pudo obtener %s de Maq%d: %v\n", f.Name, p.ID, err)
+			failed[f.Name] = true
+			continue
+		}
+		local[f.Name] = f
+	}
+
+	// 4. La nueva base son las rutas que quedaron en ambos lados
+	newBase := make(map[string]time.Time)
+	for name, lf := range local {
+		rf, ok := remote[name]
+		if !ok || failed[name] {
+			if old, had := base[name]; had {
+				newBase[name] = old
+			}
+			continue
+		}
+		mod := lf.ModTime
+		if rf.ModTime.After(mod) {
+			mod = rf.ModTime
+		}
+		newBase[name] = mod
+	}
+	s.base[p.ID] = newBase
+	return nil
+}
+
+// propagateRenames detecta rutas de la base que desaparecieron de un lado mientras
+// aparecía, en ese mismo lado, un archivo nuevo con el mismo contenido. El otro
+// lado recibe un renombre, así el contenido no se vuelve a transferir. Actualiza
+// local, remote y base para que los pasos siguientes vean ambos lados iguales.
+func (s *FolderSync) propagateRenames(p peer.PeerInfo, local, remote map[string]state.FileInfo, base map[string]time.Time) {
+	localID := s.system.Local.ID
+	for name, baseMod := range base {
+		lf, inL := local[name]
+		rf, inR := remote[name]
+		switch {
+		case !inL && inR && rf.ModTime.Equal(baseMod):
+			// Se renombró aquí: se repite en el peer
+			newName, ok := renamedCopy(rf, local, remote, base)
+			if !ok {
+				continue
+			}
+			err := sendRenameRequest(p, name, newName)
+			audit.RecordRename(name, newName, localID, p.ID, err)
+			if err != nil {
+				fmt.Printf("⚠️ No se pudo renombrar %s en Maq%d: %v\n", name, p.ID, err)
+				continue
+			}
+			fmt.Printf("🔁 Renombrado en Maq%d por sincronización: %s → %s\n", p.ID, name, newName)
+			rf.Name = newName
+			remote[newName] = rf
+			delete(remote, name)
+		case inL && !inR && lf.ModTime.Equal(baseMod):
+			// Se renombró en el peer: se repite aquí
+			newName, ok := renamedCopy(lf, remote, local, base)
+			if !ok {
+				continue
+			}
+			err := peer.RenameShared(name, newName)
+			audit.RecordRename(name, newName, p.ID, localID, err)
+			if err != nil {
+				fmt.Printf("⚠️ No se pudo renombrar %s: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("🔁 Renombrado por sincronización: %s → %s\n", name, newName)
+			lf.Name = newName
+			local[newName] = lf
+			delete(local, name)
+		default:
+			continue
+		}
+		delete(base, name)
+	}
+}
+
+// renamedCopy busca en side (el lado donde desapareció old) un archivo nuevo,
+// ausente en other y en la base, con el mismo contenido que old
+func renamedCopy(old state.FileInfo, side, other map[string]state.FileInfo, base map[string]time.Time) (string, bool) {
+	for name, f := range side {
+		if _, known := base[name]; known {
+			continue
+		}
+		if _, exists := other[name]; exists {
+			continue
+		}
+		// Un renombre conserva la fecha de modificación además del contenido
+		if !f.IsDir && f.ModTime.Equal(old.ModTime) && sameContent(old, f) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (s *FolderSync) includesPeer(id int) bool {
+	for _, pid := range s.cfg.Peers {
+		if pid == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FolderSync) scanLocal() []state.FileInfo {
+	return s.filterFolder(ListSharedFiles())
+}
+
+// filterFolder deja solo los archivos (no carpetas) dentro de la carpeta
+// sincronizada. Las copias de conflicto no se sincronizan: son del nodo que
+// detectó el conflicto hasta que se resuelva.
+func (s *FolderSync) filterFolder(files []state.FileInfo) []state.FileInfo {
+	var result []state.FileInfo
+	for _, f := range files {
+		name := filepath.ToSlash(f.Name)
+		if f.IsDir || isInternalFile(name) || isConflictCopy(name) {
+			continue
+		}
+		if s.cfg.Folder != "." && !strings.HasPrefix(name, s.cfg.Folder+"/") {
+			continue
+		}
+		f.Name = name
+		result = append(result, f)
+	}
+	return result
+}
+
+// isInternalFile indica si la ruta pertenece a los archivos de control del nodo;
+// los nodos con versiones anteriores todavía guardan el log en shared
+func isInternalFile(name string) bool {
+	switch name {
+	case "log.json", "log.snapshot.json", "log.acks.json":
+		return true
+	}
+	return false
+}
+
+func toFileMap(files []state.FileInfo) map[string]state.FileInfo {
+	m := make(map[string]state.FileInfo, len(files))
+	for _, f := range files {
+		m[f.Name] = f
+	}
+	return m
+}
+
+func fromFileMap(m map[string]state.FileInfo) []state.FileInfo {
+	files := make([]state.FileInfo, 0, len(m))
+	for _, f := range m {
+		files = append(files, f)
+	}
+	return files
+}
+
+func loadFolderSyncState() map[int]map[string]time.Time {
+	base := make(map[int]map[string]time.Time)
+	data, err := os.ReadFile(folderSyncStateFile)
+	if err == nil {
+		_ = json.Unmarshal(data, &base)
+	}
+	return base
+}
+
+func saveFolderSyncState(base map[int]map[string]time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(folderSyncStateFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(base, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(folderSyncStateFile, data, 0644)
+}
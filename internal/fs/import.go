@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/peer"
+)
+
+// ImportPaths copia archivos o carpetas externos (por ejemplo, soltados desde el
+// administrador de archivos) a la raíz de shared y devuelve sus nombres dentro
+// de shared. Si ya existe algo con el mismo nombre se agrega un sufijo numérico.
+func ImportPaths(paths []string) ([]string, error) {
+	var imported []string
+	var errs []string
+	for _, src := range paths {
+		src = filepath.Clean(src)
+		if abs, err := filepath.Abs("shared"); err == nil && (src == abs || strings.HasPrefix(src, abs+string(filepath.Separator))) {
+			// Ya está dentro de shared: no hace falta copiarlo
+			if rel, err := filepath.Rel(abs, src); err == nil {
+				imported = append(imported, filepath.ToSlash(rel))
+			}
+			continue
+		}
+		name := freeName(filepath.Base(src))
+		if err := peer.CopyTree(src, filepath.Join("shared", name)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", src, err))
+			continue
+		}
+		audit.RecordOp("CREATE", name, peer.Local.ID, peer.Local.ID, 0, "", nil)
+		fmt.Println("📥 Importado a shared:", name)
+		imported = append(imported, name)
+	}
+	if len(errs) > 0 {
+		return imported, fmt.Errorf("no se pudo importar: %s", strings.Join(errs, "; "))
+	}
+	return imported, nil
+}
+
+// freeName devuelve name, o "name (n).ext" si ya existe en shared
+func freeName(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(filepath.Join("shared", candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
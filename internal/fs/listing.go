@@ -0,0 +1,174 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// peerListing es la copia local del listado de un peer y la versión de su diario
+type peerListing struct {
+	epoch   int64
+	version int64
+	files   map[string]state.FileInfo
+	// legacyUntil marca que el peer no entendió GET_CHANGES: hasta esa fecha se
+	// usa GET_FILES y después se vuelve a probar, por si se actualizó o el
+	// cierre de la conexión fue un corte pasajero
+	legacyUntil time.Time
+}
+
+var (
+	listingMutex sync.Mutex
+	listings     = make(map[int]*peerListing)
+)
+
+// listDirPageSize es la cantidad de entradas pedidas por página en LIST_DIR
+const listDirPageSize = 500
+
+// legacyRetry es cuánto se espera antes de volver a probar GET_CHANGES
+const legacyRetry = 5 * time.Minute
+
+// fetchPeerListing devuelve el listado completo de un peer pidiendo solo los
+// cambios desde la última versión conocida. Con peers que no soportan el
+// protocolo incremental vuelve a GET_FILES.
+func fetchPeerListing(p peer.PeerInfo) ([]state.FileInfo, error) {
+	listingMutex.Lock()
+	l := listings[p.ID]
+	if l == nil {
+		l = &peerListing{files: make(map[string]state.FileInfo)}
+		listings[p.ID] = l
+	}
+	legacy, epoch, version := time.Now().Before(l.legacyUntil), l.epoch, l.version
+	listingMutex.Unlock()
+
+	address := net.JoinHostPort(p.IP, p.Port)
+	if legacy {
+		return requestFileListFromPeer(address)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := map[string]interface{}{
+		"type":  "GET_CHANGES",
+		"epoch": epoch,
+		"since": version,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var resp struct {
+		Type    string                `json:"type"`
+		Epoch   int64                 `json:"epoch"`
+		Version int64                 `json:"version"`
+		Full    bool                  `json:"full"`
+		Files   []state.FileInfo      `json:"files"`
+		Changes []state.ListingChange `json:"changes"`
+	}
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == io.EOF || resp.Type != "FILES_CHANGES" {
+		// Un peer antiguo cierra la conexión sin responder a un tipo desconocido
+		fmt.Printf("ℹ️ Maq%d no respondió a GET_CHANGES, se usa GET_FILES por %v\n", p.ID, legacyRetry)
+		listingMutex.Lock()
+		l.legacyUntil = time.Now().Add(legacyRetry)
+		listingMutex.Unlock()
+		return requestFileListFromPeer(address)
+	}
+
+	listingMutex.Lock()
+	defer listingMutex.Unlock()
+	if resp.Full {
+		l.files = make(map[string]state.FileInfo, len(resp.Files))
+		for _, f := range resp.Files {
+			l.files[f.Name] = f
+		}
+	}
+	for _, c := range resp.Changes {
+		if c.Deleted || c.Info == nil {
+			delete(l.files, c.Name)
+		} else {
+			l.files[c.Name] = *c.Info
+		}
+	}
+	l.epoch = resp.Epoch
+	l.version = resp.Version
+
+	files := make([]state.FileInfo, 0, len(l.files))
+	for _, f := range l.files {
+		files = append(files, f)
+	}
+	sortByPath(files)
+	return files, nil
+}
+
+// requestDirListing pide el contenido de una carpeta remota por páginas
+func requestDirListing(p peer.PeerInfo, dir string, recursive bool) ([]state.FileInfo, error) {
+	var result []state.FileInfo
+	offset := 0
+	for {
+		page, total, next, err := requestDirPage(p, dir, recursive, offset)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if next >= total || next <= offset {
+			return result, nil
+		}
+		offset = next
+	}
+}
+
+func requestDirPage(p peer.PeerInfo, dir string, recursive bool, offset int) ([]state.FileInfo, int, int, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 2*time.Second)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer conn.Close()
+
+	req := map[string]interface{}{
+		"type":      "LIST_DIR",
+		"path":      dir,
+		"recursive": recursive,
+		"offset":    offset,
+		"limit":     listDirPageSize,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, 0, 0, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var resp struct {
+		Type  string           `json:"type"`
+		Files []state.FileInfo `json:"files"`
+		Total int              `json:"total"`
+		Next  int              `json:"next"`
+		Error string           `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if err == io.EOF {
+			return nil, 0, 0, errUnsupported
+		}
+		return nil, 0, 0, err
+	}
+	if resp.Type != "DIR_LIST" {
+		return nil, 0, 0, fmt.Errorf("respuesta inesperada: %v %s", resp.Type, resp.Error)
+	}
+	return resp.Files, resp.Total, resp.Next, nil
+}
+
+// errUnsupported indica que el peer no entendió el mensaje (versión anterior del protocolo)
+var errUnsupported = fmt.Errorf("el peer no soporta esta operación")
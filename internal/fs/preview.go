@@ -0,0 +1,180 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"p2pfs/internal/compress"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// Límites de la vista previa: los textos se cortan y las imágenes solo se
+// muestran si caben en una sola lectura por rango
+const (
+	PreviewTextBytes  = 16 * 1024
+	PreviewImageBytes = 8 * 1024 * 1024
+)
+
+// Preview es lo que se muestra de un archivo sin copiarlo a shared
+type Preview struct {
+	PeerID    int
+	Info      state.FileInfo
+	Kind      string // "text", "image" o "" si solo hay metadatos
+	Content   []byte
+	Truncated bool
+}
+
+// PreviewFile lee el comienzo de un archivo local o remoto. Los remotos se piden
+// con GET_RANGE, así que no se descargan completos ni se escriben en disco.
+func PreviewFile(peerSystem *peer.Peer, peerID int, filename string) (Preview, error) {
+	filename = filepath.ToSlash(filename)
+	pv := Preview{PeerID: peerID, Info: cachedInfo(peerSystem, peerID, filename)}
+	if pv.Info.IsDir {
+		return pv, nil
+	}
+
+	kind := state.FileKind(filename)
+	limit := int64(PreviewTextBytes)
+	if kind == "image" {
+		if pv.Info.Size > PreviewImageBytes {
+			// Una miniatura necesita la imagen completa; las grandes quedan en metadatos
+			return pv, nil
+		}
+		limit = PreviewImageBytes
+	}
+
+	var err error
+	if peerID == peerSystem.Local.ID {
+		err = pv.readLocal(filename, limit)
+	} else {
+		p, ok := findPeer(peerID)
+		if !ok {
+			return pv, fmt.Errorf("máquina %d desconocida", peerID)
+		}
+		err = pv.readRemote(p, filename, limit)
+	}
+	if err != nil {
+		return pv, err
+	}
+
+	switch {
+	case kind == "image":
+		pv.Kind = "image"
+	case kind == "text" || looksLikeText(pv.Content):
+		pv.Kind = "text"
+		pv.Content = trimPartialRune(pv.Content)
+	default:
+		pv.Content = nil
+	}
+	return pv, nil
+}
+
+// cachedInfo busca los metadatos en el listado conocido; el rango los completa después
+func cachedInfo(peerSystem *peer.Peer, peerID int, filename string) state.FileInfo {
+	files := ListSharedFiles()
+	if peerID != peerSystem.Local.ID {
+		files = nil
+		if p, ok := findPeer(peerID); ok {
+			files = state.CachedFiles(p.IP)
+		}
+	}
+	for _, f := range files {
+		if f.Name == filename {
+			return f
+		}
+	}
+	return state.FileInfo{Name: filename, MimeType: state.MimeTypeOf(filename)}
+}
+
+func (pv *Preview) readLocal(filename string, limit int64) error {
+	fullPath := filepath.Join("shared", filepath.FromSlash(filename))
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir %s: %w", filename, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if pv.Info.ModTime.IsZero() {
+		pv.Info = state.NewFileInfo(filename, info)
+	}
+	pv.Content, err = io.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return fmt.Errorf("error al leer %s: %w", filename, err)
+	}
+	pv.Truncated = info.Size() > int64(len(pv.Content))
+	return nil
+}
+
+func (pv *Preview) readRemote(p peer.PeerInfo, filename string, limit int64) error {
+	var resp struct {
+		Type     string    `json:"type"`
+		Content  string    `json:"content"`
+		Encoding string    `json:"encoding"`
+		Size     int64     `json:"size"`
+		ModTime  time.Time `json:"modTime"`
+		Error    string    `json:"error"`
+	}
+	err := chunkRequest(p, nil, map[string]interface{}{
+		"type":           "GET_RANGE",
+		"name":           filename,
+		"offset":         0,
+		"length":         limit,
+		"acceptEncoding": compress.Supported,
+	}, &resp)
+	if err == errUnsupported {
+		return fmt.Errorf("Maq%d no admite vista previa remota", p.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Type != "RANGE" {
+		return fmt.Errorf("vista previa rechazada: %s", resp.Error)
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Content)
+	if err == nil {
+		raw, err = compress.Decode(resp.Encoding, raw)
+	}
+	if err != nil {
+		return fmt.Errorf("error al decodificar vista previa: %w", err)
+	}
+
+	pv.Content = raw
+	pv.Info.Size = resp.Size
+	pv.Info.ModTime = resp.ModTime
+	pv.Truncated = resp.Size > int64(len(raw))
+	return nil
+}
+
+// looksLikeText reconoce texto sin extensión conocida (código, configuraciones...)
+func looksLikeText(data []byte) bool {
+	if len(data) == 0 || bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+	if ct := http.DetectContentType(data); len(ct) >= 5 && ct[:5] == "text/" {
+		return true
+	}
+	return utf8.Valid(trimPartialRune(data))
+}
+
+// trimPartialRune quita el carácter UTF-8 que el corte pudo dejar a medias
+func trimPartialRune(data []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		r, size := utf8.DecodeLastRune(data)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	return data
+}
@@ -0,0 +1,225 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/transfer"
+)
+
+// Init registra los mensajes que atiende fs del lado servidor y crea el
+// planificador de transferencias
+func Init() {
+	if Transfers != nil {
+		Transfers.Close()
+	}
+	Transfers = NewScheduler(defaultTransferConfig())
+	peer.RegisterHandler("PUSH", handlePush)
+	peer.RegisterHandler("FILE_OP", handleFileOp)
+}
+
+// pushResult es el resultado de enviar un archivo a un destino por pedido de otro nodo
+type pushResult struct {
+	Target int    `json:"target"`
+	OK     bool   `json:"ok"`
+	Bytes  int64  `json:"bytes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handlePush atiende PUSH {name, targets}: el nodo que coordina un relay le pide a
+// la fuente que envíe el archivo directamente a cada destino. Se informa el avance
+// con un PUSH_PROGRESS por cada cambio de estado y al final un PUSH_DONE. Mientras
+// dura el envío se manda un PUSH_ALIVE cada pushKeepAlive para que quien coordina
+// distinga una transferencia larga de una fuente colgada.
+func handlePush(conn net.Conn, request map[string]interface{}) {
+	enc := json.NewEncoder(conn)
+	reply := func(msg map[string]interface{}) {
+		_ = enc.Encode(msg)
+	}
+	if peer.PeerIDByAddr(conn.RemoteAddr()) == 0 {
+		reply(map[string]interface{}{"type": "ERROR", "error": "Solo un peer conocido puede pedir un relay"})
+		return
+	}
+
+	name, _ := request["name"].(string)
+	if name == "" || filepath.IsAbs(name) || strings.Contains(name, "..") {
+		reply(map[string]interface{}{"type": "ERROR", "error": "Nombre inválido"})
+		return
+	}
+	fullPath := filepath.Join("shared", filepath.Clean(name))
+	info, err := os.Stat(fullPath)
+	if err == nil && info.IsDir() {
+		err = fmt.Errorf("es una carpeta")
+	}
+	var data []byte
+	if err == nil {
+		data, err = os.ReadFile(fullPath)
+	}
+	if err != nil {
+		reply(map[string]interface{}{"type": "ERROR", "error": "Archivo no accesible: " + err.Error()})
+		return
+	}
+	chunk.Local.AddData(filepath.Clean(name), data)
+	version := localVersion(fullPath, info.ModTime())
+	hash := audit.HashBytes(data)
+
+	// Los destinos se buscan por ID en la configuración local: no se envía a direcciones arbitrarias
+	rawTargets, _ := request["targets"].([]interface{})
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]pushResult, len(rawTargets))
+	for i, raw := range rawTargets {
+		id, _ := raw.(float64)
+		wg.Add(1)
+		go func(i, id int) {
+			defer wg.Done()
+			result := pushResult{Target: id}
+			target, ok := findPeer(id)
+			if !ok {
+				result.Error = "peer desconocido"
+			} else {
+				mutex.Lock()
+				reply(map[string]interface{}{"type": "PUSH_PROGRESS", "target": id, "state": "sending", "bytes": len(data)})
+				mutex.Unlock()
+				h := transfer.Start(transfer.KindSend, id, name, int64(len(data)))
+				err := pushContent(target, h, name, data, info.ModTime(), version)
+				err = finishSend(h, "TRANSFER", name, peer.Local.ID, id, int64(len(data)), hash, err)
+				if err != nil {
+					result.Error = err.Error()
+				} else {
+					result.OK = true
+					result.Bytes = int64(len(data))
+				}
+			}
+
+			progress := map[string]interface{}{"type": "PUSH_PROGRESS", "target": id, "state": "done", "bytes": result.Bytes}
+			if !result.OK {
+				progress["state"] = "failed"
+				progress["error"] = result.Error
+			}
+			mutex.Lock()
+			results[i] = result
+			reply(progress)
+			mutex.Unlock()
+		}(i, int(id))
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	ticker := time.NewTicker(pushKeepAlive)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-finished:
+			waiting = false
+		case <-ticker.C:
+			mutex.Lock()
+			reply(map[string]interface{}{"type": "PUSH_ALIVE"})
+			mutex.Unlock()
+		}
+	}
+
+	reply(map[string]interface{}{
+		"type":    "PUSH_DONE",
+		"name":    name,
+		"hash":    hash,
+		"results": results,
+	})
+}
+
+const (
+	pushKeepAlive   = 30 * time.Second
+	pushIdleTimeout = 2 * time.Minute
+)
+
+// requestPush le pide a source que envíe filename directamente a los destinos y
+// devuelve el resultado por destino; el avance se refleja en handles, indexado por
+// ID de destino. Devuelve errUnsupported si source es una versión anterior que no conoce PUSH.
+func requestPush(source peer.PeerInfo, filename string, handles map[int]*transfer.Handle) (map[int]pushResult, string, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(source.IP, source.Port), 5*time.Second)
+	if err != nil {
+		return nil, "", fmt.Errorf("no se pudo conectar al peer fuente %s: %w", source.IP, err)
+	}
+	defer conn.Close()
+
+	ids := make([]int, 0, len(handles))
+	for id := range handles {
+		ids = append(ids, id)
+	}
+	req := map[string]interface{}{
+		"type":    "PUSH",
+		"name":    filepath.ToSlash(filename),
+		"targets": ids,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, "", fmt.Errorf("no se pudo enviar solicitud: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for first := true; ; first = false {
+		// La fuente manda algo al menos cada pushKeepAlive; si calla más que
+		// pushIdleTimeout se la da por colgada y se libera al trabajador
+		_ = conn.SetReadDeadline(time.Now().Add(pushIdleTimeout))
+		var msg struct {
+			Type    string       `json:"type"`
+			Target  int          `json:"target"`
+			State   string       `json:"state"`
+			Bytes   int64        `json:"bytes"`
+			Hash    string       `json:"hash"`
+			Results []pushResult `json:"results"`
+			Error   string       `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF && first {
+				return nil, "", errUnsupported
+			}
+			return nil, "", fmt.Errorf("la fuente cortó la conexión: %w", err)
+		}
+
+		switch msg.Type {
+		case "PUSH_ALIVE":
+		case "PUSH_PROGRESS":
+			switch msg.State {
+			case "sending":
+				handles[msg.Target].SetTotal(msg.Bytes)
+				fmt.Printf("📤 Relay %s: Maq%d → Maq%d (%d bytes)...\n", filename, source.ID, msg.Target, msg.Bytes)
+			case "done":
+				fmt.Printf("✅ Relay %s: Maq%d → Maq%d completado\n", filename, source.ID, msg.Target)
+			default:
+				fmt.Printf("⚠️ Relay %s: Maq%d → Maq%d falló: %s\n", filename, source.ID, msg.Target, msg.Error)
+			}
+		case "PUSH_DONE":
+			results := make(map[int]pushResult, len(msg.Results))
+			for _, r := range msg.Results {
+				results[r.Target] = r
+			}
+			return results, msg.Hash, nil
+		case "ERROR":
+			return nil, "", fmt.Errorf("la fuente rechazó el relay: %s", msg.Error)
+		default:
+			return nil, "", fmt.Errorf("respuesta inesperada de la fuente: %v", msg.Type)
+		}
+	}
+}
+
+// findPeer busca un peer configurado por su ID
+func findPeer(id int) (peer.PeerInfo, bool) {
+	for _, p := range peer.GetPeers() {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return peer.PeerInfo{}, false
+}
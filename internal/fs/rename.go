@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// RenameFile renombra o mueve un archivo o carpeta de cualquier peer. newName es
+// la ruta nueva completa dentro de shared. En un peer remoto solo viaja la orden,
+// nunca el contenido; si está desconectado queda como operación pendiente.
+func RenameFile(peerSystem *peer.Peer, selected SelectedFile, newName string) error {
+	oldName := filepath.ToSlash(filepath.Clean(selected.FileName))
+	newName = filepath.ToSlash(filepath.Clean(strings.TrimSpace(newName)))
+	if newName == "" || newName == "." {
+		return fmt.Errorf("el nombre nuevo está vacío")
+	}
+	if newName == oldName {
+		return nil
+	}
+	localID := peerSystem.Local.ID
+
+	if selected.PeerID == localID {
+		err := peer.RenameShared(oldName, newName)
+		audit.RecordRename(oldName, newName, localID, localID, err)
+		return err
+	}
+
+	target, ok := findPeer(selected.PeerID)
+	if !ok {
+		return fmt.Errorf("peer no encontrado")
+	}
+
+	if !state.IsOnline(target.IP) {
+		// 🔴 Nodo desconectado → renombre diferido; la operación pendiente se
+		// repite al reconectarse y el listado conocido se actualiza ya para que
+		// la GUI muestre el nombre nuevo
+		state.RenameInCache(target.IP, oldName, newName)
+		state.AddPendingOp(target.ID, state.PendingOperation{
+			Type:     "rename",
+			FilePath: oldName,
+			NewPath:  newName,
+			TargetID: target.ID,
+			SourceID: localID,
+		})
+		audit.RecordPendingPaths("RENAME", oldName, newName, localID, target.ID)
+		return fmt.Errorf("nodo desconectado, renombre registrado como pendiente")
+	}
+
+	err := sendRenameRequest(target, oldName, newName)
+	audit.RecordRename(oldName, newName, localID, target.ID, err)
+	if err != nil {
+		return err
+	}
+	state.RenameInCache(target.IP, oldName, newName)
+	peer.SendSyncRename(oldName, newName, localID, target.ID)
+	return nil
+}
+
+// RenamedPath interpreta lo que escribió el usuario para name: un nombre simple
+// lo renombra en su carpeta y un texto con / es la ruta completa en shared (mover)
+func RenamedPath(name, input string) string {
+	input = strings.TrimSpace(filepath.ToSlash(input))
+	if strings.Contains(input, "/") {
+		return strings.TrimPrefix(path.Clean(input), "/")
+	}
+	dir := path.Dir(filepath.ToSlash(name))
+	if dir == "." {
+		return input
+	}
+	return dir + "/" + input
+}
+
+// sendRenameRequest envía RENAME_FILE a un nodo remoto y espera su confirmación
+func sendRenameRequest(p peer.PeerInfo, oldName, newName string) error {
+	err := sendFileOp(p, map[string]string{"type": "RENAME_FILE", "name": oldName, "newName": newName})
+	if err == errUnsupported {
+		return fmt.Errorf("Maq%d no admite renombrar: %w", p.ID, err)
+	}
+	return err
+}
@@ -0,0 +1,251 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"p2pfs/internal/throttle"
+	"p2pfs/internal/transfer"
+)
+
+var transferConfigFile = filepath.Join("config", "transfer.json")
+
+// TransferConfig limita cuántas transferencias corren a la vez
+type TransferConfig struct {
+	MaxWorkers int `json:"max_workers"` // En total
+	PerPeer    int `json:"per_peer"`    // Por cada peer remoto
+	MaxQueued  int `json:"max_queued"`  // Trabajos en espera por clase antes de frenar a quien encola
+}
+
+// LoadTransferConfig lee config/transfer.json; sin archivo se usan valores por defecto
+func LoadTransferConfig() (TransferConfig, error) {
+	cfg := TransferConfig{MaxWorkers: 8, PerPeer: 3, MaxQueued: 256}
+	data, err := os.ReadFile(transferConfigFile)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error al decodificar %s: %w", transferConfigFile, err)
+	}
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = 8
+	}
+	if cfg.PerPeer <= 0 || cfg.PerPeer > cfg.MaxWorkers {
+		cfg.PerPeer = cfg.MaxWorkers
+	}
+	if cfg.MaxQueued <= 0 {
+		cfg.MaxQueued = 256
+	}
+	return cfg, nil
+}
+
+// transferJob es una transferencia de un archivo esperando un trabajador
+type transferJob struct {
+	class  string // throttle.ClassBulk o throttle.ClassPending
+	peerID int
+	name   string
+	run    func() error
+	done   chan error
+	queued *transfer.Handle // Entrada "en cola" en el administrador de transferencias
+}
+
+// Scheduler reparte las transferencias entre un número limitado de trabajadores.
+// Cada peer tiene su cola y se atienden por turnos, así una carpeta grande hacia
+// un peer no bloquea lo que se envía a los demás. Los trabajos de una clase con
+// ventanas horarias esperan en la cola hasta que la ventana se abra, sin frenar
+// a los de otras clases que tienen detrás.
+type Scheduler struct {
+	mutex   sync.Mutex
+	space   *sync.Cond // avisa cuando se libera lugar en las colas
+	cfg     TransferConfig
+	queues  map[int][]*transferJob
+	order   []int // peers con trabajos en cola, en orden de turno
+	active  map[int]int
+	running int
+	queued  map[string]int // trabajos en espera por clase
+
+	stop      chan struct{} // cierra watchWindows
+	closeOnce sync.Once
+}
+
+// Transfers es el planificador usado por las transferencias de carpetas y los
+// relays; lo crea Init con la configuración de config/transfer.json
+var Transfers *Scheduler
+
+func defaultTransferConfig() TransferConfig {
+	cfg, err := LoadTransferConfig()
+	if err != nil {
+		fmt.Println("⚠️ Configuración de transferencias inválida, se usan valores por defecto:", err)
+	}
+	return cfg
+}
+
+// NewScheduler crea un planificador con los límites indicados
+func NewScheduler(cfg TransferConfig) *Scheduler {
+	s := &Scheduler{
+		cfg:    cfg,
+		queues: make(map[int][]*transferJob),
+		active: make(map[int]int),
+		queued: make(map[string]int),
+		stop:   make(chan struct{}),
+	}
+	s.space = sync.NewCond(&s.mutex)
+	go s.watchWindows(30 * time.Second)
+	return s
+}
+
+// watchWindows reintenta arrancar trabajos periódicamente por si se abrió una ventana horaria
+func (s *Scheduler) watchWindows(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.dispatchLocked()
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Close detiene la revisión periódica de ventanas horarias. Los trabajos en
+// curso terminan normalmente; los que esperan una ventana ya no se revisan.
+func (s *Scheduler) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+}
+
+// Submit encola una transferencia hacia o desde peerID. Si ya hay demasiados
+// trabajos de la misma clase en espera, bloquea hasta que se libere lugar. El
+// canal devuelto recibe el resultado.
+func (s *Scheduler) Submit(class string, peerID int, name string, run func() error) <-chan error {
+	job := &transferJob{class: class, peerID: peerID, name: name, run: run, done: make(chan error, 1)}
+	job.queued = transfer.Enqueue(peerID, name)
+
+	s.mutex.Lock()
+	for s.queued[class] >= s.cfg.MaxQueued {
+		s.space.Wait()
+	}
+	if len(s.queues[peerID]) == 0 {
+		s.order = append(s.order, peerID)
+	}
+	s.queues[peerID] = append(s.queues[peerID], job)
+	s.queued[class]++
+	s.dispatchLocked()
+	s.mutex.Unlock()
+	return job.done
+}
+
+// Batch agrupa trabajos para esperarlos juntos
+type Batch struct {
+	s       *Scheduler
+	class   string
+	results []<-chan error
+}
+
+// NewBatch crea un grupo de trabajos de la clase indicada
+func (s *Scheduler) NewBatch(class string) *Batch {
+	return &Batch{s: s, class: class}
+}
+
+// Add encola un trabajo del grupo
+func (b *Batch) Add(peerID int, name string, run func() error) {
+	b.results = append(b.results, b.s.Submit(b.class, peerID, name, run))
+}
+
+// Wait espera todos los trabajos del grupo y devuelve sus errores combinados
+func (b *Batch) Wait() error {
+	var errs []error
+	for _, ch := range b.results {
+		if err := <-ch; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dispatchLocked arranca trabajos mientras haya trabajadores libres, tomando
+// uno de cada peer por turno: el primero de su cola cuya clase pueda correr
+// ahora. Se asume que mutex está tomado.
+func (s *Scheduler) dispatchLocked() {
+	now := time.Now()
+	allowed := make(map[string]bool)
+	canRun := func(class string) bool {
+		ok, seen := allowed[class]
+		if !seen {
+			ok = throttle.Allowed(class, now)
+			allowed[class] = ok
+		}
+		return ok
+	}
+
+	for s.running < s.cfg.MaxWorkers && len(s.order) > 0 {
+		started := false
+		for i := 0; i < len(s.order) && s.running < s.cfg.MaxWorkers; i++ {
+			peerID := s.order[0]
+			s.order = s.order[1:]
+			queue := s.queues[peerID]
+			next := -1
+			if s.active[peerID] < s.cfg.PerPeer {
+				for j, job := range queue {
+					if canRun(job.class) {
+						next = j
+						break
+					}
+				}
+			}
+			if next < 0 {
+				s.order = append(s.order, peerID)
+				continue
+			}
+
+			job := queue[next]
+			if len(queue) > 1 {
+				s.queues[peerID] = append(queue[:next:next], queue[next+1:]...)
+				s.order = append(s.order, peerID)
+			} else {
+				delete(s.queues, peerID)
+			}
+			s.queued[job.class]--
+			s.active[peerID]++
+			s.running++
+			started = true
+			go s.work(job)
+		}
+		if !started {
+			break
+		}
+	}
+	s.space.Broadcast()
+}
+
+func (s *Scheduler) work(job *transferJob) {
+	var err error
+	if job.queued.Canceled() {
+		// Cancelado mientras esperaba: queda en la lista como cancelado
+		err = transfer.ErrCanceled
+	} else {
+		// Al arrancar, el trabajo registra su propia transferencia con el avance real
+		job.queued.Discard()
+		err = job.run()
+	}
+	if err != nil && err != transfer.ErrCanceled {
+		fmt.Printf("⚠️ Error al transferir %s: %v\n", job.name, err)
+	}
+	job.done <- err
+
+	s.mutex.Lock()
+	s.active[job.peerID]--
+	s.running--
+	s.dispatchLocked()
+	s.mutex.Unlock()
+}
@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"p2pfs/internal/throttle"
+)
+
+func waitResult(t *testing.T, ch <-chan error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("el trabajo no terminó")
+	}
+}
+
+func TestSchedulerRoundRobin(t *testing.T) {
+	tests := []struct {
+		name    string
+		submits []int // peer de cada trabajo, en orden de llegada
+		want    []int // orden en que deben correr con un solo trabajador
+	}{
+		{"un peer", []int{1, 1, 1}, []int{1, 1, 1}},
+		{"dos peers", []int{1, 1, 1, 2, 2}, []int{1, 2, 1, 2, 1}},
+		{"tres peers", []int{1, 1, 1, 2, 3, 3}, []int{1, 2, 3, 1, 3, 1}},
+		{"llegadas intercaladas", []int{2, 1, 2, 2, 1}, []int{2, 1, 2, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(TransferConfig{MaxWorkers: 1, PerPeer: 1, MaxQueued: 64})
+			t.Cleanup(s.Close)
+
+			// Un trabajo que ocupa al único trabajador mientras se encola el resto
+			release := make(chan struct{})
+			blocker := s.Submit(throttle.ClassBulk, 99, "bloqueo", func() error {
+				<-release
+				return nil
+			})
+
+			var mutex sync.Mutex
+			var ran []int
+			var results []<-chan error
+			for _, peerID := range tt.submits {
+				peerID := peerID
+				results = append(results, s.Submit(throttle.ClassBulk, peerID, "archivo", func() error {
+					mutex.Lock()
+					ran = append(ran, peerID)
+					mutex.Unlock()
+					return nil
+				}))
+			}
+			close(release)
+			waitResult(t, blocker)
+			for _, ch := range results {
+				waitResult(t, ch)
+			}
+
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("orden = %v, quería %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerPerPeerLimit(t *testing.T) {
+	s := NewScheduler(TransferConfig{MaxWorkers: 3, PerPeer: 2, MaxQueued: 64})
+	t.Cleanup(s.Close)
+
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	batch := s.NewBatch(throttle.ClassBulk)
+	for i := 0; i < 4; i++ {
+		batch.Add(1, "grande", func() error {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no arrancaron los trabajos hacia el peer 1")
+		}
+	}
+
+	// Con el peer 1 en su límite, el trabajador libre atiende al peer 2
+	waitResult(t, s.Submit(throttle.ClassBulk, 2, "chico", func() error { return nil }))
+	select {
+	case <-started:
+		t.Error("corrió un tercer trabajo hacia el peer 1 pese al límite por peer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := batch.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// closePendingWindow configura una ventana para las operaciones pendientes que
+// no incluye la hora actual
+func closePendingWindow(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	window := throttle.Window{
+		From:  now.Add(2 * time.Hour).Format("15:04"),
+		To:    now.Add(3 * time.Hour).Format("15:04"),
+		Class: throttle.ClassPending,
+	}
+	if err := throttle.Apply(throttle.Config{Windows: []throttle.Window{window}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = throttle.Apply(throttle.Config{})
+		_ = os.Chdir(wd)
+	})
+}
+
+func TestSchedulerClosedWindowDoesNotBlock(t *testing.T) {
+	closePendingWindow(t)
+	s := NewScheduler(TransferConfig{MaxWorkers: 1, PerPeer: 1, MaxQueued: 2})
+	t.Cleanup(s.Close)
+
+	// Las pendientes llenan su cola y esperan a que abra la ventana
+	ran := make(chan string, 4)
+	for i := 0; i < 2; i++ {
+		s.Submit(throttle.ClassPending, 1, "pendiente", func() error {
+			ran <- "pendiente"
+			return nil
+		})
+	}
+
+	// Lo que pide el usuario detrás de ellas, hacia el mismo peer, corre igual
+	// y encolarlo no se bloquea aunque la cola de pendientes esté llena
+	submitted := make(chan (<-chan error), 1)
+	go func() {
+		submitted <- s.Submit(throttle.ClassBulk, 1, "del usuario", func() error {
+			ran <- "del usuario"
+			return nil
+		})
+	}()
+	select {
+	case done := <-submitted:
+		waitResult(t, done)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Submit quedó bloqueado por la cola de pendientes")
+	}
+	if got := <-ran; got != "del usuario" {
+		t.Errorf("corrió %q con la ventana cerrada", got)
+	}
+	select {
+	case got := <-ran:
+		t.Errorf("corrió %q con la ventana cerrada", got)
+	default:
+	}
+}
@@ -0,0 +1,240 @@
+package fs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// SearchQuery filtra archivos de todos los peers. Los campos vacíos no filtran.
+type SearchQuery struct {
+	Pattern     string // Comodines (*.pdf, informe-??.txt) o expresión regular si Regex
+	Regex       bool
+	Ext         string // Extensión, con o sin punto
+	MinSize     int64
+	MaxSize     int64 // 0 = sin máximo
+	Since       time.Time
+	Until       time.Time
+	IncludeDirs bool
+}
+
+// Estado de las copias de un resultado
+const (
+	CopiesSingle     = "única"
+	CopiesIdentical  = "idénticas"
+	CopiesDiffer     = "distintas"
+	CopiesUnverified = "sin verificar" // Falta el hash de alguna copia
+)
+
+// SearchHolder es una copia de un resultado en un peer
+type SearchHolder struct {
+	PeerID int
+	Online bool
+	Info   state.FileInfo
+}
+
+// SearchResult agrupa las copias de una misma ruta en todos los peers
+type SearchResult struct {
+	Name    string
+	IsDir   bool
+	Holders []SearchHolder
+	Copies  string
+}
+
+// Has indica si peerID tiene una copia
+func (r SearchResult) Has(peerID int) bool {
+	for _, h := range r.Holders {
+		if h.PeerID == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Best devuelve la copia en línea más reciente, para traerla
+func (r SearchResult) Best() (SearchHolder, bool) {
+	var best SearchHolder
+	found := false
+	for _, h := range r.Holders {
+		if h.Online && (!found || h.Info.ModTime.After(best.Info.ModTime)) {
+			best, found = h, true
+		}
+	}
+	return best, found
+}
+
+// Search busca en el listado local y en el último listado conocido de cada peer
+// los archivos que cumplen la consulta
+func Search(peers []peer.PeerInfo, localID int, q SearchQuery) ([]SearchResult, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+	return collectCopies(peers, localID, func(f state.FileInfo) bool { return q.accepts(f, match) }), nil
+}
+
+// FindCopies devuelve las copias conocidas de una ruta exacta en todos los peers
+func FindCopies(peers []peer.PeerInfo, localID int, name string) (SearchResult, bool) {
+	results := collectCopies(peers, localID, func(f state.FileInfo) bool { return f.Name == name })
+	if len(results) == 0 {
+		return SearchResult{Name: name}, false
+	}
+	return results[0], true
+}
+
+// collectCopies agrupa por ruta las entradas que acepta keep, del listado local
+// y del último listado conocido de cada peer
+func collectCopies(peers []peer.PeerInfo, localID int, keep func(state.FileInfo) bool) []SearchResult {
+	byName := make(map[string]*SearchResult)
+	for _, p := range peers {
+		files := state.CachedFiles(p.IP)
+		online := state.IsOnline(p.IP)
+		if p.ID == localID {
+			files = ListSharedFiles()
+			online = true
+		}
+		for _, f := range files {
+			if !keep(f) {
+				continue
+			}
+			r, ok := byName[f.Name]
+			if !ok {
+				r = &SearchResult{Name: f.Name, IsDir: f.IsDir}
+				byName[f.Name] = r
+			}
+			r.Holders = append(r.Holders, SearchHolder{PeerID: p.ID, Online: online, Info: f})
+		}
+	}
+
+	results := make([]SearchResult, 0, len(byName))
+	for _, r := range byName {
+		sort.Slice(r.Holders, func(i, j int) bool { return r.Holders[i].PeerID < r.Holders[j].PeerID })
+		r.Copies = compareCopies(r.Holders)
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// compareCopies decide si las copias tienen el mismo contenido usando el hash;
+// sin hash, tamaños distintos alcanzan para saber que difieren
+func compareCopies(holders []SearchHolder) string {
+	if len(holders) < 2 {
+		return CopiesSingle
+	}
+	first := holders[0].Info
+	verified := true
+	for _, h := range holders[1:] {
+		if first.IsDir || h.Info.IsDir {
+			continue
+		}
+		if h.Info.Size != first.Size {
+			return CopiesDiffer
+		}
+		if h.Info.Hash == "" || first.Hash == "" {
+			verified = false
+			continue
+		}
+		if h.Info.Hash != first.Hash {
+			return CopiesDiffer
+		}
+	}
+	if !verified {
+		return CopiesUnverified
+	}
+	return CopiesIdentical
+}
+
+func (q SearchQuery) matcher() (func(string) bool, error) {
+	pattern := strings.TrimSpace(q.Pattern)
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	if q.Regex {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expresión regular inválida: %w", err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("patrón inválido: %w", err)
+	}
+	pattern = strings.ToLower(pattern)
+	if !strings.ContainsAny(pattern, "*?[") {
+		// Sin comodines se busca el texto en cualquier parte del nombre
+		return func(name string) bool { return strings.Contains(strings.ToLower(name), pattern) }, nil
+	}
+	return func(name string) bool {
+		name = strings.ToLower(name)
+		// Un patrón con / se compara con la ruta completa; si no, con el nombre
+		if strings.Contains(pattern, "/") {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}, nil
+}
+
+func (q SearchQuery) accepts(f state.FileInfo, match func(string) bool) bool {
+	if f.IsDir && !q.IncludeDirs {
+		return false
+	}
+	if !match(f.Name) {
+		return false
+	}
+	if q.Ext != "" {
+		ext := "." + strings.TrimPrefix(strings.ToLower(q.Ext), ".")
+		if strings.ToLower(path.Ext(f.Name)) != ext {
+			return false
+		}
+	}
+	if !f.IsDir && (f.Size < q.MinSize || (q.MaxSize > 0 && f.Size > q.MaxSize)) {
+		return false
+	}
+	if !q.Since.IsZero() && f.ModTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && f.ModTime.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// ParseSize interpreta tamaños como "512", "10K", "2.5MB" o "1G"
+func ParseSize(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	if text == "" {
+		return 0, nil
+	}
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "B"), "I")
+	mult := int64(1)
+	if n := len(text); n > 0 {
+		switch text[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			text = strings.TrimSpace(text[:n-1])
+		}
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("tamaño inválido")
+	}
+	return int64(v * float64(mult)), nil
+}
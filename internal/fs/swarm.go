@@ -0,0 +1,208 @@
+package fs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/compress"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+	"p2pfs/internal/transfer"
+)
+
+const (
+	swarmPieceSize = 4 * 1024 * 1024 // Máximo pedido a una fuente en un solo GET_RANGE
+	swarmPerSource = 2               // Pedidos simultáneos a cada fuente
+)
+
+// swarmSource es un peer que tiene una copia idéntica del archivo, quizás con otro nombre
+type swarmSource struct {
+	peer peer.PeerInfo
+	name string
+}
+
+// swarmPiece es un tramo contiguo de fragmentos que se pide de una sola vez
+type swarmPiece struct {
+	chunks []chunk.Chunk
+}
+
+// swarmSources busca, además de p, los peers en línea cuyo último listado
+// anuncia un archivo con el mismo hash
+func swarmSources(p peer.PeerInfo, filename, hash string) []swarmSource {
+	sources := []swarmSource{{peer: p, name: filepath.ToSlash(filename)}}
+	if hash == "" {
+		return sources
+	}
+	for _, other := range peer.GetPeers() {
+		if other.ID == p.ID || other.ID == peer.Local.ID || !state.IsOnline(other.IP) {
+			continue
+		}
+		for _, f := range state.CachedFiles(other.IP) {
+			if !f.IsDir && f.Hash == hash {
+				sources = append(sources, swarmSource{peer: other, name: f.Name})
+				break
+			}
+		}
+	}
+	return sources
+}
+
+// swarmPieces agrupa los fragmentos a descargar en tramos contiguos
+func swarmPieces(chunks []chunk.Chunk) []*swarmPiece {
+	var pieces []*swarmPiece
+	var current *swarmPiece
+	var end, size int64
+	for _, c := range chunks {
+		if current == nil || c.Offset != end || size+int64(c.Size) > swarmPieceSize {
+			current = &swarmPiece{}
+			pieces = append(pieces, current)
+			size = 0
+		}
+		current.chunks = append(current.chunks, c)
+		end = c.Offset + int64(c.Size)
+		size += int64(c.Size)
+	}
+	return pieces
+}
+
+// fetchSwarm descarga los fragmentos indicados repartiendo tramos entre todas las
+// fuentes en paralelo. Cada fragmento se verifica por su hash; si una fuente se
+// desconecta o envía datos incorrectos deja de usarse y sus tramos pasan a las demás.
+func fetchSwarm(h *transfer.Handle, sources []swarmSource, chunks []chunk.Chunk) (map[string][]byte, error) {
+	pieces := swarmPieces(chunks)
+	queue := make(chan *swarmPiece, len(pieces))
+	for _, piece := range pieces {
+		queue <- piece
+	}
+
+	var mutex sync.Mutex
+	data := make(map[string][]byte, len(chunks))
+	remaining := len(pieces)
+	workers := len(sources) * swarmPerSource
+	var lastErr error
+	finished := make(chan struct{})
+	dead := make(map[int]bool)
+
+	// finish cierra finished una sola vez; se asume que mutex está tomado
+	finish := func() {
+		select {
+		case <-finished:
+		default:
+			close(finished)
+		}
+	}
+
+	worker := func(src swarmSource) {
+		for {
+			var piece *swarmPiece
+			select {
+			case <-finished:
+				return
+			case piece = <-queue:
+			}
+
+			mutex.Lock()
+			if dead[src.peer.ID] {
+				// Otro trabajador de la misma fuente ya falló: el tramo queda para las demás
+				queue <- piece
+				workers--
+				if workers == 0 {
+					finish()
+				}
+				mutex.Unlock()
+				return
+			}
+			mutex.Unlock()
+
+			got, err := fetchPiece(h, src, piece)
+			mutex.Lock()
+			if err != nil {
+				if !dead[src.peer.ID] {
+					fmt.Printf("⚠️ Fuente Maq%d descartada: %v\n", src.peer.ID, err)
+					dead[src.peer.ID] = true
+				}
+				lastErr = err
+				queue <- piece
+				workers--
+				if workers == 0 {
+					finish()
+				}
+				mutex.Unlock()
+				return
+			}
+			for hash, b := range got {
+				data[hash] = b
+			}
+			remaining--
+			if remaining == 0 {
+				finish()
+			}
+			mutex.Unlock()
+		}
+	}
+
+	for _, src := range sources {
+		for i := 0; i < swarmPerSource; i++ {
+			go worker(src)
+		}
+	}
+	<-finished
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if remaining > 0 {
+		return nil, fmt.Errorf("descarga incompleta (%d tramos sin fuente): %w", remaining, lastErr)
+	}
+	return data, nil
+}
+
+// fetchPiece pide un tramo con GET_RANGE y verifica cada fragmento
+func fetchPiece(h *transfer.Handle, src swarmSource, piece *swarmPiece) (map[string][]byte, error) {
+	first := piece.chunks[0]
+	last := piece.chunks[len(piece.chunks)-1]
+	length := last.Offset + int64(last.Size) - first.Offset
+
+	var resp struct {
+		Type     string `json:"type"`
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+		Error    string `json:"error"`
+	}
+	err := chunkRequest(src.peer, h, map[string]interface{}{
+		"type":           "GET_RANGE",
+		"name":           src.name,
+		"offset":         first.Offset,
+		"length":         length,
+		"acceptEncoding": compress.Supported,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Type != "RANGE" {
+		return nil, fmt.Errorf("respuesta inesperada: %v %s", resp.Type, resp.Error)
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Content)
+	if err == nil {
+		raw, err = compress.Decode(resp.Encoding, raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al decodificar tramo: %w", err)
+	}
+	if int64(len(raw)) != length {
+		return nil, fmt.Errorf("tramo incompleto: %d de %d bytes", len(raw), length)
+	}
+
+	out := make(map[string][]byte, len(piece.chunks))
+	for _, c := range piece.chunks {
+		start := c.Offset - first.Offset
+		b := raw[start : start+int64(c.Size)]
+		if chunk.Hash(b) != c.Hash {
+			return nil, fmt.Errorf("fragmento %s corrupto", c.Hash[:8])
+		}
+		out[c.Hash] = b
+	}
+	return out, nil
+}
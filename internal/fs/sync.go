@@ -9,8 +9,10 @@ import (
 	"path/filepath"
 	"time"
 
+	"p2pfs/internal/audit"
 	"p2pfs/internal/peer"
 	"p2pfs/internal/state"
+	"p2pfs/internal/throttle"
 )
 
 // ResyncAfterReconnect aplica operaciones pendientes a un nodo recién reconectado
@@ -32,28 +34,92 @@ func ResyncAfterReconnect(peerID int) {
 		return
 	}
 
+	// Los envíos y pedidos pendientes pasan por el planificador: si hay una
+	// ventana horaria para operaciones pendientes, esperan en cola hasta que se abra
+	if !throttle.Allowed(throttle.ClassPending, time.Now()) {
+		next := throttle.NextAllowed(throttle.ClassPending, time.Now())
+		fmt.Printf("⏳ Operaciones pendientes para Maq%d en espera hasta las %s\n", peerID, next.Format("15:04"))
+	}
+	batch := Transfers.NewBatch(throttle.ClassPending)
+	var jobs []func()
+
 	for _, op := range ops {
+		op := op
 		switch op.Type {
 		case "send":
 			if op.SourceID == localID {
-				err := SendFileToPeer(target, op.FilePath, op.Flatten)
-				if err != nil {
-					fmt.Printf("❌ Error al reenviar %s: %v\n", op.FilePath, err)
+				for _, name := range pendingSendFiles(op.FilePath) {
+					name := name
+					flatten := op.Flatten && name == op.FilePath
+					jobs = append(jobs, func() {
+						batch.Add(target.ID, name, func() error {
+							return SendFileToPeer(target, name, flatten)
+						})
+					})
 				}
 			}
 		case "get":
 			if op.TargetID == localID {
-				err := RequestFileFromPeer(target, op.FilePath, op.Flatten)
-				if err != nil {
-					fmt.Printf("❌ Error al solicitar %s: %v\n", op.FilePath, err)
-				}
+				jobs = append(jobs, func() {
+					batch.Add(target.ID, op.FilePath, func() error {
+						return RequestFileFromPeer(target, op.FilePath, op.Flatten)
+					})
+				})
 			}
 		case "delete":
 			if op.SourceID == localID {
-				go sendDeleteRequest(target, op.FilePath)
+				go func(path string) {
+					err := sendDeleteRequest(target, path)
+					audit.RecordOp("DELETE", path, localID, target.ID, 0, "", err)
+				}(op.FilePath)
+			}
+		case "rename":
+			// Renombres, carpetas y copias se aplican en orden y antes de los
+			// envíos encolados, que pueden referirse ya a las rutas nuevas
+			if op.SourceID == localID {
+				err := sendRenameRequest(target, op.FilePath, op.NewPath)
+				audit.RecordRename(op.FilePath, op.NewPath, localID, target.ID, err)
+			}
+		case "mkdir":
+			if op.SourceID == localID {
+				err := sendMakeDirRequest(target, op.FilePath)
+				audit.RecordOp("MKDIR", op.FilePath, localID, target.ID, 0, "", err)
+			}
+		case "copy":
+			if op.SourceID == localID {
+				err := sendCopyRequest(target, op.FilePath, op.NewPath)
+				audit.RecordPaths("COPY", op.FilePath, op.NewPath, localID, target.ID, err)
 			}
 		}
 	}
+
+	// Se encola en segundo plano: con la cola llena Submit espera y no debe
+	// frenar el ciclo de sincronización
+	go func() {
+		for _, add := range jobs {
+			add()
+		}
+	}()
+}
+
+// pendingSendFiles expande una carpeta pendiente de envío en sus archivos, así
+// cada uno es un trabajo independiente del planificador
+func pendingSendFiles(name string) []string {
+	root := filepath.Join("shared", filepath.Clean(name))
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return []string{name}
+	}
+	var files []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if rel, err := filepath.Rel("shared", path); err == nil {
+				files = append(files, rel)
+			}
+		}
+		return nil
+	})
+	return files
 }
 
 
@@ -111,6 +177,15 @@ func requestFileFromPeer(ip, filename string) {
 
 // StartAutoSync sincroniza periódicamente con los peers
 func StartAutoSync(peerSystem *peer.Peer, localID int, callbacks SyncCallbacks) {
+	// Los cambios locales se muestran apenas el vigilante los detecta
+	if localWatcher != nil {
+		localWatcher.Subscribe(func(events []WatchEvent) {
+			files := localWatcher.Files()
+			state.SetFileCache(peer.GetLocalIP(), files)
+			callbacks.UpdateFileList(localID, files)
+		})
+	}
+
 	ticker := time.NewTicker(5 * time.Second)
 	go func() {
 		for range ticker.C {
@@ -120,57 +195,66 @@ func StartAutoSync(peerSystem *peer.Peer, localID int, callbacks SyncCallbacks)
 
 				if pinfo.ID != localID {
 					var err error
-					address := fmt.Sprintf("%s:%s", pinfo.IP, pinfo.Port)
-					files, err = requestFileListFromPeer(address)
+					files, err = fetchPeerListing(pinfo)
 					isOnline = err == nil
 				} else {
 					files = ListSharedFiles()
 				}
 
-				wasOnline := state.OnlineStatus[pinfo.IP]
-				state.OnlineStatus[pinfo.IP] = isOnline
+				wasOnline := state.SetOnline(pinfo.IP, isOnline)
 
 				if isOnline && !wasOnline && pinfo.ID != localID {
 					ResyncAfterReconnect(pinfo.ID)
 				}
 
 				if isOnline {
-					state.FileCache[pinfo.IP] = files
+					state.SetFileCache(pinfo.IP, files)
 					callbacks.UpdateStatus(pinfo.ID, true)
 					callbacks.UpdateFileList(pinfo.ID, files)
 				} else {
 					callbacks.UpdateStatus(pinfo.ID, false)
-					callbacks.UpdateFileList(pinfo.ID, state.FileCache[pinfo.IP])
+					callbacks.UpdateFileList(pinfo.ID, state.CachedFiles(pinfo.IP))
 				}
 			}
 		}
 	}()
 }
 
+// ListSharedFiles devuelve el listado local; si el vigilante está activo usa
+// su listado en memoria en lugar de recorrer shared
 func ListSharedFiles() []state.FileInfo {
+	if localWatcher != nil {
+		return localWatcher.Files()
+	}
+	return walkShared()
+}
+
+// walkShared recorre todo shared
+func walkShared() []state.FileInfo {
 	var files []state.FileInfo
 	_ = filepath.Walk("shared", func(path string, info os.FileInfo, err error) error {
 		if err != nil || path == "shared" {
 			return nil
 		}
 		rel, _ := filepath.Rel("shared", path)
-		files = append(files, state.FileInfo{
-			Name:    rel,
-			ModTime: info.ModTime(),
-			IsDir:   info.IsDir(),
-		})
+		files = append(files, fileInfoFor(filepath.ToSlash(rel), info))
 		return nil
 	})
 	return files
 }
 
+// fileInfoFor arma la entrada del listado para una ruta relativa a shared
+func fileInfoFor(rel string, info os.FileInfo) state.FileInfo {
+	return state.NewFileInfo(rel, info)
+}
+
 func GetLocalOrRemoteFileList(peerSystem *peer.Peer, peerID int) ([]state.FileInfo, error) {
 	if peerID == peerSystem.Local.ID {
 		return ListSharedFiles(), nil
 	}
 	for _, p := range peerSystem.Peers {
 		if p.ID == peerID {
-			return requestFileListFromPeer(fmt.Sprintf("%s:%s", p.IP, p.Port))
+			return fetchPeerListing(p)
 		}
 	}
 	return nil, fmt.Errorf("peer %d no encontrado", peerID)
@@ -180,3 +264,57 @@ type SyncCallbacks struct {
 	UpdateStatus   func(peerID int, online bool)
 	UpdateFileList func(peerID int, files []state.FileInfo)
 }
+// RetryPendingOp ejecuta ya una operación pendiente hacia peerID, sin esperar el
+// ciclo de reconexión ni las ventanas horarias
+func RetryPendingOp(peerID int, op state.PendingOperation) error {
+	target, ok := findPeer(peerID)
+	if !ok {
+		return fmt.Errorf("peer %d no encontrado", peerID)
+	}
+	if !state.IsOnline(target.IP) {
+		return fmt.Errorf("Maq%d sigue desconectada", peerID)
+	}
+	if !state.RemovePendingOp(peerID, op) {
+		return fmt.Errorf("la operación ya no está pendiente")
+	}
+
+	switch op.Type {
+	case "send":
+		if op.SourceID != peer.Local.ID {
+			source, ok := findPeer(op.SourceID)
+			if !ok {
+				return fmt.Errorf("peer origen %d no encontrado", op.SourceID)
+			}
+			return RelayFileBetweenPeers(source, op.FilePath, []peer.PeerInfo{target})
+		}
+		return SendFileToPeer(target, op.FilePath, op.Flatten)
+	case "get":
+		return RequestFileFromPeer(target, op.FilePath, op.Flatten)
+	case "delete":
+		err := sendDeleteRequest(target, op.FilePath)
+		audit.RecordOp("DELETE", op.FilePath, peer.Local.ID, target.ID, 0, "", err)
+		return err
+	case "rename":
+		err := sendRenameRequest(target, op.FilePath, op.NewPath)
+		audit.RecordRename(op.FilePath, op.NewPath, peer.Local.ID, target.ID, err)
+		return err
+	case "mkdir":
+		err := sendMakeDirRequest(target, op.FilePath)
+		audit.RecordOp("MKDIR", op.FilePath, peer.Local.ID, target.ID, 0, "", err)
+		return err
+	case "copy":
+		err := sendCopyRequest(target, op.FilePath, op.NewPath)
+		audit.RecordPaths("COPY", op.FilePath, op.NewPath, peer.Local.ID, target.ID, err)
+		return err
+	}
+	return fmt.Errorf("operación desconocida: %s", op.Type)
+}
+
+// CancelPendingOp descarta una operación pendiente hacia peerID
+func CancelPendingOp(peerID int, op state.PendingOperation) error {
+	if !state.RemovePendingOp(peerID, op) {
+		return fmt.Errorf("la operación ya no está pendiente")
+	}
+	fmt.Printf("🚫 Operación pendiente descartada: %s %s (Maq%d)\n", op.Type, op.FilePath, peerID)
+	return nil
+}
@@ -3,15 +3,23 @@ package fs
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"p2pfs/internal/audit"
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/compress"
+	"p2pfs/internal/index"
 	"p2pfs/internal/peer"
 	"p2pfs/internal/state"
+	"p2pfs/internal/transfer"
+	"p2pfs/internal/throttle"
 )
 
 // SendFileToPeer envía un archivo o carpeta local a otro nodo
@@ -48,27 +56,125 @@ func sendSingleFile(p peer.PeerInfo, fullPath string, sendAsName string) error {
 	if err != nil {
 		return fmt.Errorf("no se pudo leer %s: %w", fullPath, err)
 	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("no se pudo acceder a %s: %w", fullPath, err)
+	}
+	if rel, err := filepath.Rel("shared", fullPath); err == nil {
+		chunk.Local.AddData(rel, data)
+	}
+
+	h := transfer.Start(transfer.KindSend, p.ID, sendAsName, int64(len(data)))
+	h.SetRetry(func() error { return sendSingleFile(p, fullPath, sendAsName) })
+	err = pushContent(p, h, sendAsName, data, info.ModTime(), localVersion(fullPath, info.ModTime()))
+	return finishSend(h, "TRANSFER", sendAsName, peer.Local.ID, p.ID, int64(len(data)), audit.HashBytes(data), err)
+}
+
+// errUnconfirmed indica que el receptor cerró la conexión sin confirmar el
+// SEND_FILE, como hacen los nodos anteriores al acuse
+var errUnconfirmed = errors.New("envío sin confirmación del receptor")
+
+// finishSend cierra la transferencia h y la registra en el historial. Un envío
+// sin confirmar se da por hecho, pero queda registrado como tal.
+func finishSend(h *transfer.Handle, action, name string, originID, targetID int, size int64, hash string, err error) error {
+	if err == errUnconfirmed {
+		h.Finish(nil)
+		audit.RecordUnconfirmed(action, name, originID, targetID, size, hash)
+		return nil
+	}
+	h.Finish(err)
+	audit.RecordOp(action, name, originID, targetID, size, hash, err)
+	return err
+}
+
+// pushContent envía un archivo con el método más barato que soporte el peer:
+// solo los bloques cambiados, solo los fragmentos que le faltan o el contenido completo
+func pushContent(p peer.PeerInfo, h *transfer.Handle, name string, data []byte, modTime time.Time, version state.VersionVector) error {
+	if len(data) >= deltaMinSize {
+		sent, err := sendDelta(p, h, name, data, modTime, version)
+		if err != nil {
+			fmt.Println("⚠️ Falló el envío por delta:", err)
+		}
+		if sent {
+			return nil
+		}
+	}
+	if len(data) >= chunkMinSize {
+		sent, err := sendChunked(p, h, name, data, modTime, version)
+		if err != nil {
+			fmt.Println("⚠️ Falló el envío por fragmentos:", err)
+		}
+		if sent {
+			return nil
+		}
+	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", p.IP, p.Port))
+	conn, err := dialTransfer(p, h)
 	if err != nil {
 		return fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
 	}
 	defer conn.Close()
 
+	payload, encoding := compress.Encode(name, data, encodingsFor(p))
 	msg := map[string]interface{}{
 		"type":    "SEND_FILE",
-		"name":    sendAsName,
-		"content": base64.StdEncoding.EncodeToString(data),
+		"name":    name,
+		"content": base64.StdEncoding.EncodeToString(payload),
 		"isDir":   false,
+		"modTime": modTime,
+		"version": version,
+	}
+	if encoding != "" {
+		msg["encoding"] = encoding
 	}
-	return json.NewEncoder(conn).Encode(msg)
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+	var ack struct {
+		Type  string `json:"type"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&ack); err != nil {
+		if err == io.EOF {
+			return errUnconfirmed
+		}
+		return fmt.Errorf("no se recibió confirmación: %w", err)
+	}
+	if ack.Type != "FILE_ACK" {
+		return fmt.Errorf("el peer rechazó el archivo: %s", ack.Error)
+	}
+	return nil
+}
+
+// dialTransfer abre una conexión para transferir contenido con p, sujeta a los
+// límites de ancho de banda globales y del peer. Si h no es nil, los bytes se
+// cuentan en esa transferencia.
+func dialTransfer(p peer.PeerInfo, h *transfer.Handle) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return h.Conn(throttle.Wrap(conn, p.ID)), nil
+}
+
+// localVersion devuelve el vector de versión de un archivo dentro de shared
+func localVersion(fullPath string, modTime time.Time) state.VersionVector {
+	rel, err := filepath.Rel("shared", fullPath)
+	if err != nil {
+		return nil
+	}
+	return state.ObserveVersion(filepath.ToSlash(rel), modTime)
 }
 
 // sendDirectoryRecursively envía todos los archivos dentro de una carpeta con estructura
+// Los archivos se envían en paralelo a través del planificador de transferencias.
 func sendDirectoryRecursively(p peer.PeerInfo, root string) error {
 	rootPath := filepath.Join("shared", root)
+	batch := Transfers.NewBatch(throttle.ClassBulk)
 
-	return filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	walkErr := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
@@ -83,8 +189,8 @@ func sendDirectoryRecursively(p peer.PeerInfo, root string) error {
 
 		// Si el nodo está desconectado → registrar como pendiente
 		
-		if !state.OnlineStatus[p.IP] {
-		state.FileCache[p.IP] = append(state.FileCache[p.IP], state.FileInfo{
+		if !state.IsOnline(p.IP) {
+		state.AddToFileCache(p.IP, state.FileInfo{
 		Name:    relPath,
 		ModTime: info.ModTime(),
 		IsDir:   false,
@@ -97,22 +203,30 @@ func sendDirectoryRecursively(p peer.PeerInfo, root string) error {
 			Flatten:  false, // ✅ estructura completa
 		})
 		peer.SendSyncLog("TRANSFER", relPath, peer.Local.ID, p.ID)
+		audit.RecordPending("TRANSFER", relPath, peer.Local.ID, p.ID)
 		fmt.Printf("📦 Pendiente: %s para %s\n", relPath, p.IP)
 		return nil
 		}
 
 
 
-		// Nodo en línea → enviar inmediatamente
-		return sendSingleFile(p, path, relPath)
+		// Nodo en línea → encolar el envío
+		batch.Add(p.ID, relPath, func() error {
+			return sendSingleFile(p, path, relPath)
+		})
+		return nil
 	})
+	if err := batch.Wait(); err != nil {
+		return err
+	}
+	return walkErr
 }
 
 
 
 // RequestFileFromPeer solicita un archivo desde otro nodo
 func RequestFileFromPeer(p peer.PeerInfo, filename string, flatten bool) error {
-	if !state.OnlineStatus[p.IP] {
+	if !state.IsOnline(p.IP) {
 		state.AddPendingOp(p.ID, state.PendingOperation{
 			Type:     "get",
 			FilePath: filename,
@@ -121,68 +235,190 @@ func RequestFileFromPeer(p peer.PeerInfo, filename string, flatten bool) error {
 			Flatten:  flatten, // ✅ nuevo campo
 		})
 		peer.SendSyncLog("GET_FILE", filename, p.ID, peer.Local.ID)
+		audit.RecordPending("TRANSFER", filename, p.ID, peer.Local.ID)
 		fmt.Printf("📥 Solicitud pendiente: archivo '%s' será enviado desde %s al reconectarse\n", filename, p.IP)
 		return nil
 	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", p.IP, p.Port))
+	// ✅ Cambiar forma de guardar según flatten
+	var path string
+	if flatten {
+		path = filepath.Join("shared", filepath.Base(filename)) // sin carpeta
+	} else {
+		path = filepath.Join("shared", filename) // con estructura
+	}
+
+	// Si ya tenemos localmente un archivo con el mismo contenido se copia sin usar la red
+	if size, hash, ok := copyKnownContent(p, filename, path); ok {
+		audit.RecordOp("TRANSFER", filename, p.ID, peer.Local.ID, size, hash, nil)
+		fmt.Println("✅ Contenido ya disponible localmente, copiado →", path)
+		return nil
+	}
+
+	// Los archivos grandes se piden por fragmentos para no bajar lo que ya está en el almacén local
+	var size int64
+	var hash string
+	err := errUnsupported
+	remote, _ := cachedRemoteInfo(p, filename)
+	h := transfer.Start(transfer.KindReceive, p.ID, filename, remote.Size)
+	h.SetRetry(func() error { return RequestFileFromPeer(p, filename, flatten) })
+	if remote.Size >= chunkMinSize {
+		size, hash, err = fetchChunked(p, h, filename, path)
+	}
+	// Si un fragmento local desapareció mientras se armaba el archivo se baja completo
+	if err == errUnsupported || errors.Is(err, chunk.ErrMissingChunk) {
+		size, hash, err = fetchFileTo(p, h, filename, path)
+	}
+	h.Finish(err)
+	audit.RecordOp("TRANSFER", filename, p.ID, peer.Local.ID, size, hash, err)
 	if err != nil {
-		return fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
+		return err
+	}
+
+	fmt.Println("✅ Archivo transferido desde", p.IP, "→", path)
+	return nil
+}
+
+// fetchFileTo descarga un archivo remoto y lo guarda en path, conservando
+// su fecha de modificación y su vector de versión
+func fetchFileTo(p peer.PeerInfo, h *transfer.Handle, filename, path string) (int64, string, error) {
+	conn, err := dialTransfer(p, h)
+	if err != nil {
+		return 0, "", fmt.Errorf("no se pudo conectar a %s: %w", p.IP, err)
 	}
 	defer conn.Close()
 
 	req := map[string]interface{}{
-		"type": "GET_FILE",
-		"name": filename,
+		"type":           "GET_FILE",
+		"name":           filename,
+		"acceptEncoding": compress.Supported,
 	}
 	if err := json.NewEncoder(conn).Encode(req); err != nil {
-		return fmt.Errorf("no se pudo enviar la solicitud: %w", err)
+		return 0, "", fmt.Errorf("no se pudo enviar la solicitud: %w", err)
 	}
 
 	var resp map[string]interface{}
 	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
-		return fmt.Errorf("error al recibir archivo: %w", err)
+		return 0, "", fmt.Errorf("error al recibir archivo: %w", err)
 	}
 
 	if resp["type"] != "FILE_CONTENT" {
 		errMsg, _ := resp["error"].(string)
-		return fmt.Errorf("respuesta inesperada del peer: %v", errMsg)
+		return 0, "", fmt.Errorf("respuesta inesperada del peer: %v", errMsg)
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(resp["content"].(string))
+	content, _ := resp["content"].(string)
+	decoded, err := base64.StdEncoding.DecodeString(content)
+	if err == nil {
+		encoding, _ := resp["encoding"].(string)
+		decoded, err = compress.Decode(encoding, decoded)
+	}
 	if err != nil {
-		return fmt.Errorf("error al decodificar contenido: %w", err)
+		return 0, "", fmt.Errorf("error al decodificar contenido: %w", err)
 	}
+	size := int64(len(decoded))
+	hash := audit.HashBytes(decoded)
 
-	// ✅ Cambiar forma de guardar según flatten
-	var path string
-	if flatten {
-		path = filepath.Join("shared", filepath.Base(filename)) // sin carpeta
-	} else {
-		path = filepath.Join("shared", filename) // con estructura
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return size, hash, fmt.Errorf("error creando carpetas destino: %w", err)
 	}
 
+	defer beginReceive(path)()
+	if err := os.WriteFile(path, decoded, 0644); err != nil {
+		return size, hash, fmt.Errorf("error al guardar archivo: %w", err)
+	}
+	recordReceivedMetadata(path, resp)
+	if info, err := os.Stat(path); err == nil {
+		if rel, err := filepath.Rel("shared", path); err == nil {
+			state.StoreHash(rel, info, hash)
+		}
+	}
+	return size, hash, nil
+}
+
+// copyKnownContent busca en el índice local un archivo con el hash que el peer
+// anunció para filename y, si existe, lo copia a path
+func copyKnownContent(p peer.PeerInfo, filename, path string) (int64, string, bool) {
+	remote, ok := cachedRemoteInfo(p, filename)
+	if !ok || remote.Hash == "" {
+		return 0, "", false
+	}
+	rel, ok := index.Local.FindByHash(remote.Hash)
+	if !ok {
+		return 0, "", false
+	}
+	src := filepath.Join("shared", filepath.FromSlash(rel))
+	if filepath.Clean(src) == filepath.Clean(path) {
+		return remote.Size, remote.Hash, true
+	}
+	data, err := os.ReadFile(src)
+	if err != nil || state.HashBytes(data) != remote.Hash {
+		return 0, "", false
+	}
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return fmt.Errorf("error creando carpetas destino: %w", err)
+		return 0, "", false
+	}
+	defer beginReceive(path)()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, "", false
 	}
+	_ = os.Chtimes(path, remote.ModTime, remote.ModTime)
+	if info, err := os.Stat(path); err == nil {
+		dst, _ := filepath.Rel("shared", path)
+		state.RecordReceivedVersion(filepath.ToSlash(dst), remote.Version, info.ModTime())
+		state.StoreHash(dst, info, remote.Hash)
+	}
+	return int64(len(data)), remote.Hash, true
+}
 
-	if err := os.WriteFile(path, decoded, 0644); err != nil {
-		return fmt.Errorf("error al guardar archivo: %w", err)
+// cachedRemoteInfo busca un archivo en el último listado conocido del peer
+func cachedRemoteInfo(p peer.PeerInfo, filename string) (state.FileInfo, bool) {
+	for _, f := range state.CachedFiles(p.IP) {
+		if f.Name == filepath.ToSlash(filename) {
+			return f, true
+		}
 	}
+	return state.FileInfo{}, false
+}
 
-	fmt.Println("✅ Archivo transferido desde", p.IP, "→", path)
-	return nil
+// beginReceive protege el vector de versión de path mientras se escribe un
+// archivo recibido; ver state.BeginReceive
+func beginReceive(path string) func() {
+	rel, err := filepath.Rel("shared", path)
+	if err != nil {
+		return func() {}
+	}
+	return state.BeginReceive(rel)
+}
+
+// recordReceivedMetadata aplica la fecha de modificación y el vector de versión
+// que acompañan a un archivo recibido
+func recordReceivedMetadata(path string, msg map[string]interface{}) {
+	if modStr, ok := msg["modTime"].(string); ok {
+		if modTime, err := time.Parse(time.RFC3339Nano, modStr); err == nil && !modTime.IsZero() {
+			_ = os.Chtimes(path, modTime, modTime)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel("shared", path)
+	if err != nil {
+		return
+	}
+	state.RecordReceivedVersion(filepath.ToSlash(rel), state.ParseVersion(msg["version"]), info.ModTime())
 }
 
 
 
 // RequestDirectoryFromPeer solicita todos los archivos dentro de un directorio remoto
 func RequestDirectoryFromPeer(p peer.PeerInfo, dir string) error {
-	if !state.OnlineStatus[p.IP] {
+	if !state.IsOnline(p.IP) {
 		fmt.Printf("📥 Nodo %s desconectado, registrando solicitud de carpeta %s como pendiente\n", p.IP, dir)
 		
 		// Obtener archivos del FileCache de la última sincronización
-		for _, f := range state.FileCache[p.IP] {
+		for _, f := range state.CachedFiles(p.IP) {
 			if strings.HasPrefix(f.Name, dir+"/") && !f.IsDir {
 				state.AddPendingOp(p.ID, state.PendingOperation{
 					Type:     "get",
@@ -191,12 +427,13 @@ func RequestDirectoryFromPeer(p peer.PeerInfo, dir string) error {
 					SourceID: p.ID,
 				})
 				// Mostrar visualmente lo que llegará
-				state.FileCache[p.IP] = append(state.FileCache[p.IP], state.FileInfo{
+				state.AddToFileCache(p.IP, state.FileInfo{
 					Name:    f.Name,
 					ModTime: f.ModTime,
 					IsDir:   false,
 				})
 				peer.SendSyncLog("GET_FILE", f.Name, p.ID, peer.Local.ID)
+				audit.RecordPending("TRANSFER", f.Name, p.ID, peer.Local.ID)
 			}
 		}
 		return nil
@@ -212,12 +449,18 @@ func RequestDirectoryFromPeer(p peer.PeerInfo, dir string) error {
 		return fmt.Errorf("el directorio remoto está vacío o no se encontró: %s", dir)
 	}
 
+	// Las descargas corren en paralelo; el planificador informa cada error
+	batch := Transfers.NewBatch(throttle.ClassBulk)
 	for _, f := range files {
-		err := RequestFileFromPeer(p, f.Name, false)
-		if err != nil {
-			fmt.Printf("⚠️ Error al solicitar %s: %v\n", f.Name, err)
+		if f.IsDir {
+			continue
 		}
+		name := f.Name
+		batch.Add(p.ID, name, func() error {
+			return RequestFileFromPeer(p, name, false)
+		})
 	}
+	_ = batch.Wait()
 
 	return nil
 }
@@ -227,27 +470,124 @@ func RequestDirectoryFromPeer(p peer.PeerInfo, dir string) error {
 func RelayFileBetweenPeers(source peer.PeerInfo, filename string, targets []peer.PeerInfo) error {
 	filename = filepath.Clean(filename)
 
+	// Un archivo conocido no necesita listado: va directo al PUSH
+	if remote, ok := cachedRemoteInfo(source, filename); ok && !remote.IsDir {
+		return relaySingleFile(source, filename, targets)
+	}
+
 	files, err := requestRemoteFileList(source, filename)
 	if err != nil {
 		return fmt.Errorf("no se pudo obtener lista de archivos de %s: %w", filename, err)
 	}
-	if len(files) > 0 {
-		for _, f := range files {
-			rel := f.Name
-			RelayFileBetweenPeers(source, rel, targets)
+	if len(files) == 1 && files[0].Name == filepath.ToSlash(filename) && !files[0].IsDir {
+		return relaySingleFile(source, filename, targets)
+	}
+
+	// Cada archivo de la carpeta se reenvía como un trabajo del planificador
+	batch := Transfers.NewBatch(throttle.ClassBulk)
+	empty := true
+	for _, f := range files {
+		if f.IsDir {
+			continue
 		}
+		empty = false
+		rel := f.Name
+		batch.Add(source.ID, rel, func() error {
+			return relaySingleFile(source, rel, targets)
+		})
+	}
+	if empty {
+		return relayEmptyDir(source, filename, files, targets)
+	}
+	return batch.Wait()
+}
+
+// relayEmptyDir crea en cada destino una carpeta que en la fuente no tiene
+// archivos, junto con sus subcarpetas vacías
+func relayEmptyDir(source peer.PeerInfo, dir string, files []state.FileInfo, targets []peer.PeerInfo) error {
+	dirs := []string{filepath.ToSlash(dir)}
+	for _, f := range files {
+		if f.IsDir && f.Name != dirs[0] {
+			dirs = append(dirs, f.Name)
+		}
+	}
+	fmt.Printf("📁 %s no tiene archivos en Maq%d: solo se crea la carpeta\n", dir, source.ID)
+
+	var errs []error
+	for _, target := range targets {
+		for _, name := range dirs {
+			if err := makeRemoteDir(target, name, peer.Local.ID); err != nil && err != errDirPending {
+				errs = append(errs, fmt.Errorf("Maq%d: %w", target.ID, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// relaySingleFile le pide al nodo fuente que envíe el archivo directamente a cada
+// destino en línea; los destinos desconectados o que fallan quedan como pendientes.
+// Si la fuente no soporta PUSH, el archivo pasa por este nodo.
+func relaySingleFile(source peer.PeerInfo, filename string, targets []peer.PeerInfo) error {
+	var online []peer.PeerInfo
+	for _, target := range targets {
+		if !state.IsOnline(target.IP) {
+			queueRelay(source, filename, target)
+			continue
+		}
+		online = append(online, target)
+	}
+	if len(online) == 0 {
 		return nil
 	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", source.IP, source.Port))
+	remote, _ := cachedRemoteInfo(source, filename)
+	handles := make(map[int]*transfer.Handle, len(online))
+	for _, target := range online {
+		handles[target.ID] = transfer.Start(transfer.KindRelay, target.ID, filename, remote.Size)
+	}
+
+	results, hash, err := requestPush(source, filename, handles)
+	if err == errUnsupported {
+		for _, h := range handles {
+			h.Discard()
+		}
+		return relayThroughCoordinator(source, filename, online)
+	}
+	if err != nil {
+		for _, target := range online {
+			handles[target.ID].Finish(err)
+			queueRelay(source, filename, target)
+		}
+		return err
+	}
+
+	for _, target := range online {
+		r, ok := results[target.ID]
+		if !ok || !r.OK {
+			handles[target.ID].Finish(fmt.Errorf("relay fallido: %s", r.Error))
+			queueRelay(source, filename, target)
+			continue
+		}
+		handles[target.ID].Finish(nil)
+		audit.RecordOp("RELAY", filename, source.ID, target.ID, r.Bytes, hash, nil)
+		peer.SendSyncLog("TRANSFER", filename, source.ID, target.ID)
+	}
+	return nil
+}
+
+// relayThroughCoordinator descarga el archivo de una fuente antigua y lo reenvía a cada destino
+func relayThroughCoordinator(source peer.PeerInfo, filename string, targets []peer.PeerInfo) error {
+	conn, err := dialTransfer(source, nil)
 	if err != nil {
 		return fmt.Errorf("no se pudo conectar al peer fuente %s: %w", source.IP, err)
 	}
 	defer conn.Close()
 
 	req := map[string]interface{}{
-		"type": "GET_FILE",
-		"name": filename,
+		"type":           "GET_FILE",
+		"name":           filename,
+		"acceptEncoding": compress.Supported,
 	}
 	if err := json.NewEncoder(conn).Encode(req); err != nil {
 		return fmt.Errorf("no se pudo enviar solicitud: %w", err)
@@ -266,81 +606,62 @@ func RelayFileBetweenPeers(source peer.PeerInfo, filename string, targets []peer
 		return fmt.Errorf("contenido inválido")
 	}
 	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err == nil {
+		encoding, _ := resp["encoding"].(string)
+		data, err = compress.Decode(encoding, data)
+	}
 	if err != nil {
 		return fmt.Errorf("error al decodificar contenido: %w", err)
 	}
 
-	for _, target := range targets {
-		
+	modTime, _ := time.Parse(time.RFC3339Nano, fmt.Sprint(resp["modTime"]))
+	version := state.ParseVersion(resp["version"])
 
-		if !state.OnlineStatus[target.IP] {
-	// Registrar como pendiente cada archivo del directorio
-	if len(files) > 0 {
-		for _, f := range files {
-			state.FileCache[target.IP] = append(state.FileCache[target.IP], state.FileInfo{
-				Name:    f.Name,
-				ModTime: f.ModTime,
-				IsDir:   f.IsDir,
-			})
-			state.AddPendingOp(target.ID, state.PendingOperation{
-				Type:     "send",
-				FilePath: f.Name,
-				TargetID: target.ID,
-				SourceID: source.ID,
-			})
-			peer.SendSyncLog("TRANSFER", f.Name, source.ID, target.ID)
+	for _, target := range targets {
+		// Cada destino recibe solo los fragmentos que le faltan
+		h := transfer.Start(transfer.KindRelay, target.ID, filename, int64(len(data)))
+		err := pushContent(target, h, filename, data, modTime, version)
+		if err != nil && err != errUnconfirmed {
+			h.Finish(err)
+			fmt.Printf("⚠️ Relay %s → Maq%d falló: %v\n", filename, target.ID, err)
+			queueRelay(source, filename, target)
+			continue
 		}
-	} else {
-		// Caso: archivo único
-		state.FileCache[target.IP] = append(state.FileCache[target.IP], state.FileInfo{
-			Name:    filename,
-			ModTime: time.Now(),
-			IsDir:   false,
-		})
-		state.AddPendingOp(target.ID, state.PendingOperation{
-			Type:     "send",
-			FilePath: filename,
-			TargetID: target.ID,
-			SourceID: source.ID,
-		})
+		_ = finishSend(h, "RELAY", filename, source.ID, target.ID, int64(len(data)), audit.HashBytes(data), err)
 		peer.SendSyncLog("TRANSFER", filename, source.ID, target.ID)
-		}
-		continue
 	}
 
+	return nil
+}
 
-		connT, err := net.Dial("tcp", fmt.Sprintf("%s:%s", target.IP, target.Port))
-		if err != nil {
-			state.FileCache[target.IP] = append(state.FileCache[target.IP], state.FileInfo{
-				Name:    filename,
-				ModTime: time.Now(),
-			})
-			state.AddPendingOp(target.ID, state.PendingOperation{
-				Type:     "send",
-				FilePath: filename,
-				TargetID: target.ID,
-				SourceID: source.ID,
-			})
-			peer.SendSyncLog("TRANSFER", filename, source.ID, target.ID)
-			continue
-		}
-		defer connT.Close()
+// queueRelay deja el relay de filename hacia target como operación pendiente
+func queueRelay(source peer.PeerInfo, filename string, target peer.PeerInfo) {
+	state.AddToFileCache(target.IP, state.FileInfo{
+		Name:    filename,
+		ModTime: time.Now(),
+	})
+	state.AddPendingOp(target.ID, state.PendingOperation{
+		Type:     "send",
+		FilePath: filename,
+		TargetID: target.ID,
+		SourceID: source.ID,
+	})
+	peer.SendSyncLog("TRANSFER", filename, source.ID, target.ID)
+	audit.RecordPending("RELAY", filename, source.ID, target.ID)
+}
 
-		msg := map[string]interface{}{
-			"type":    "SEND_FILE",
-			"name":    filename,
-			"content": base64.StdEncoding.EncodeToString(data),
-			"isDir":   false,
-		}
-		json.NewEncoder(connT).Encode(msg)
-		peer.SendSyncLog("TRANSFER", filename, source.ID, target.ID)
+// requestRemoteFileList obtiene lista recursiva de archivos dentro de dir desde un nodo remoto.
+// Solo se pide esa carpeta; si el peer no soporta LIST_DIR se filtra su listado completo.
+func requestRemoteFileList(p peer.PeerInfo, dir string) ([]state.FileInfo, error) {
+	files, err := requestDirListing(p, strings.TrimSuffix(dir, "/"), true)
+	if err != errUnsupported {
+		return files, err
 	}
-
-	return nil
+	return requestRemoteFileListLegacy(p, dir)
 }
 
-// requestRemoteFileList obtiene lista recursiva de archivos desde un nodo remoto
-func requestRemoteFileList(peer peer.PeerInfo, dir string) ([]state.FileInfo, error) {
+// requestRemoteFileListLegacy descarga el listado completo y lo filtra por dir
+func requestRemoteFileListLegacy(peer peer.PeerInfo, dir string) ([]state.FileInfo, error) {
 	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", peer.IP, peer.Port))
 	if err != nil {
 		return nil, err
@@ -375,6 +696,10 @@ func requestRemoteFileList(peer peer.PeerInfo, dir string) ([]state.FileInfo, er
 			result = append(result, f)
 		}
 	}
+	// Igual que LIST_DIR: una ruta que no está en el listado es un error, no una carpeta vacía
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no existe %s", dir)
+	}
 	return result, nil
 }
 
@@ -390,7 +715,7 @@ func TransferFile(peerSystem *peer.Peer, selected SelectedFile, checkedPeers map
 	for _, p := range peerSystem.Peers {
 		if p.ID == selected.PeerID {
 			// Buscar si el archivo seleccionado es un directorio usando FileCache
-			for _, f := range state.FileCache[p.IP] {
+			for _, f := range state.CachedFiles(p.IP) {
 				if f.Name == selected.FileName && f.IsDir {
 					return 1, RequestDirectoryFromPeer(p, selected.FileName)
 					}
@@ -418,7 +743,7 @@ func TransferFile(peerSystem *peer.Peer, selected SelectedFile, checkedPeers map
 							isDir = info.IsDir()
 						}
 
-						state.FileCache[p.IP] = append(state.FileCache[p.IP], state.FileInfo{
+						state.AddToFileCache(p.IP, state.FileInfo{
 							Name:    selected.FileName,
 							ModTime: time.Now(),
 							IsDir:   isDir,
@@ -431,6 +756,7 @@ func TransferFile(peerSystem *peer.Peer, selected SelectedFile, checkedPeers map
 							SourceID: localID,
 						})
 						peer.SendSyncLog("TRANSFER", selected.FileName, localID, p.ID)
+						audit.RecordPending("TRANSFER", selected.FileName, localID, p.ID)
 					} else {
 						peer.SendSyncLog("TRANSFER", selected.FileName, localID, p.ID)
 					}
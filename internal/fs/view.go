@@ -26,11 +26,7 @@ func GetLocalFiles() ([]state.FileInfo, error) {
 		if err != nil {
 			continue
 		}
-		files = append(files, state.FileInfo{
-			Name:    entry.Name(),
-			ModTime: info.ModTime(),
-			IsDir:   entry.IsDir(),
-		})
+		files = append(files, state.NewFileInfo(entry.Name(), info))
 	}
 	return files, nil
 }
@@ -40,7 +36,7 @@ func GetRemoteFiles(ip, port string) ([]state.FileInfo, error) {
 	address := fmt.Sprintf("%s:%s", ip, port)
 	conn, err := net.DialTimeout("tcp", address, 2*time.Second)
 	if err != nil {
-		state.OnlineStatus[ip] = false
+		state.SetOnline(ip, false)
 		return nil, fmt.Errorf("nodo %s desconectado", ip)
 	}
 	defer conn.Close()
@@ -52,55 +48,23 @@ func GetRemoteFiles(ip, port string) ([]state.FileInfo, error) {
 		return nil, err
 	}
 
-	var response map[string]interface{}
+	// Se decodifica con las etiquetas JSON de state.FileInfo. La comparación de
+	// claves de encoding/json no distingue mayúsculas, así que también se leen
+	// los listados de nodos que todavía envían "Name"/"ModTime".
+	var response struct {
+		Type  string           `json:"type"`
+		Files []state.FileInfo `json:"files"`
+	}
 	if err := json.NewDecoder(conn).Decode(&response); err != nil {
 		return nil, err
 	}
-
-	var result []state.FileInfo
-
-	if response["type"] == "FILES_LIST" {
-		raw, ok := response["files"]
-		if !ok {
-			fmt.Println("❌ 'files' no encontrado en la respuesta")
-			return result, nil
-		}
-
-		rawFiles, ok := raw.([]interface{})
-		if !ok || rawFiles == nil {
-			fmt.Println("❌ 'files' no es una lista válida o es nil")
-			return result, nil
-		}
-
-		for _, item := range rawFiles {
-			f, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			// Extracción segura de campos
-			name, nameOK := f["name"].(string)
-			modStr, modOK := f["modTime"].(string)
-			isDir, _ := f["isDir"].(bool) // por defecto false
-
-			modTime := time.Now()
-			if modOK {
-				if parsed, err := time.Parse(time.RFC3339, modStr); err == nil {
-					modTime = parsed
-				}
-			}
-
-			if nameOK {
-				result = append(result, state.FileInfo{
-					Name:    name,
-					ModTime: modTime,
-					IsDir:   isDir,
-				})
-			}
-		}
+	if response.Type != "FILES_LIST" {
+		return nil, fmt.Errorf("respuesta inesperada: %v", response.Type)
 	}
-
-	return result, nil
+	if response.Files == nil {
+		fmt.Println("❌ 'files' no encontrado en la respuesta")
+	}
+	return response.Files, nil
 }
 
 // ✅ Retorna archivos del nodo especificado
@@ -122,20 +86,42 @@ func GetFilesByPeer(p peer.PeerInfo, localID int) ([]state.FileInfo, error) {
 	return files, nil
 }
 
-// ✅ Compara archivos locales con los del nodo remoto y retorna los que faltan o están desactualizados
+// ✅ Compara archivos locales con los del nodo remoto y retorna los que faltan o están desactualizados.
+// Si ambos lados tienen vector de versión se usa el vector y no la fecha, que depende del reloj de cada nodo.
+// Las modificaciones concurrentes no se consideran desactualizadas en ningún sentido: ver DetectConflicts.
 func CompararArchivos(localFiles, remotoFiles []state.FileInfo) []state.FileInfo {
 	var faltantes []state.FileInfo
-	remotoMap := make(map[string]time.Time)
+	remotoMap := make(map[string]state.FileInfo)
 
 	for _, rf := range remotoFiles {
-		remotoMap[rf.Name] = rf.ModTime
+		remotoMap[rf.Name] = rf
 	}
 
 	for _, lf := range localFiles {
-		if t, ok := remotoMap[lf.Name]; !ok || lf.ModTime.After(t) {
+		if rf, ok := remotoMap[lf.Name]; !ok || isNewer(lf, rf) {
 			faltantes = append(faltantes, lf)
 		}
 	}
 
 	return faltantes
 }
+
+// isNewer indica si a reemplaza a b
+func isNewer(a, b state.FileInfo) bool {
+	if len(a.Version) > 0 && len(b.Version) > 0 {
+		if sameContent(a, b) {
+			return false
+		}
+		return a.Version.Compare(b.Version) == state.After
+	}
+	return a.ModTime.After(b.ModTime)
+}
+
+// sameContent indica si dos entradas describen el mismo contenido:
+// por hash si ambos lo conocen, si no por tamaño y fecha
+func sameContent(a, b state.FileInfo) bool {
+	if a.Hash != "" && b.Hash != "" {
+		return a.Hash == b.Hash
+	}
+	return a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+}
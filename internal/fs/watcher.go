@@ -0,0 +1,348 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/index"
+	"p2pfs/internal/state"
+)
+
+// WatchEvent es un cambio local ya depurado (sin archivos temporales de editores)
+type WatchEvent struct {
+	Type    string // "create", "modify", "delete", "rename"
+	Name    string // Ruta relativa a shared
+	OldName string // Solo para "rename"
+}
+
+// Watcher vigila shared con inotify y mantiene el listado local en memoria,
+// así StartAutoSync no necesita recorrer todo el árbol en cada ciclo.
+type Watcher struct {
+	root     string
+	fsw      *fsnotify.Watcher
+	debounce time.Duration
+
+	mutex       sync.Mutex
+	files       map[string]state.FileInfo
+	pending     map[string]string // ruta → tipo de cambio acumulado
+	renamed     []state.FileInfo  // rutas renombradas que esperan su nuevo nombre
+	timer       *time.Timer
+	subscribers []func([]WatchEvent)
+}
+
+var localWatcher *Watcher
+
+// StartWatcher comienza a vigilar shared. Los eventos se agrupan durante debounce
+// y cada fallback se hace un escaneo completo para recuperar eventos perdidos.
+func StartWatcher(debounce, fallback time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo iniciar el vigilante de archivos: %w", err)
+	}
+
+	w := &Watcher{
+		root:     "shared",
+		fsw:      fsw,
+		debounce: debounce,
+		files:    make(map[string]state.FileInfo),
+		pending:  make(map[string]string),
+	}
+	w.addRecursive(w.root)
+	initial := walkShared()
+	for _, f := range initial {
+		w.files[f.Name] = f
+	}
+	state.ApplyListing(initial)
+	state.JournalLive.Store(true)
+	localWatcher = w
+
+	go w.loop()
+	go w.fallbackScan(fallback)
+	fmt.Println("👁️ Vigilando cambios en", w.root)
+	return w, nil
+}
+
+// Subscribe registra una función que recibe cada lote de cambios
+func (w *Watcher) Subscribe(fn func([]WatchEvent)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Files devuelve el listado local actual, en el mismo orden que un recorrido de shared
+func (w *Watcher) Files() []state.FileInfo {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	files := make([]state.FileInfo, 0, len(w.files))
+	for _, f := range w.files {
+		files = append(files, f)
+	}
+	sortByPath(files)
+	return files
+}
+
+func (w *Watcher) loop() {
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println("⚠️ Error del vigilante de archivos:", err)
+		}
+	}
+}
+
+func (w *Watcher) handle(ev fsnotify.Event) {
+	rel, err := filepath.Rel(w.root, ev.Name)
+	if err != nil || rel == "." || isTemporaryFile(filepath.Base(ev.Name)) {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	switch {
+	case ev.Has(fsnotify.Create):
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			// Las carpetas nuevas se vigilan y su contenido se informa como creado
+			w.addRecursive(ev.Name)
+			_ = filepath.Walk(ev.Name, func(path string, _ os.FileInfo, err error) error {
+				if err == nil {
+					if r, err := filepath.Rel(w.root, path); err == nil {
+						w.mark(filepath.ToSlash(r), "create")
+					}
+				}
+				return nil
+			})
+		} else {
+			w.mark(rel, "create")
+		}
+	case ev.Has(fsnotify.Write), ev.Has(fsnotify.Chmod):
+		w.mark(rel, "modify")
+	case ev.Has(fsnotify.Rename):
+		if old, ok := w.files[rel]; ok {
+			w.renamed = append(w.renamed, old)
+		}
+		w.markTree(rel, "delete")
+	case ev.Has(fsnotify.Remove):
+		w.markTree(rel, "delete")
+	}
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.debounce, w.flush)
+	} else {
+		w.timer.Reset(w.debounce)
+	}
+}
+
+// mark acumula el cambio de una ruta; se asume que mutex está tomado.
+// create+delete se anulan (archivo temporal) y delete+create equivale a modify
+// (editores que reemplazan el archivo al guardar).
+func (w *Watcher) mark(rel, kind string) {
+	prev, ok := w.pending[rel]
+	if !ok {
+		w.pending[rel] = kind
+		return
+	}
+	switch {
+	case prev == "create" && kind == "delete":
+		if _, existed := w.files[rel]; existed {
+			w.pending[rel] = "delete"
+		} else {
+			delete(w.pending, rel)
+		}
+	case prev == "delete" && kind == "create":
+		w.pending[rel] = "modify"
+	case prev == "create" && kind == "modify":
+		// sigue siendo una creación
+	default:
+		w.pending[rel] = kind
+	}
+}
+
+// markTree marca como eliminada una ruta y todo lo que había debajo
+func (w *Watcher) markTree(rel, kind string) {
+	w.mark(rel, kind)
+	for name := range w.files {
+		if strings.HasPrefix(name, rel+"/") {
+			w.mark(name, kind)
+		}
+	}
+}
+
+// flush aplica los cambios acumulados al listado y avisa a los suscriptores
+func (w *Watcher) flush() {
+	w.mutex.Lock()
+	pending := w.pending
+	renamed := w.renamed
+	w.pending = make(map[string]string)
+	w.renamed = nil
+	w.timer = nil
+
+	var events []WatchEvent
+	for rel, kind := range pending {
+		info, err := os.Stat(filepath.Join(w.root, rel))
+		if err != nil {
+			if _, existed := w.files[rel]; !existed {
+				continue
+			}
+			delete(w.files, rel)
+			state.ForgetVersion(rel)
+			state.RecordListingChange(rel, nil)
+			index.Local.Remove(rel)
+			chunk.Local.Remove(rel)
+			events = append(events, WatchEvent{Type: "delete", Name: rel})
+			continue
+		}
+		if _, existed := w.files[rel]; !existed {
+			kind = "create"
+		} else if kind == "delete" {
+			kind = "modify"
+		}
+		f := fileInfoFor(rel, info)
+		if !f.IsDir && f.Hash == "" {
+			index.Local.Enqueue(rel)
+		}
+		w.files[rel] = f
+		state.RecordListingChange(rel, &f)
+		events = append(events, WatchEvent{Type: kind, Name: rel})
+	}
+	events = pairRenames(events, renamed, w.files)
+	subscribers := append([]func([]WatchEvent){}, w.subscribers...)
+	w.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	for _, fn := range subscribers {
+		fn(events)
+	}
+}
+
+// pairRenames convierte un par delete+create en rename cuando el archivo nuevo
+// conserva el tamaño y la fecha de modificación del que desapareció
+func pairRenames(events []WatchEvent, renamed []state.FileInfo, files map[string]state.FileInfo) []WatchEvent {
+	if len(renamed) == 0 {
+		return events
+	}
+	used := make(map[int]bool)
+	var result []WatchEvent
+	for _, old := range renamed {
+		for i, ev := range events {
+			if used[i] || ev.Type != "create" {
+				continue
+			}
+			nf := files[ev.Name]
+			if nf.IsDir == old.IsDir && nf.Size == old.Size && nf.ModTime.Equal(old.ModTime) {
+				events[i] = WatchEvent{Type: "rename", Name: ev.Name, OldName: old.Name}
+				for j, other := range events {
+					if other.Type == "delete" && other.Name == old.Name {
+						used[j] = true
+					}
+				}
+				break
+			}
+		}
+	}
+	for i, ev := range events {
+		if !used[i] {
+			result = append(result, ev)
+		}
+	}
+	return result
+}
+
+// fallbackScan recorre shared periódicamente para recuperar eventos perdidos
+// (desbordes de inotify, carpetas creadas antes de empezar a vigilarlas, etc.)
+func (w *Watcher) fallbackScan(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		current := walkShared()
+		w.mutex.Lock()
+		seen := make(map[string]bool, len(current))
+		for _, f := range current {
+			seen[f.Name] = true
+			old, ok := w.files[f.Name]
+			if !ok {
+				if f.IsDir {
+					w.addRecursive(filepath.Join(w.root, f.Name))
+				}
+				w.mark(f.Name, "create")
+			} else if !old.ModTime.Equal(f.ModTime) || old.Size != f.Size {
+				w.mark(f.Name, "modify")
+			}
+		}
+		for name := range w.files {
+			if !seen[name] {
+				w.mark(name, "delete")
+			}
+		}
+		hasPending := len(w.pending) > 0
+		w.mutex.Unlock()
+
+		if hasPending {
+			w.flush()
+		}
+	}
+}
+
+// addRecursive vigila una carpeta y todas sus subcarpetas
+func (w *Watcher) addRecursive(dir string) {
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if err := w.fsw.Add(path); err != nil {
+			fmt.Println("⚠️ No se pudo vigilar", path, ":", err)
+		}
+		return nil
+	})
+}
+
+// isTemporaryFile reconoce los archivos auxiliares que crean los editores al guardar
+func isTemporaryFile(name string) bool {
+	switch {
+	case name == "4913", // prueba de escritura de vim
+		strings.HasSuffix(name, "~"),
+		strings.HasPrefix(name, ".#"),
+		strings.HasPrefix(name, ".~lock."),
+		strings.HasPrefix(name, "~$"):
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".swp", ".swx", ".tmp", ".part", ".crdownload":
+		return true
+	}
+	return false
+}
+
+// sortByPath ordena como filepath.Walk: cada carpeta antes que su contenido
+func sortByPath(files []state.FileInfo) {
+	sort.Slice(files, func(i, j int) bool {
+		a := strings.Split(files[i].Name, "/")
+		b := strings.Split(files[j].Name, "/")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
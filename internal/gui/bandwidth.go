@@ -0,0 +1,134 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/compress"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/throttle"
+)
+
+// newBandwidthTab permite editar los límites de ancho de banda y las ventanas
+// horarias; los cambios se guardan en config/bandwidth.json y se aplican al instante
+func newBandwidthTab(peerSystem *peer.Peer) fyne.CanvasObject {
+	cfg := throttle.Current()
+
+	uploadEntry := newRateEntry(cfg.UploadKBps)
+	downloadEntry := newRateEntry(cfg.DownloadKBps)
+
+	type peerRow struct {
+		id       int
+		upload   *widget.Entry
+		download *widget.Entry
+	}
+	var rows []peerRow
+	peerForm := container.NewGridWithColumns(3,
+		widget.NewLabel("Máquina"), widget.NewLabel("Subida (KB/s)"), widget.NewLabel("Bajada (KB/s)"),
+	)
+	for _, p := range peerSystem.Peers {
+		if p.ID == peerSystem.Local.ID {
+			continue
+		}
+		l := cfg.Peers[p.ID]
+		row := peerRow{id: p.ID, upload: newRateEntry(l.UploadKBps), download: newRateEntry(l.DownloadKBps)}
+		rows = append(rows, row)
+		peerForm.Add(widget.NewLabel(fmt.Sprintf("Maq%d (%s)", p.ID, p.IP)))
+		peerForm.Add(row.upload)
+		peerForm.Add(row.download)
+	}
+
+	windowsEntry := widget.NewMultiLineEntry()
+	windowsEntry.SetPlaceHolder("pending=20:00-07:00\nbulk=22:00-06:00")
+	windowsEntry.SetText(strings.ReplaceAll(throttle.FormatWindows(cfg.Windows), ",", "\n"))
+	windowsEntry.SetMinRowsVisible(4)
+
+	resultLabel := widget.NewLabel("0 = sin límite. Sin ventanas, todo se transfiere en cualquier horario.")
+
+	statsLabel := widget.NewLabel("Compresión: " + compress.CurrentStats().String())
+	statsButton := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		statsLabel.SetText("Compresión: " + compress.CurrentStats().String())
+	})
+
+	saveButton := widget.NewButtonWithIcon("Guardar", theme.DocumentSaveIcon(), func() {
+		var next throttle.Config
+		var err error
+		if next.UploadKBps, err = parseRate(uploadEntry.Text); err != nil {
+			resultLabel.SetText("❌ Subida global: " + err.Error())
+			return
+		}
+		if next.DownloadKBps, err = parseRate(downloadEntry.Text); err != nil {
+			resultLabel.SetText("❌ Bajada global: " + err.Error())
+			return
+		}
+		next.Peers = make(map[int]throttle.Limit)
+		for _, row := range rows {
+			var l throttle.Limit
+			if l.UploadKBps, err = parseRate(row.upload.Text); err != nil {
+				resultLabel.SetText(fmt.Sprintf("❌ Subida de Maq%d: %v", row.id, err))
+				return
+			}
+			if l.DownloadKBps, err = parseRate(row.download.Text); err != nil {
+				resultLabel.SetText(fmt.Sprintf("❌ Bajada de Maq%d: %v", row.id, err))
+				return
+			}
+			if l != (throttle.Limit{}) {
+				next.Peers[row.id] = l
+			}
+		}
+		if next.Windows, err = throttle.ParseWindows(windowsEntry.Text); err != nil {
+			resultLabel.SetText("❌ " + err.Error())
+			return
+		}
+		if err := throttle.Apply(next); err != nil {
+			resultLabel.SetText("❌ " + err.Error())
+			return
+		}
+		resultLabel.SetText("✅ Límites guardados y aplicados")
+	})
+
+	globalForm := widget.NewForm(
+		widget.NewFormItem("Subida global (KB/s)", uploadEntry),
+		widget.NewFormItem("Bajada global (KB/s)", downloadEntry),
+	)
+
+	return container.NewVScroll(container.NewVBox(
+		widget.NewLabelWithStyle("Límites globales", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		globalForm,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Límites por máquina", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		peerForm,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Ventanas horarias (clase=desde-hasta, una por línea)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("pending: operaciones pendientes al reconectarse · bulk: carpetas y relays"),
+		windowsEntry,
+		container.NewHBox(saveButton),
+		resultLabel,
+		widget.NewSeparator(),
+		container.NewBorder(nil, nil, nil, statsButton, statsLabel),
+	))
+}
+
+func newRateEntry(kbps int) *widget.Entry {
+	e := widget.NewEntry()
+	e.SetText(strconv.Itoa(kbps))
+	return e
+}
+
+func parseRate(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("valor inválido %q", text)
+	}
+	return n, nil
+}
@@ -0,0 +1,83 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/fs"
+	"p2pfs/internal/state"
+)
+
+// newConflictsTab lista los conflictos sin resolver y permite elegir qué versión conservar.
+// Devuelve el contenido de la pestaña y una función para refrescarla.
+func newConflictsTab(win fyne.Window) (fyne.CanvasObject, func()) {
+	var conflicts []state.Conflict
+	statusLabel := widget.NewLabel("")
+
+	var list *widget.List
+	refresh := func() {
+		conflicts = state.GetConflicts()
+		list.Refresh()
+		statusLabel.SetText(fmt.Sprintf("%d conflicto(s) sin resolver", len(conflicts)))
+	}
+
+	list = widget.NewList(
+		func() int { return len(conflicts) },
+		func() fyne.CanvasObject {
+			return container.NewHBox(
+				widget.NewIcon(theme.WarningIcon()),
+				widget.NewLabel(""),
+				layout.NewSpacer(),
+				widget.NewButton("Resolver", nil),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			c := conflicts[id]
+			row := obj.(*fyne.Container)
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%s ↔ Maq%d (%s) — copia: %s",
+				c.Path, c.PeerID, c.Detected.Format("02-Jan 15:04"), c.ConflictCopy))
+			row.Objects[3].(*widget.Button).OnTapped = func() {
+				showResolveDialog(win, c, func(err error) {
+					if err != nil {
+						statusLabel.SetText("❌ " + err.Error())
+						return
+					}
+					refresh()
+				})
+			}
+		},
+	)
+
+	refreshButton := widget.NewButtonWithIcon("Actualizar", theme.ViewRefreshIcon(), refresh)
+	refresh()
+
+	return container.NewBorder(container.NewHBox(refreshButton, statusLabel), nil, nil, nil, list), refresh
+}
+
+// showResolveDialog ofrece conservar la versión local, la remota o ambas
+func showResolveDialog(win fyne.Window, c state.Conflict, done func(error)) {
+	var d dialog.Dialog
+	resolve := func(choice string) func() {
+		return func() {
+			d.Hide()
+			done(fs.ResolveConflict(c.Path, choice))
+		}
+	}
+
+	info := widget.NewLabel(fmt.Sprintf(
+		"%s fue modificado en este nodo y en Maq%d sin sincronizarse.\nLa versión de Maq%d se guardó como %s.",
+		c.Path, c.PeerID, c.PeerID, c.ConflictCopy))
+	buttons := container.NewHBox(
+		widget.NewButton("Conservar la mía", resolve("mine")),
+		widget.NewButton("Conservar la de Maq"+fmt.Sprint(c.PeerID), resolve("theirs")),
+		widget.NewButton("Conservar ambas", resolve("both")),
+	)
+	d = dialog.NewCustom("Resolver conflicto", "Cancelar", container.NewVBox(info, buttons), win)
+	d.Show()
+}
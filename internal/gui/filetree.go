@@ -0,0 +1,504 @@
+package gui
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/state"
+)
+
+// peerTree muestra el listado de un peer en un widget.Tree: solo se crean las
+// filas visibles y al llegar un listado nuevo se refrescan únicamente las
+// entradas que cambiaron, así se conservan el scroll, las carpetas abiertas y
+// la selección. La selección es propia (no la del widget.Tree) para admitir
+// varios elementos con ctrl/shift + clic. Todos sus métodos deben llamarse
+// desde el hilo de la GUI.
+type peerTree struct {
+	peerID   int
+	local    bool
+	tree     *widget.Tree
+	files    map[string]state.FileInfo
+	children map[string][]string // "" es la raíz
+	suffixes map[string]string   // marcas de operaciones pendientes y conflictos
+
+	selection map[string]bool
+	anchor    string           // último elemento elegido, inicio de un rango con shift
+	modifier  fyne.KeyModifier // teclas presionadas en el último clic
+
+	dragItems []string // elementos que se están arrastrando
+	dragPos   fyne.Position
+
+	editing string // elemento que se está renombrando en su fila
+
+	onSelectionChanged func()
+	onOpen             func(name string)
+	onRename           func(name, text string)
+	onContextMenu      func(name string, pos fyne.Position) // pos absoluta en la ventana
+	onDragStart        func(names []string)
+	onDrop             func(names []string, pos fyne.Position) // pos absoluta en la ventana
+}
+
+func newPeerTree(peerID int, local bool) *peerTree {
+	t := &peerTree{
+		peerID:    peerID,
+		local:     local,
+		files:     make(map[string]state.FileInfo),
+		children:  make(map[string][]string),
+		suffixes:  make(map[string]string),
+		selection: make(map[string]bool),
+	}
+	t.tree = widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID { return t.children[uid] },
+		func(uid widget.TreeNodeID) bool { return uid == "" || t.files[uid].IsDir },
+		func(branch bool) fyne.CanvasObject { return newFileRow(t) },
+		func(uid widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			obj.(*fileRow).set(uid, t.files[uid], t.suffixes[uid], t.selection[uid])
+		},
+	)
+	return t
+}
+
+// SetFiles reemplaza el listado comparándolo con el anterior
+func (t *peerTree) SetFiles(files []state.FileInfo) {
+	next := make(map[string]state.FileInfo, len(files))
+	for _, f := range files {
+		name := strings.Trim(strings.TrimPrefix(f.Name, "shared/"), "/")
+		if name == "" || name == "." {
+			continue
+		}
+		f.Name = name
+		next[name] = f
+		// Un listado puede traer archivos sin la entrada de su carpeta
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := next[dir]; ok {
+				break
+			}
+			next[dir] = state.FileInfo{Name: dir, IsDir: true}
+		}
+	}
+	suffixes := t.pendingSuffixes(next)
+
+	structural := len(next) != len(t.files)
+	var changed []string
+	for name, f := range next {
+		old, ok := t.files[name]
+		switch {
+		case !ok || old.IsDir != f.IsDir:
+			structural = true
+		case !sameEntry(old, f) || suffixes[name] != t.suffixes[name]:
+			changed = append(changed, name)
+		}
+	}
+	if !structural && len(changed) == 0 {
+		return
+	}
+
+	t.files = next
+	t.suffixes = suffixes
+	if structural {
+		t.children = buildChildren(next)
+		dropped := false
+		for name := range t.selection {
+			if _, ok := next[name]; !ok {
+				delete(t.selection, name)
+				dropped = true
+			}
+		}
+		t.tree.Refresh()
+		if dropped {
+			t.selectionChanged()
+		}
+		return
+	}
+	for _, name := range changed {
+		t.tree.RefreshItem(name)
+	}
+}
+
+// Selected devuelve los elementos seleccionados en orden alfabético
+func (t *peerTree) Selected() []string {
+	names := make([]string, 0, len(t.selection))
+	for name := range t.selection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ClearSelection quita la selección sin avisar (se usa al elegir en otro panel)
+func (t *peerTree) ClearSelection() {
+	if len(t.selection) == 0 {
+		return
+	}
+	t.selection = make(map[string]bool)
+	t.anchor = ""
+	t.tree.Refresh()
+}
+
+// SelectAllIn selecciona todo lo que está directamente dentro de la carpeta del
+// último elemento elegido (o de la raíz), abriéndola si hace falta
+func (t *peerTree) SelectAllIn() {
+	dir := ""
+	if t.anchor != "" {
+		dir = path.Dir(t.anchor)
+		if t.files[t.anchor].IsDir && t.tree.IsBranchOpen(t.anchor) {
+			dir = t.anchor
+		}
+		if dir == "." {
+			dir = ""
+		}
+	}
+	if dir != "" {
+		t.tree.OpenBranch(dir)
+	}
+	t.selection = make(map[string]bool)
+	for _, name := range t.children[dir] {
+		t.selection[name] = true
+	}
+	t.tree.Refresh()
+	t.selectionChanged()
+}
+
+// tap aplica un clic según las teclas presionadas: solo, ctrl (alternar) o shift (rango)
+func (t *peerTree) tap(name string) {
+	switch {
+	case t.modifier&(fyne.KeyModifierControl|fyne.KeyModifierSuper) != 0:
+		if t.selection[name] {
+			delete(t.selection, name)
+		} else {
+			t.selection[name] = true
+		}
+		t.anchor = name
+	case t.modifier&fyne.KeyModifierShift != 0 && t.anchor != "":
+		t.selection = make(map[string]bool)
+		inRange := false
+		for _, uid := range t.visible("") {
+			edge := uid == name || uid == t.anchor
+			if edge || inRange {
+				t.selection[uid] = true
+			}
+			if edge && name != t.anchor {
+				inRange = !inRange
+			}
+		}
+	default:
+		t.selection = map[string]bool{name: true}
+		t.anchor = name
+	}
+	t.tree.Refresh()
+	t.selectionChanged()
+}
+
+// visible devuelve los elementos que se ven bajo uid, en el orden en que se muestran
+func (t *peerTree) visible(uid string) []string {
+	var out []string
+	for _, child := range t.children[uid] {
+		out = append(out, child)
+		if t.files[child].IsDir && t.tree.IsBranchOpen(child) {
+			out = append(out, t.visible(child)...)
+		}
+	}
+	return out
+}
+
+// drag sigue un arrastre desde name. Si name no está seleccionado se arrastra
+// solo ese elemento; si lo está, toda la selección.
+func (t *peerTree) drag(name string, pos fyne.Position) {
+	if t.dragItems == nil {
+		if !t.selection[name] {
+			t.modifier = 0
+			t.tap(name)
+		}
+		t.dragItems = t.Selected()
+		if t.onDragStart != nil {
+			t.onDragStart(t.dragItems)
+		}
+	}
+	t.dragPos = pos
+}
+
+func (t *peerTree) dragEnd() {
+	items := t.dragItems
+	t.dragItems = nil
+	if len(items) > 0 && t.onDrop != nil {
+		t.onDrop(items, t.dragPos)
+	}
+}
+
+func (t *peerTree) selectionChanged() {
+	if t.onSelectionChanged != nil {
+		t.onSelectionChanged()
+	}
+}
+
+// Has indica si el listado actual contiene name
+func (t *peerTree) Has(name string) bool {
+	_, ok := t.files[name]
+	return ok
+}
+
+// Info devuelve la entrada de name en el listado actual
+func (t *peerTree) Info(name string) (state.FileInfo, bool) {
+	f, ok := t.files[name]
+	return f, ok
+}
+
+// Reveal abre las carpetas que contienen name
+func (t *peerTree) Reveal(name string) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		t.tree.OpenBranch(dir)
+	}
+}
+
+// Focus muestra name como único elemento seleccionado y lleva la vista hasta él
+func (t *peerTree) Focus(name string) {
+	if !t.Has(name) {
+		return
+	}
+	t.Reveal(name)
+	t.selection = map[string]bool{name: true}
+	t.anchor = name
+	t.tree.Refresh()
+	t.tree.ScrollTo(name)
+	t.selectionChanged()
+}
+
+// StartRename muestra un campo de texto en la fila de name para cambiarle el nombre
+func (t *peerTree) StartRename(name string) {
+	if !t.Has(name) || t.onRename == nil {
+		return
+	}
+	t.editing = name
+	t.Focus(name)
+	t.tree.RefreshItem(name)
+}
+
+// finishRename cierra la edición; si el texto cambió se avisa a onRename, que
+// lo interpreta con fs.RenamedPath (un texto con / mueve el elemento)
+func (t *peerTree) finishRename(name, text string) {
+	if t.editing != name {
+		return
+	}
+	t.editing = ""
+	t.tree.RefreshItem(name)
+	text = strings.TrimSpace(text)
+	if text == "" || text == path.Base(name) {
+		return
+	}
+	t.onRename(name, text)
+}
+
+// activate responde al doble clic: abre o cierra carpetas y delega el resto
+func (t *peerTree) activate(name string) {
+	if t.files[name].IsDir {
+		t.tree.ToggleBranch(name)
+		return
+	}
+	if t.onOpen != nil {
+		t.onOpen(name)
+	}
+}
+
+func (t *peerTree) pendingSuffixes(files map[string]state.FileInfo) map[string]string {
+	suffixes := make(map[string]string)
+	for _, ops := range state.GetAllPendingOps() {
+		for _, op := range ops {
+			name := op.FilePath
+			if op.NewPath != "" {
+				// El listado conocido ya muestra el renombre o la copia
+				name = op.NewPath
+			}
+			if op.TargetID != t.peerID || suffixes[name] != "" {
+				continue
+			}
+			suffixes[name] = " " + pendingIcon(op.Type)
+		}
+	}
+	if t.local {
+		for name := range files {
+			if state.HasConflict(name) {
+				suffixes[name] += " ⚠️"
+			}
+		}
+	}
+	return suffixes
+}
+
+func sameEntry(a, b state.FileInfo) bool {
+	return a.Size == b.Size && a.ModTime.Equal(b.ModTime) && a.Hash == b.Hash
+}
+
+// buildChildren arma la lista de hijos de cada carpeta: carpetas primero y luego por nombre
+func buildChildren(files map[string]state.FileInfo) map[string][]string {
+	children := make(map[string][]string)
+	for name := range files {
+		parent := path.Dir(name)
+		if parent == "." {
+			parent = ""
+		}
+		children[parent] = append(children[parent], name)
+	}
+	for _, list := range children {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := files[list[i]], files[list[j]]
+			if a.IsDir != b.IsDir {
+				return a.IsDir
+			}
+			return strings.ToLower(list[i]) < strings.ToLower(list[j])
+		})
+	}
+	return children
+}
+
+// fileRow es la fila reutilizable del árbol. Atiende el clic (selección) y el
+// doble clic por su cuenta porque tapa al nodo del árbol.
+type fileRow struct {
+	widget.BaseWidget
+	owner      *peerTree
+	uid        string
+	background *canvas.Rectangle
+	icon       *widget.Icon
+	label      *widget.Label
+	entry      *renameEntry
+}
+
+func newFileRow(owner *peerTree) *fileRow {
+	r := &fileRow{
+		owner:      owner,
+		background: canvas.NewRectangle(theme.Color(theme.ColorNameSelection)),
+		icon:       widget.NewIcon(nil),
+		label:      widget.NewLabel(""),
+	}
+	r.label.Truncation = fyne.TextTruncateEllipsis
+	r.entry = newRenameEntry(func(text string) { owner.finishRename(r.uid, text) })
+	r.entry.Hide()
+	r.ExtendBaseWidget(r)
+	return r
+}
+
+func (r *fileRow) set(uid string, f state.FileInfo, suffix string, selected bool) {
+	r.uid = uid
+	r.background.Hidden = !selected
+	name := path.Base(uid)
+	mod := f.ModTime.Format("02-Jan 15:04")
+	text := fmt.Sprintf("%s (%s)%s", name, mod, suffix)
+	if f.ModTime.IsZero() {
+		text = name + suffix
+	}
+	if !f.IsDir {
+		text = fmt.Sprintf("%s (%s, %s)%s", name, mod, formatSize(f.Size), suffix)
+	}
+	r.icon.SetResource(getIconForFile(name, f.IsDir))
+	r.label.SetText(text)
+
+	editing := uid != "" && uid == r.owner.editing
+	if editing == r.entry.Visible() {
+		return
+	}
+	if !editing {
+		r.entry.Hide()
+		r.label.Show()
+		return
+	}
+	r.label.Hide()
+	r.entry.SetText(name)
+	r.entry.Show()
+	// El foco se pide después de que la fila quede en el canvas
+	fyne.Do(func() {
+		if c := fyne.CurrentApp().Driver().CanvasForObject(r.entry); c != nil {
+			c.Focus(r.entry)
+		}
+	})
+}
+
+func (r *fileRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewStack(r.background, container.NewBorder(nil, nil, r.icon, nil, container.NewStack(r.label, r.entry))))
+}
+
+// MouseDown guarda las teclas presionadas para el Tapped que sigue
+func (r *fileRow) MouseDown(ev *desktop.MouseEvent) {
+	r.owner.modifier = ev.Modifier
+}
+
+func (r *fileRow) MouseUp(*desktop.MouseEvent) {}
+
+func (r *fileRow) Tapped(*fyne.PointEvent) {
+	r.owner.tap(r.uid)
+}
+
+// Dragged y DragEnd permiten arrastrar la fila hacia el panel de otra máquina
+func (r *fileRow) Dragged(ev *fyne.DragEvent) {
+	r.owner.drag(r.uid, ev.AbsolutePosition)
+}
+
+func (r *fileRow) DragEnd() {
+	r.owner.dragEnd()
+}
+
+// TappedSecondary abre el menú contextual; si la fila no estaba seleccionada
+// pasa a ser la única selección, como en un explorador de archivos
+func (r *fileRow) TappedSecondary(ev *fyne.PointEvent) {
+	if !r.owner.selection[r.uid] {
+		r.owner.modifier = 0
+		r.owner.tap(r.uid)
+	}
+	if r.owner.onContextMenu != nil {
+		r.owner.onContextMenu(r.uid, ev.AbsolutePosition)
+	}
+}
+
+func (r *fileRow) DoubleTapped(*fyne.PointEvent) {
+	r.owner.modifier = 0
+	r.owner.tap(r.uid)
+	r.owner.activate(r.uid)
+}
+
+// renameEntry es el campo del renombre en línea: Enter o perder el foco confirman
+// y Escape descarta el cambio
+type renameEntry struct {
+	widget.Entry
+	done     func(text string)
+	finished bool
+}
+
+func newRenameEntry(done func(text string)) *renameEntry {
+	e := &renameEntry{done: done}
+	e.ExtendBaseWidget(e)
+	e.OnSubmitted = func(text string) { e.finish(text) }
+	return e
+}
+
+func (e *renameEntry) SetText(text string) {
+	e.finished = false
+	e.Entry.SetText(text)
+}
+
+func (e *renameEntry) TypedKey(ev *fyne.KeyEvent) {
+	if ev.Name == fyne.KeyEscape {
+		e.finish("")
+		return
+	}
+	e.Entry.TypedKey(ev)
+}
+
+func (e *renameEntry) FocusLost() {
+	e.Entry.FocusLost()
+	e.finish(e.Text)
+}
+
+func (e *renameEntry) finish(text string) {
+	if e.finished {
+		return
+	}
+	e.finished = true
+	e.done(text)
+}
@@ -7,8 +7,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
-	"strings"
-	"time"
 
 	"image/color"
 
@@ -16,12 +14,14 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
-	"p2pfs/internal/peer"
 	"p2pfs/internal/fs"
+	"p2pfs/internal/peer"
 	"p2pfs/internal/state"
 )
 
@@ -38,14 +38,24 @@ func Run(peerSystem *peer.Peer) {
 	grid := container.NewGridWithColumns(2)
 	machinePanels := make(map[int]*fyne.Container)
 	machineStates := make(map[int]*widget.Label)
-	machineFileLists := make(map[int]*fyne.Container)
-	expandedDirs := make(map[int]map[string]bool)
+	trees := make(map[int]*peerTree)
 
 	scroll := container.NewVScroll(grid)
 	scroll.SetMinSize(fyne.NewSize(1000, 600))
 
-	var selectedFile *fs.SelectedFile
-	var selectedButton *widget.Button
+	// La selección vive en un solo panel a la vez: activePeer indica cuál
+	activePeer := peerSystem.Local.ID
+	selection := func() []fs.SelectedFile {
+		t, ok := trees[activePeer]
+		if !ok {
+			return nil
+		}
+		var items []fs.SelectedFile
+		for _, name := range t.Selected() {
+			items = append(items, fs.SelectedFile{FileName: name, PeerID: activePeer})
+		}
+		return items
+	}
 	localID := peerSystem.Local.ID
 
 	peerChecks := container.NewVBox()
@@ -67,73 +77,227 @@ func Run(peerSystem *peer.Peer) {
 	})
 	peerChecks.Add(selectAllCheck)
 
-	deleteButton := widget.NewButtonWithIcon("Eliminar", theme.DeleteIcon(), func() {
-		if selectedFile == nil {
+	// showFiles actualiza el árbol de un peer; se puede llamar desde cualquier goroutine
+	showFiles := func(peerID int, files []state.FileInfo) {
+		fyne.Do(func() {
+			if t, ok := trees[peerID]; ok {
+				t.SetFiles(files)
+			}
+		})
+	}
+
+	// reportBatch muestra el resumen de una operación sobre varios elementos y,
+	// si hubo errores, el detalle de cada uno
+	reportBatch := func(action string, report fs.BatchReport) {
+		fyne.Do(func() {
+			if len(report.Failed()) == 0 {
+				statusLabel.SetText(fmt.Sprintf("✅ %s: %s.", action, report.Summary()))
+				return
+			}
+			statusLabel.SetText(fmt.Sprintf("⚠️ %s: %s.", action, report.Summary()))
+			dialog.ShowInformation(action+": errores", report.Details(), myWindow)
+		})
+	}
+
+	// startDelete elimina los elementos en segundo plano
+	startDelete := func(items []fs.SelectedFile) {
+		if len(items) == 0 {
 			statusLabel.SetText("❌ Selecciona un archivo para eliminar.")
 			return
 		}
-		err := fs.DeleteFile(peerSystem, *selectedFile)
+		statusLabel.SetText(fmt.Sprintf("🗑️ Eliminando %d elemento(s)...", len(items)))
+		go reportBatch("Eliminación", fs.DeleteFiles(peerSystem, items))
+	}
+
+	// startTransfer transfiere los elementos en segundo plano hacia los peers
+	// marcados; sin destinos, los elementos remotos se traen a este nodo
+	startTransfer := func(items []fs.SelectedFile, checked map[int]bool) {
+		if len(items) == 0 {
+			statusLabel.SetText("❌ Selecciona un archivo para transferir.")
+			return
+		}
+		targets := 0
+		for _, ok := range checked {
+			if ok {
+				targets++
+			}
+		}
+		if targets == 0 {
+			targets = 1
+		}
+		statusLabel.SetText(fmt.Sprintf("📤 Transfiriendo %d elemento(s) hacia %d máquina(s)... (ver Transferencias)", len(items), targets))
+
+		// La transferencia corre en segundo plano; el avance se ve en la pestaña Transferencias
+		go func() {
+			reportBatch("Transferencia", fs.TransferFiles(peerSystem, items, checked))
+
+			showFiles(localID, fs.ListSharedFiles())
+			for _, p := range peerSystem.Peers {
+				if p.ID == localID {
+					continue
+				}
+				files, _ := fs.GetLocalOrRemoteFileList(peerSystem, p.ID)
+				showFiles(p.ID, files)
+				if checked[p.ID] {
+					id := p.ID
+					fyne.Do(func() {
+						for _, item := range items {
+							trees[id].Reveal(filepath.ToSlash(item.FileName))
+						}
+					})
+				}
+			}
+		}()
+	}
+
+	// refreshPeer vuelve a mostrar el listado de un peer; si no responde se usa el
+	// último listado conocido, que ya refleja las operaciones pendientes
+	refreshPeer := func(peerID int) {
+		files, err := fs.GetLocalOrRemoteFileList(peerSystem, peerID)
 		if err != nil {
-			statusLabel.SetText("❌ " + err.Error())
-		} else {
-			statusLabel.SetText("🗑️ Eliminación solicitada.")
+			for _, p := range peerSystem.Peers {
+				if p.ID == peerID {
+					files = state.CachedFiles(p.IP)
+				}
+			}
 		}
-	})
+		showFiles(peerID, files)
+	}
 
-	var fileCache = make(map[int][]state.FileInfo)
+	// renameItem renombra o mueve en segundo plano y refresca el panel del peer
+	renameItem := func(peerID int, name, text string) {
+		newName := fs.RenamedPath(name, text)
+		statusLabel.SetText(fmt.Sprintf("✏️ Renombrando %s → %s...", name, newName))
+		go func() {
+			err := fs.RenameFile(peerSystem, fs.SelectedFile{FileName: name, PeerID: peerID}, newName)
+			refreshPeer(peerID)
+			fyne.Do(func() {
+				if err != nil {
+					statusLabel.SetText("⚠️ " + err.Error())
+					return
+				}
+				statusLabel.SetText(fmt.Sprintf("✅ %s renombrado a %s", name, newName))
+				if t, ok := trees[peerID]; ok {
+					t.Focus(newName)
+				}
+			})
+		}()
+	}
 
-	var renderFileList func(peerID int)
+	// startRename abre el renombre en línea del único elemento seleccionado
+	startRename := func() {
+		t, ok := trees[activePeer]
+		if !ok || len(t.Selected()) != 1 {
+			statusLabel.SetText("❌ Selecciona un solo elemento para renombrar.")
+			return
+		}
+		t.StartRename(t.Selected()[0])
+	}
 
-	transferButton := widget.NewButtonWithIcon("Transferir", theme.MailForwardIcon(), func() {
-		if selectedFile == nil {
-			statusLabel.SetText("❌ Selecciona un archivo para transferir.")
+	// panelAt devuelve el peer cuyo panel contiene la posición absoluta pos, o -1
+	panelAt := func(pos fyne.Position) int {
+		driver := fyne.CurrentApp().Driver()
+		for id, panel := range machinePanels {
+			origin := driver.AbsolutePositionForObject(panel)
+			size := panel.Size()
+			if pos.X >= origin.X && pos.X < origin.X+size.Width && pos.Y >= origin.Y && pos.Y < origin.Y+size.Height {
+				return id
+			}
+		}
+		return -1
+	}
+
+	// dropOnPeer decide la operación al soltar elementos de sourceID sobre el panel
+	// de targetID: enviar (local → remoto), traer (remoto → local) o relay (remoto → remoto)
+	dropOnPeer := func(sourceID int, names []string, targetID int) {
+		if targetID < 0 || targetID == sourceID {
+			statusLabel.SetText("Arrastre cancelado")
 			return
 		}
+		items := make([]fs.SelectedFile, len(names))
+		for i, name := range names {
+			items[i] = fs.SelectedFile{FileName: name, PeerID: sourceID}
+		}
+		checked := make(map[int]bool)
+		if targetID != localID {
+			checked[targetID] = true
+		}
+		startTransfer(items, checked)
+	}
 
+	// Archivos soltados desde el administrador de archivos del sistema: se copian
+	// a shared y, si se sueltan sobre otra máquina, se le envían
+	myWindow.SetOnDropped(func(pos fyne.Position, uris []fyne.URI) {
+		var paths []string
+		for _, u := range uris {
+			if u.Scheme() == "file" {
+				paths = append(paths, u.Path())
+			}
+		}
+		if len(paths) == 0 {
+			return
+		}
+		targetID := panelAt(pos)
+		statusLabel.SetText(fmt.Sprintf("📥 Importando %d elemento(s)...", len(paths)))
+		go func() {
+			names, err := fs.ImportPaths(paths)
+			showFiles(localID, fs.ListSharedFiles())
+			fyne.Do(func() {
+				if err != nil {
+					statusLabel.SetText("⚠️ " + err.Error())
+				} else {
+					statusLabel.SetText(fmt.Sprintf("✅ %d elemento(s) importado(s) a shared", len(names)))
+				}
+				if len(names) > 0 && targetID >= 0 && targetID != localID {
+					dropOnPeer(localID, names, targetID)
+				}
+			})
+		}()
+	})
+
+	deleteButton := widget.NewButtonWithIcon("Eliminar", theme.DeleteIcon(), func() {
+		startDelete(selection())
+	})
+
+	transferButton := widget.NewButtonWithIcon("Transferir", theme.MailForwardIcon(), func() {
 		checked := make(map[int]bool)
 		for id, chk := range peerCheckMap {
 			checked[id] = chk.Checked
 		}
+		startTransfer(selection(), checked)
+	})
 
-		n, err := fs.TransferFile(peerSystem, *selectedFile, checked)
-		if err != nil {
-			statusLabel.SetText("⚠️ " + err.Error())
-		} else {
-			statusLabel.SetText(fmt.Sprintf("📤 Archivo enviado a %d máquina(s).", n))
-
-			go func() {
-				localFiles := fs.ListSharedFiles()
-				fileCache[localID] = localFiles
-				renderFileList(localID)
-
-				for id, ok := range checked {
-					if ok {
-						files, _ := fs.GetLocalOrRemoteFileList(peerSystem, id)
-						fileCache[id] = files
-						if len(selectedFile.FileName) > 0 {
-							parent := filepath.Dir(selectedFile.FileName)
-							if parent != "." {
-								expandedDirs[id][parent] = true
-							}
-						}
-						renderFileList(id)
-					}
-				}
-
-				for _, p := range peerSystem.Peers {
-					if p.ID != localID && !checked[p.ID] {
-						files, _ := fs.GetLocalOrRemoteFileList(peerSystem, p.ID)
-						fileCache[p.ID] = files
-						renderFileList(p.ID)
-					}
-				}
-			}()
+	selectAllInFolder := func() {
+		if t, ok := trees[activePeer]; ok {
+			t.SelectAllIn()
+		}
+	}
+	selectAllButton := widget.NewButtonWithIcon("Seleccionar carpeta", theme.CheckButtonCheckedIcon(), selectAllInFolder)
+	menu := &fileMenu{
+		win:        myWindow,
+		peerSystem: peerSystem,
+		trees:      trees,
+		send:       dropOnPeer,
+		remove:     startDelete,
+		refresh:    refreshPeer,
+		status:     statusLabel.SetText,
+	}
+	newFolderButton := widget.NewButtonWithIcon("Nueva carpeta", theme.FolderNewIcon(), func() {
+		menu.mkdir(activePeer, "")
+	})
+	renameButton := widget.NewButtonWithIcon("Renombrar", theme.DocumentCreateIcon(), startRename)
+	myWindow.Canvas().SetOnTypedKey(func(ev *fyne.KeyEvent) {
+		if ev.Name == fyne.KeyF2 {
+			startRename()
 		}
 	})
+	myWindow.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyA, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+		selectAllInFolder()
+	})
 
 	header := container.NewVBox(
 		canvas.NewText("Sistema Distribuido P2P", theme.ForegroundColor()),
-		container.NewHBox(deleteButton, transferButton, layout.NewSpacer(), syncIcon),
+		container.NewHBox(deleteButton, transferButton, renameButton, newFolderButton, selectAllButton, layout.NewSpacer(), syncIcon),
 		container.NewHBox(statusLabel, layout.NewSpacer(), selectedLabel),
 		widget.NewSeparator(),
 		container.NewVBox(
@@ -142,7 +306,46 @@ func Run(peerSystem *peer.Peer) {
 		),
 	)
 
-	myWindow.SetContent(container.NewBorder(header, nil, nil, nil, scroll))
+	conflictsTab, refreshConflicts := newConflictsTab(myWindow)
+
+	// Los resultados de búsqueda se traen como cualquier transferencia; abrir un
+	// archivo que no está aquí muestra su vista previa y una carpeta, su panel
+	var tabs *container.AppTabs
+	fetchResult := func(r fs.SearchResult) {
+		if best, ok := r.Best(); ok {
+			startTransfer([]fs.SelectedFile{{FileName: r.Name, PeerID: best.PeerID}}, nil)
+		}
+	}
+	openResult := func(r fs.SearchResult) {
+		if r.Has(localID) && !r.IsDir {
+			go openFile(r.Name)
+			return
+		}
+		holder := r.Holders[0]
+		if best, ok := r.Best(); ok {
+			holder = best
+		}
+		if !r.IsDir {
+			showPreview(myWindow, peerSystem, holder.PeerID, r.Name)
+			return
+		}
+		if t, ok := trees[holder.PeerID]; ok {
+			tabs.SelectIndex(0)
+			t.Focus(r.Name)
+			statusLabel.SetText(fmt.Sprintf("📍 %s está en Maq%d", r.Name, holder.PeerID))
+		}
+	}
+
+	tabs = container.NewAppTabs(
+		container.NewTabItemWithIcon("Máquinas", theme.ComputerIcon(), scroll),
+		container.NewTabItemWithIcon("Buscar", theme.SearchIcon(), newSearchTab(peerSystem, fetchResult, openResult)),
+		container.NewTabItemWithIcon("Transferencias", theme.UploadIcon(), newTransfersTab()),
+		container.NewTabItemWithIcon("Historial", theme.HistoryIcon(), newHistoryTab(peerSystem)),
+		container.NewTabItemWithIcon("Conflictos", theme.WarningIcon(), conflictsTab),
+		container.NewTabItemWithIcon("Ancho de banda", theme.SettingsIcon(), newBandwidthTab(peerSystem)),
+	)
+
+	myWindow.SetContent(container.NewBorder(header, nil, nil, nil, tabs))
 	myWindow.Show()
 
 	colors := []color.Color{
@@ -163,16 +366,54 @@ func Run(peerSystem *peer.Peer) {
 
 		stateLbl := widget.NewLabel("Desconocido")
 		machineStates[pinfo.ID] = stateLbl
-		fileList := container.NewVBox()
-		machineFileLists[pinfo.ID] = fileList
-		expandedDirs[pinfo.ID] = make(map[string]bool)
 
-		content := container.NewVBox(title, stateLbl, widget.NewSeparator(), fileList)
+		pid := pinfo.ID
+		tree := newPeerTree(pid, pid == localID)
+		tree.onSelectionChanged = func() {
+			// La selección es de un solo panel: elegir en otro limpia la anterior
+			if activePeer != pid {
+				if old, ok := trees[activePeer]; ok {
+					old.ClearSelection()
+				}
+				activePeer = pid
+			}
+			names := trees[pid].Selected()
+			switch len(names) {
+			case 0:
+				selectedLabel.SetText("Archivo seleccionado: ninguno")
+			case 1:
+				selectedLabel.SetText("Archivo seleccionado: " + filepath.Base(names[0]) + " (Maq" + strconv.Itoa(pid) + ")")
+			default:
+				selectedLabel.SetText(fmt.Sprintf("%d elementos seleccionados (Maq%d)", len(names), pid))
+			}
+		}
+		tree.onDragStart = func(names []string) {
+			statusLabel.SetText(fmt.Sprintf("↔️ Suelta sobre otra máquina para transferir %d elemento(s)", len(names)))
+		}
+		tree.onDrop = func(names []string, pos fyne.Position) {
+			dropOnPeer(pid, names, panelAt(pos))
+		}
+		tree.onRename = func(name, text string) {
+			renameItem(pid, name, text)
+		}
+		tree.onContextMenu = func(name string, pos fyne.Position) {
+			menu.show(pid, name, pos)
+		}
+		tree.onOpen = func(name string) {
+			if pid == localID {
+				go openFile(name)
+				return
+			}
+			showPreview(myWindow, peerSystem, pid, name)
+		}
+		trees[pid] = tree
+
+		content := container.NewBorder(container.NewVBox(title, stateLbl, widget.NewSeparator()), nil, nil, nil, tree.tree)
 		border := canvas.NewRectangle(colors[i%len(colors)])
 		border.StrokeWidth = 4
 		border.StrokeColor = colors[i%len(colors)]
 		border.FillColor = color.NRGBA{R: 20, G: 20, B: 20, A: 255}
-		border.SetMinSize(fyne.NewSize(500, 250))
+		border.SetMinSize(fyne.NewSize(500, 350))
 
 		panel := container.NewMax(border, container.NewPadded(content))
 		machinePanels[pinfo.ID] = panel
@@ -188,118 +429,13 @@ func Run(peerSystem *peer.Peer) {
 			}
 		},
 		UpdateFileList: func(peerID int, files []state.FileInfo) {
-			fileCache[peerID] = files
-			renderFileList(peerID)
+			showFiles(peerID, files)
+			if peerID == localID {
+				fyne.Do(refreshConflicts)
+			}
 		},
 	})
 
-		renderFileList = func(peerID int) {
-	files := fileCache[peerID]
-	machineFileLists[peerID].Objects = nil
-	allOps := state.GetAllPendingOps()
-
-	for _, file := range files {
-		depth := strings.Count(file.Name, "/")
-		pathParts := strings.Split(file.Name, "/")
-
-		// Ocultar archivos o carpetas si algún ancestro no está expandido
-		show := true
-		if depth > 0 {
-			for i := 1; i < len(pathParts); i++ {
-				ancestor := strings.Join(pathParts[:i], "/")
-				if !expandedDirs[peerID][ancestor] {
-					show = false
-					break
-				}
-			}
-		}
-		if !show {
-			continue
-		}
-
-		name := filepath.Base(file.Name)
-		mod := file.ModTime.Format("02-Jan 15:04")
-		suffix := ""
-		for _, ops := range allOps {
-			for _, op := range ops {
-				if op.TargetID == peerID && op.FilePath == file.Name {
-					switch op.Type {
-					case "get":
-						suffix = " ⏳"
-					case "send":
-						suffix = " 📤"
-					case "delete":
-						suffix = " 🗑️"
-					}
-					break
-				}
-			}
-		}
-
-		label := fmt.Sprintf("%s (%s)%s", name, mod, suffix)
-		icon := getIconForFile(name, file.IsDir)
-		btn := widget.NewButtonWithIcon(label, icon, nil)
-		btn.Alignment = widget.ButtonAlignLeading
-		btn.Importance = widget.MediumImportance
-
-		pid := peerID
-		fname := file.Name
-		thisBtn := btn
-		var lastClick time.Time
-
-		var arrow *widget.Button
-		if file.IsDir {
-			expanded := expandedDirs[pid][fname]
-			arrow = widget.NewButton("▸", nil)
-			if expanded {
-				arrow.SetText("▼")
-			}
-			arrow.OnTapped = func() {
-				expandedDirs[pid][fname] = !expandedDirs[pid][fname]
-				renderFileList(pid)
-			}
-		}
-
-		btn.OnTapped = func() {
-			now := time.Now()
-			if selectedButton != nil {
-				selectedButton.Importance = widget.MediumImportance
-				selectedButton.Refresh()
-			}
-			clean := strings.TrimPrefix(fname, "shared/")
-			selectedFile = &fs.SelectedFile{FileName: clean, PeerID: pid}
-			selectedButton = thisBtn
-			thisBtn.Importance = widget.HighImportance
-			thisBtn.Refresh()
-			selectedLabel.SetText("Archivo seleccionado: " + name + " (Maq" + strconv.Itoa(pid) + ")")
-
-			if file.IsDir && now.Sub(lastClick) < 500*time.Millisecond {
-				expandedDirs[pid][fname] = !expandedDirs[pid][fname]
-				renderFileList(pid)
-			}
-			if pid == localID && now.Sub(lastClick) < 500*time.Millisecond && !file.IsDir {
-				go openFile(fname)
-			}
-			lastClick = now
-		}
-
-		// 🔽 INDENTACIÓN VISUAL
-		indentSpace := canvas.NewRectangle(color.Transparent)
-		indentSpace.SetMinSize(fyne.NewSize(float32(depth*35), 1)) // 25px por nivel
-
-		row := container.NewHBox()
-		row.Add(indentSpace)
-		if arrow != nil {
-			row.Add(arrow)
-		}
-		row.Add(btn)
-
-			machineFileLists[peerID].Add(row)
-		}
-		machineFileLists[peerID].Refresh()
-	}
-
-
 	myApp.Run()
 }
 
@@ -317,19 +453,32 @@ func openFile(name string) {
 	_ = cmd.Start()
 }
 
+// formatSize muestra un tamaño en bytes con la unidad más cómoda
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func getIconForFile(name string, isDir bool) fyne.Resource {
 	if isDir {
 		return theme.FolderIcon()
 	}
-	ext := strings.ToLower(filepath.Ext(name))
-	switch ext {
-	case ".txt":
+	switch state.FileKind(name) {
+	case "text":
 		return theme.DocumentIcon()
-	case ".mp3":
+	case "audio":
 		return theme.MediaMusicIcon()
-	case ".jpg", ".png":
+	case "image":
 		return theme.MediaPhotoIcon()
-	case ".mp4", ".avi":
+	case "video":
 		return theme.MediaVideoIcon()
 	default:
 		return theme.FileIcon()
@@ -0,0 +1,112 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/log"
+	"p2pfs/internal/peer"
+)
+
+// newHistoryTab construye la pestaña de historial con filtros por peer, ruta, acción y fechas
+func newHistoryTab(peerSystem *peer.Peer) fyne.CanvasObject {
+	var entries []log.LogEntry
+
+	peerEntry := widget.NewEntry()
+	peerEntry.SetPlaceHolder("ID de máquina")
+	pathEntry := widget.NewEntry()
+	pathEntry.SetPlaceHolder("Prefijo de ruta")
+	actionSelect := widget.NewSelect([]string{"Todas", "CREATE", "TRANSFER", "DELETE", "RELAY", "RENAME", "MKDIR", "COPY"}, nil)
+	actionSelect.SetSelected("Todas")
+	sinceEntry := widget.NewEntry()
+	sinceEntry.SetPlaceHolder("Desde (2006-01-02)")
+	untilEntry := widget.NewEntry()
+	untilEntry.SetPlaceHolder("Hasta (2006-01-02)")
+	remoteCheck := widget.NewCheck("Incluir peers remotos", nil)
+	resultLabel := widget.NewLabel("")
+
+	list := widget.NewList(
+		func() int { return len(entries) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(audit.Format(entries[id]))
+		},
+	)
+
+	search := func() {
+		filter := audit.Filter{PathPrefix: strings.TrimSpace(pathEntry.Text)}
+		if txt := strings.TrimSpace(peerEntry.Text); txt != "" {
+			id, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(txt), "maq"))
+			if err != nil {
+				resultLabel.SetText("❌ ID de máquina inválido")
+				return
+			}
+			filter.PeerID = id
+		}
+		if actionSelect.Selected != "Todas" {
+			filter.Action = actionSelect.Selected
+		}
+		var err error
+		if filter.Since, err = parseDate(sinceEntry.Text, false); err != nil {
+			resultLabel.SetText("❌ Fecha inicial inválida")
+			return
+		}
+		if filter.Until, err = parseDate(untilEntry.Text, true); err != nil {
+			resultLabel.SetText("❌ Fecha final inválida")
+			return
+		}
+
+		resultLabel.SetText("🔎 Consultando...")
+		includeRemote := remoteCheck.Checked
+		go func() {
+			var found []log.LogEntry
+			if includeRemote {
+				found = audit.QueryAll(peerSystem.Peers, peerSystem.Local.ID, filter)
+			} else {
+				found, err = audit.Query(filter)
+			}
+			fyne.Do(func() {
+				entries = found
+				list.Refresh()
+				if err != nil {
+					resultLabel.SetText("⚠️ " + err.Error())
+					return
+				}
+				resultLabel.SetText(fmt.Sprintf("%d entrada(s)", len(found)))
+			})
+		}()
+	}
+
+	searchButton := widget.NewButtonWithIcon("Buscar", theme.SearchIcon(), search)
+
+	filters := container.NewVBox(
+		container.NewGridWithColumns(5, peerEntry, pathEntry, actionSelect, sinceEntry, untilEntry),
+		container.NewHBox(remoteCheck, searchButton, resultLabel),
+		widget.NewSeparator(),
+	)
+	return container.NewBorder(filters, nil, nil, nil, list)
+}
+
+// parseDate interpreta una fecha simple; endOfDay la extiende hasta el final del día
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
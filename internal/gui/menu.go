@@ -0,0 +1,307 @@
+package gui
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/audit"
+	"p2pfs/internal/fs"
+	"p2pfs/internal/peer"
+	"p2pfs/internal/state"
+)
+
+// fileMenu arma el menú contextual de las filas de los paneles de máquinas.
+// Las acciones que ya existen en la cabecera se delegan en las mismas funciones
+// de gui.go: send (enviar, traer o relay), remove (eliminar) y refresh.
+type fileMenu struct {
+	win        fyne.Window
+	peerSystem *peer.Peer
+	trees      map[int]*peerTree
+	send       func(sourceID int, names []string, targetID int)
+	remove     func(items []fs.SelectedFile)
+	refresh    func(peerID int)
+	status     func(text string)
+}
+
+// show abre el menú de name (del panel de peerID) en la posición absoluta pos.
+// Enviar, traer y eliminar se aplican a toda la selección; el resto, a name.
+func (m *fileMenu) show(peerID int, name string, pos fyne.Position) {
+	t, ok := m.trees[peerID]
+	if !ok {
+		return
+	}
+	info, _ := t.Info(name)
+	localID := m.peerSystem.Local.ID
+	names := t.Selected()
+	if len(names) == 0 {
+		names = []string{name}
+	}
+	many := ""
+	if len(names) > 1 {
+		many = fmt.Sprintf(" (%d elementos)", len(names))
+	}
+
+	var items []*fyne.MenuItem
+	if !info.IsDir {
+		if peerID == localID {
+			items = append(items, fyne.NewMenuItem("Abrir", func() { go openFile(name) }))
+		}
+		items = append(items, fyne.NewMenuItem("Vista previa", func() { showPreview(m.win, m.peerSystem, peerID, name) }))
+	}
+
+	var targets []*fyne.MenuItem
+	for _, p := range m.peerSystem.Peers {
+		if p.ID == peerID {
+			continue
+		}
+		targetID := p.ID
+		label := fmt.Sprintf("Maq%d (%s)", p.ID, p.IP)
+		if p.ID == localID {
+			label = fmt.Sprintf("Maq%d (esta máquina)", p.ID)
+		}
+		targets = append(targets, fyne.NewMenuItem(label, func() { m.send(peerID, names, targetID) }))
+	}
+	sendTo := fyne.NewMenuItem("Enviar a"+many, nil)
+	sendTo.ChildMenu = fyne.NewMenu("", targets...)
+	items = append(items, sendTo)
+	if peerID != localID {
+		items = append(items, fyne.NewMenuItem("Traer aquí"+many, func() { m.send(peerID, names, localID) }))
+	}
+
+	// Las carpetas nuevas se crean dentro de la carpeta elegida o junto al archivo
+	dir := name
+	if !info.IsDir {
+		dir = path.Dir(name)
+	}
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Renombrar", func() { t.StartRename(name) }),
+		fyne.NewMenuItem("Duplicar...", func() { m.duplicate(peerID, name) }),
+		fyne.NewMenuItem("Nueva carpeta...", func() { m.mkdir(peerID, dir) }),
+		fyne.NewMenuItem("Eliminar"+many, func() { m.confirmDelete(peerID, names) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Copiar ruta", func() { m.copyPath(peerID, name) }),
+		fyne.NewMenuItem("Historial", func() { m.showHistory(name) }),
+	)
+	if !info.IsDir {
+		items = append(items, fyne.NewMenuItem("Versiones", func() { m.showVersions(name) }))
+	}
+	items = append(items, fyne.NewMenuItem("Propiedades", func() { m.showProperties(peerID, name, info) }))
+
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), m.win.Canvas(), pos)
+}
+
+// confirmDelete pide confirmación antes de eliminar los elementos elegidos
+func (m *fileMenu) confirmDelete(peerID int, names []string) {
+	text := fmt.Sprintf("¿Eliminar %s de Maq%d?", names[0], peerID)
+	if len(names) > 1 {
+		text = fmt.Sprintf("¿Eliminar %d elementos de Maq%d?", len(names), peerID)
+	}
+	dialog.ShowConfirm("Eliminar", text, func(ok bool) {
+		if !ok {
+			return
+		}
+		items := make([]fs.SelectedFile, len(names))
+		for i, name := range names {
+			items[i] = fs.SelectedFile{FileName: name, PeerID: peerID}
+		}
+		m.remove(items)
+	}, m.win)
+}
+
+// copyPath copia al portapapeles la ruta absoluta (local) o "MaqN:ruta" (remota)
+func (m *fileMenu) copyPath(peerID int, name string) {
+	text := fmt.Sprintf("Maq%d:%s", peerID, name)
+	if peerID == m.peerSystem.Local.ID {
+		if abs, err := filepath.Abs(filepath.Join("shared", filepath.FromSlash(name))); err == nil {
+			text = abs
+		}
+	}
+	fyne.CurrentApp().Clipboard().SetContent(text)
+	m.status("📋 Ruta copiada: " + text)
+}
+
+// showHistory muestra las entradas del historial de todos los peers sobre name
+// (o sobre lo que haya dentro, si es una carpeta)
+func (m *fileMenu) showHistory(name string) {
+	list := widget.NewLabel("🔎 Consultando historial...")
+	d := dialog.NewCustom("Historial: "+name, "Cerrar", container.NewVScroll(list), m.win)
+	d.Resize(fyne.NewSize(820, 420))
+	d.Show()
+
+	go func() {
+		all := audit.QueryAll(m.peerSystem.Peers, m.peerSystem.Local.ID, audit.Filter{PathPrefix: name})
+		var lines []string
+		for _, e := range all {
+			// El filtro es por prefijo: se descartan "informe.txt.bak" y similares
+			if !samePathOrInside(e.FileName, name) && !samePathOrInside(e.NewName, name) {
+				continue
+			}
+			lines = append(lines, audit.Format(e))
+		}
+		fyne.Do(func() {
+			if len(lines) == 0 {
+				list.SetText("Sin entradas en el historial.")
+				return
+			}
+			list.SetText(strings.Join(lines, "\n"))
+		})
+	}()
+}
+
+// showVersions compara la copia de cada peer contra la local usando los
+// vectores de versión, e indica las copias de conflicto guardadas
+func (m *fileMenu) showVersions(name string) {
+	localID := m.peerSystem.Local.ID
+	copies, _ := fs.FindCopies(m.peerSystem.Peers, localID, name)
+	var local state.FileInfo
+	hasLocal := false
+	for _, h := range copies.Holders {
+		if h.PeerID == localID {
+			local, hasLocal = h.Info, true
+		}
+	}
+
+	var lines []string
+	for _, h := range copies.Holders {
+		line := fmt.Sprintf("Maq%d: [%s]  %s  %s", h.PeerID, h.Info.Version, h.Info.ModTime.Format("2006-01-02 15:04:05"), formatSize(h.Info.Size))
+		if hasLocal && h.PeerID != localID {
+			line += "  — " + describeOrdering(h.Info.Version.Compare(local.Version))
+		}
+		if !h.Online {
+			line += "  (offline, listado anterior)"
+		}
+		lines = append(lines, line)
+	}
+	for _, c := range state.GetConflicts() {
+		if c.Path == name {
+			lines = append(lines, fmt.Sprintf("⚠️ Conflicto con Maq%d: la otra versión está en %s", c.PeerID, c.ConflictCopy))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No hay información de versiones.")
+	}
+	dialog.ShowInformation("Versiones: "+name, strings.Join(lines, "\n"), m.win)
+}
+
+// describeOrdering explica cómo se relaciona una copia remota con la local
+func describeOrdering(o state.Ordering) string {
+	switch o {
+	case state.Equal:
+		return "misma versión"
+	case state.Before:
+		return "más antigua que la local"
+	case state.After:
+		return "más nueva que la local"
+	}
+	return "modificada en paralelo (conflicto)"
+}
+
+// showProperties muestra tamaño, fecha, tipo, hash y qué peers tienen el archivo.
+// El hash local se calcula en segundo plano si el índice todavía no lo tiene.
+func (m *fileMenu) showProperties(peerID int, name string, info state.FileInfo) {
+	localID := m.peerSystem.Local.ID
+	label := widget.NewLabel("")
+	render := func(info state.FileInfo) {
+		text := fmt.Sprintf("Ruta: %s\nMáquina: Maq%d", name, peerID)
+		if info.IsDir {
+			text += "\nTipo: carpeta"
+		} else {
+			hash := info.Hash
+			if hash == "" {
+				hash = "(sin calcular)"
+			}
+			text += fmt.Sprintf("\nTamaño: %s (%d bytes)\nModificado: %s\nTipo: %s\nPermisos: %s\nSHA-256: %s",
+				formatSize(info.Size), info.Size, info.ModTime.Format("2006-01-02 15:04:05"), info.MimeType, info.Mode, hash)
+		}
+		if copies, ok := fs.FindCopies(m.peerSystem.Peers, localID, name); ok {
+			text += "\nEn: " + describeHolders(copies, localID)
+			if !info.IsDir {
+				text += "\nCopias: " + copies.Copies
+			}
+		}
+		label.SetText(text)
+	}
+	render(info)
+	dialog.ShowCustom("Propiedades: "+path.Base(name), "Cerrar", label, m.win)
+
+	if peerID == localID && !info.IsDir && info.Hash == "" {
+		go func() {
+			hash, err := state.EnsureHash(name)
+			if err != nil {
+				return
+			}
+			info.Hash = hash
+			fyne.Do(func() { render(info) })
+		}()
+	}
+}
+
+// samePathOrInside indica si p es name o está dentro de la carpeta name
+func samePathOrInside(p, name string) bool {
+	return p == name || strings.HasPrefix(p, name+"/")
+}
+
+// mkdir pide el nombre y crea la carpeta dentro de dir ("" o "." es la raíz)
+func (m *fileMenu) mkdir(peerID int, dir string) {
+	promptName(m.win, "Nueva carpeta", "Nombre", "Nueva carpeta", func(text string) {
+		name := text
+		if dir != "" && dir != "." {
+			name = dir + "/" + text
+		}
+		m.run(peerID, fmt.Sprintf("📁 Carpeta %s creada en Maq%d", name, peerID), func() error {
+			return fs.MakeDir(m.peerSystem, peerID, name)
+		}, name)
+	})
+}
+
+// duplicate pide la ruta de la copia y la hace en el mismo peer
+func (m *fileMenu) duplicate(peerID int, name string) {
+	suggested := fs.CopyName(m.peerSystem, peerID, name)
+	promptName(m.win, "Duplicar "+path.Base(name), "Ruta de la copia", suggested, func(text string) {
+		newName := fs.RenamedPath(name, text)
+		m.run(peerID, fmt.Sprintf("📄 %s copiado a %s", name, newName), func() error {
+			return fs.CopyFile(m.peerSystem, fs.SelectedFile{FileName: name, PeerID: peerID}, newName)
+		}, newName)
+	})
+}
+
+// run ejecuta op en segundo plano, informa el resultado y muestra focus en el panel
+func (m *fileMenu) run(peerID int, done string, op func() error, focus string) {
+	go func() {
+		err := op()
+		m.refresh(peerID)
+		fyne.Do(func() {
+			if err != nil {
+				m.status("⚠️ " + err.Error())
+				return
+			}
+			m.status("✅ " + done)
+			if t, ok := m.trees[peerID]; ok {
+				t.Focus(focus)
+			}
+		})
+	}()
+}
+
+// promptName muestra un diálogo con un solo campo de texto
+func promptName(win fyne.Window, title, label, initial string, onOK func(text string)) {
+	entry := widget.NewEntry()
+	entry.SetText(initial)
+	items := []*widget.FormItem{widget.NewFormItem(label, entry)}
+	d := dialog.NewForm(title, "Aceptar", "Cancelar", items, func(ok bool) {
+		if ok && entry.Text != "" {
+			onOK(entry.Text)
+		}
+	}, win)
+	d.Resize(fyne.NewSize(420, 160))
+	d.Show()
+	win.Canvas().Focus(entry)
+}
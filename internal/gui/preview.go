@@ -0,0 +1,85 @@
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/fs"
+	"p2pfs/internal/peer"
+)
+
+// showPreview abre un diálogo con los metadatos y el comienzo de un archivo de
+// cualquier peer. El contenido llega en segundo plano y no se guarda en shared.
+func showPreview(win fyne.Window, peerSystem *peer.Peer, peerID int, name string) {
+	body := container.NewStack(widget.NewLabel("⏳ Cargando vista previa..."))
+	meta := widget.NewLabel("")
+	content := container.NewBorder(meta, nil, nil, nil, body)
+
+	d := dialog.NewCustom(fmt.Sprintf("Vista previa: %s (Maq%d)", path.Base(name), peerID), "Cerrar", content, win)
+	d.Resize(fyne.NewSize(700, 520))
+	d.Show()
+
+	go func() {
+		pv, err := fs.PreviewFile(peerSystem, peerID, name)
+		fyne.Do(func() {
+			meta.SetText(describePreview(pv))
+			if err != nil {
+				body.Objects = []fyne.CanvasObject{widget.NewLabel("❌ " + err.Error())}
+				body.Refresh()
+				return
+			}
+			body.Objects = []fyne.CanvasObject{previewBody(name, pv)}
+			body.Refresh()
+		})
+	}()
+}
+
+// previewBody muestra el texto o la miniatura; lo demás queda en los metadatos
+func previewBody(name string, pv fs.Preview) fyne.CanvasObject {
+	switch pv.Kind {
+	case "text":
+		text := string(pv.Content)
+		if pv.Truncated {
+			text += fmt.Sprintf("\n\n… (solo se muestran los primeros %s)", formatSize(int64(len(pv.Content))))
+		}
+		label := widget.NewLabelWithStyle(text, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		label.Wrapping = fyne.TextWrapWord
+		return container.NewVScroll(label)
+	case "image":
+		img := canvas.NewImageFromReader(bytes.NewReader(pv.Content), path.Base(name))
+		img.FillMode = canvas.ImageFillContain
+		img.SetMinSize(fyne.NewSize(320, 240))
+		return img
+	}
+	if pv.Info.IsDir {
+		return widget.NewLabel("📁 Carpeta")
+	}
+	if pv.Info.Size > fs.PreviewImageBytes {
+		return widget.NewLabel("Sin vista previa: el archivo es demasiado grande o no es texto.")
+	}
+	return widget.NewLabel("Sin vista previa para este tipo de archivo.")
+}
+
+// describePreview resume los metadatos conocidos del archivo
+func describePreview(pv fs.Preview) string {
+	f := pv.Info
+	text := fmt.Sprintf("Ruta: %s\nMáquina: Maq%d", f.Name, pv.PeerID)
+	if f.IsDir {
+		return text
+	}
+	text += fmt.Sprintf("\nTamaño: %s\nModificado: %s\nTipo: %s", formatSize(f.Size), f.ModTime.Format("2006-01-02 15:04:05"), f.MimeType)
+	if f.Mode != "" {
+		text += "\nPermisos: " + f.Mode
+	}
+	if f.Hash != "" {
+		text += "\nSHA-256: " + f.Hash
+	}
+	return text
+}
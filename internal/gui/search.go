@@ -0,0 +1,128 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/fs"
+	"p2pfs/internal/peer"
+)
+
+// newSearchTab construye la pestaña de búsqueda sobre los archivos de todos los
+// peers. onFetch trae un resultado a este nodo y onOpen lo abre o muestra su vista previa.
+func newSearchTab(peerSystem *peer.Peer, onFetch, onOpen func(fs.SearchResult)) fyne.CanvasObject {
+	var results []fs.SearchResult
+	localID := peerSystem.Local.ID
+
+	patternEntry := widget.NewEntry()
+	patternEntry.SetPlaceHolder("Nombre (*.pdf, informe-??.txt)")
+	regexCheck := widget.NewCheck("Expresión regular", nil)
+	extEntry := widget.NewEntry()
+	extEntry.SetPlaceHolder("Extensión")
+	minEntry := widget.NewEntry()
+	minEntry.SetPlaceHolder("Tamaño mín. (10KB)")
+	maxEntry := widget.NewEntry()
+	maxEntry.SetPlaceHolder("Tamaño máx. (2MB)")
+	sinceEntry := widget.NewEntry()
+	sinceEntry.SetPlaceHolder("Desde (2006-01-02)")
+	untilEntry := widget.NewEntry()
+	untilEntry.SetPlaceHolder("Hasta (2006-01-02)")
+	dirsCheck := widget.NewCheck("Incluir carpetas", nil)
+	resultLabel := widget.NewLabel("")
+
+	list := widget.NewList(
+		func() int { return len(results) },
+		func() fyne.CanvasObject {
+			fetch := widget.NewButtonWithIcon("Traer", theme.DownloadIcon(), nil)
+			open := widget.NewButtonWithIcon("Abrir", theme.FileIcon(), nil)
+			return container.NewBorder(nil, nil, widget.NewIcon(theme.FileIcon()), container.NewHBox(fetch, open), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			r := results[id]
+			row := obj.(*fyne.Container)
+			row.Objects[1].(*widget.Icon).SetResource(getIconForFile(r.Name, r.IsDir))
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s  —  %s  (%s)", r.Name, describeHolders(r, localID), r.Copies))
+
+			buttons := row.Objects[2].(*fyne.Container)
+			fetch := buttons.Objects[0].(*widget.Button)
+			fetch.OnTapped = func() { onFetch(r) }
+			// Traer solo tiene sentido si falta aquí o si la copia local difiere
+			if _, online := r.Best(); online && (!r.Has(localID) || r.Copies == fs.CopiesDiffer) {
+				fetch.Enable()
+			} else {
+				fetch.Disable()
+			}
+			buttons.Objects[1].(*widget.Button).OnTapped = func() { onOpen(r) }
+		},
+	)
+
+	search := func() {
+		q := fs.SearchQuery{
+			Pattern:     patternEntry.Text,
+			Regex:       regexCheck.Checked,
+			Ext:         strings.TrimSpace(extEntry.Text),
+			IncludeDirs: dirsCheck.Checked,
+		}
+		var err error
+		if q.MinSize, err = fs.ParseSize(minEntry.Text); err != nil {
+			resultLabel.SetText("❌ Tamaño mínimo inválido")
+			return
+		}
+		if q.MaxSize, err = fs.ParseSize(maxEntry.Text); err != nil {
+			resultLabel.SetText("❌ Tamaño máximo inválido")
+			return
+		}
+		if q.Since, err = parseDate(sinceEntry.Text, false); err != nil {
+			resultLabel.SetText("❌ Fecha inicial inválida")
+			return
+		}
+		if q.Until, err = parseDate(untilEntry.Text, true); err != nil {
+			resultLabel.SetText("❌ Fecha final inválida")
+			return
+		}
+
+		found, err := fs.Search(peerSystem.Peers, localID, q)
+		if err != nil {
+			resultLabel.SetText("❌ " + err.Error())
+			return
+		}
+		results = found
+		list.Refresh()
+		resultLabel.SetText(fmt.Sprintf("%d resultado(s)", len(found)))
+	}
+	patternEntry.OnSubmitted = func(string) { search() }
+
+	searchButton := widget.NewButtonWithIcon("Buscar", theme.SearchIcon(), search)
+
+	filters := container.NewVBox(
+		container.NewBorder(nil, nil, nil, regexCheck, patternEntry),
+		container.NewGridWithColumns(5, extEntry, minEntry, maxEntry, sinceEntry, untilEntry),
+		container.NewHBox(dirsCheck, searchButton, resultLabel),
+		widget.NewSeparator(),
+	)
+	return container.NewBorder(filters, nil, nil, nil, list)
+}
+
+// describeHolders lista las máquinas que tienen el archivo; las offline se marcan
+// porque su listado puede estar desactualizado
+func describeHolders(r fs.SearchResult, localID int) string {
+	var parts []string
+	for _, h := range r.Holders {
+		name := fmt.Sprintf("Maq%d", h.PeerID)
+		if h.PeerID == localID {
+			name += " (local)"
+		} else if !h.Online {
+			name += " (offline)"
+		}
+		if !r.IsDir {
+			name += " " + formatSize(h.Info.Size)
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, ", ")
+}
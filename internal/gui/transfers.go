@@ -0,0 +1,192 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"p2pfs/internal/fs"
+	"p2pfs/internal/state"
+	"p2pfs/internal/transfer"
+)
+
+// transferRow es la fila de una transferencia: descripción, barra de avance y acciones
+type transferRow struct {
+	box    *fyne.Container
+	label  *widget.Label
+	bar    *widget.ProgressBar
+	pause  *widget.Button
+	cancel *widget.Button
+	retry  *widget.Button
+	state  transfer.State
+}
+
+// newTransfersTab muestra las transferencias en curso, en cola, terminadas y
+// fallidas, y las operaciones pendientes por peers desconectados, con acciones
+// por fila. Las transferencias se actualizan con los eventos del administrador.
+func newTransfersTab() fyne.CanvasObject {
+	statusLabel := widget.NewLabel("")
+	setStatus := func(err error, ok string) {
+		if err != nil {
+			statusLabel.SetText("❌ " + err.Error())
+		} else {
+			statusLabel.SetText(ok)
+		}
+	}
+
+	list := container.NewVBox()
+	rows := make(map[int64]*transferRow)
+	emptyLabel := widget.NewLabel("No hay transferencias")
+
+	newRow := func(id int64) *transferRow {
+		row := &transferRow{label: widget.NewLabel(""), bar: widget.NewProgressBar()}
+		row.pause = widget.NewButtonWithIcon("", theme.MediaPauseIcon(), func() {
+			if row.state == transfer.Paused {
+				setStatus(transfer.Resume(id), fmt.Sprintf("▶️ Transferencia #%d reanudada", id))
+			} else {
+				setStatus(transfer.Pause(id), fmt.Sprintf("⏸️ Transferencia #%d en pausa", id))
+			}
+		})
+		row.cancel = widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+			setStatus(transfer.Cancel(id), fmt.Sprintf("🚫 Transferencia #%d cancelada", id))
+		})
+		row.retry = widget.NewButtonWithIcon("Reintentar", theme.ViewRefreshIcon(), func() {
+			setStatus(transfer.Retry(id), fmt.Sprintf("🔄 Reintentando la transferencia #%d", id))
+		})
+		actions := container.NewHBox(row.pause, row.cancel, row.retry)
+		row.box = container.NewBorder(nil, row.bar, nil, actions, row.label)
+		return row
+	}
+
+	apply := func(t transfer.Transfer) {
+		row, ok := rows[t.ID]
+		if t.Removed {
+			if ok {
+				list.Remove(row.box)
+				delete(rows, t.ID)
+			}
+		} else {
+			if !ok {
+				row = newRow(t.ID)
+				rows[t.ID] = row
+				// Las más recientes arriba
+				list.Objects = append([]fyne.CanvasObject{row.box}, list.Objects...)
+			}
+			row.state = t.State
+			row.label.SetText(t.String())
+			row.bar.SetValue(t.Progress())
+			row.pause.Hidden = t.State != transfer.Running && t.State != transfer.Paused
+			if t.State == transfer.Paused {
+				row.pause.SetIcon(theme.MediaPlayIcon())
+			} else {
+				row.pause.SetIcon(theme.MediaPauseIcon())
+			}
+			row.cancel.Hidden = t.Finished()
+			row.retry.Hidden = !t.CanRetry()
+			row.box.Refresh()
+		}
+		emptyLabel.Hidden = len(rows) > 0
+		list.Refresh()
+		emptyLabel.Refresh()
+	}
+
+	events, _ := transfer.Subscribe()
+	current := transfer.List()
+	for i := len(current) - 1; i >= 0; i-- {
+		apply(current[i])
+	}
+	go func() {
+		for t := range events {
+			t := t
+			fyne.Do(func() { apply(t) })
+		}
+	}()
+
+	// Operaciones que esperan a que un peer vuelva a conectarse
+	pendingList := container.NewVBox()
+	var refreshPending func()
+	refreshPending = func() {
+		pendingList.Objects = nil
+		all := state.GetAllPendingOps()
+		ids := make([]int, 0, len(all))
+		for id := range all {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, peerID := range ids {
+			for _, op := range all[peerID] {
+				peerID, op := peerID, op
+				name := op.FilePath
+				if op.NewPath != "" {
+					name += " → " + op.NewPath
+				}
+				label := widget.NewLabel(fmt.Sprintf("%s %s %s (Maq%d → Maq%d)", pendingIcon(op.Type), op.Type, name, op.SourceID, op.TargetID))
+				retry := widget.NewButtonWithIcon("Reintentar", theme.ViewRefreshIcon(), func() {
+					statusLabel.SetText(fmt.Sprintf("🔄 Reintentando %s...", op.FilePath))
+					go func() {
+						err := fs.RetryPendingOp(peerID, op)
+						fyne.Do(func() {
+							setStatus(err, fmt.Sprintf("✅ %s %s completado", op.Type, op.FilePath))
+							refreshPending()
+						})
+					}()
+				})
+				cancel := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+					setStatus(fs.CancelPendingOp(peerID, op), fmt.Sprintf("🚫 %s %s descartado", op.Type, op.FilePath))
+					refreshPending()
+				})
+				pendingList.Add(container.NewBorder(nil, nil, nil, container.NewHBox(retry, cancel), label))
+			}
+		}
+		if len(pendingList.Objects) == 0 {
+			pendingList.Add(widget.NewLabel("No hay operaciones pendientes"))
+		}
+		pendingList.Refresh()
+	}
+	refreshPending()
+	go func() {
+		for range time.Tick(3 * time.Second) {
+			fyne.Do(refreshPending)
+		}
+	}()
+
+	clearButton := widget.NewButtonWithIcon("Limpiar terminadas", theme.ContentClearIcon(), func() {
+		n := transfer.ClearFinished()
+		statusLabel.SetText(fmt.Sprintf("🧹 %d transferencia(s) quitada(s) de la lista", n))
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Transferencias", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		emptyLabel,
+		list,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Pendientes por peers desconectados", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		pendingList,
+	)
+	toolbar := container.NewHBox(clearButton, statusLabel, layout.NewSpacer())
+	return container.NewBorder(toolbar, nil, nil, nil, container.NewVScroll(content))
+}
+
+func pendingIcon(opType string) string {
+	switch opType {
+	case "get":
+		return "⏳"
+	case "send":
+		return "📤"
+	case "delete":
+		return "🗑️"
+	case "rename":
+		return "✏️"
+	case "mkdir":
+		return "📁"
+	case "copy":
+		return "📄"
+	}
+	return "•"
+}
@@ -0,0 +1,315 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry describe el contenido conocido de un archivo de shared
+type Entry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Inode   uint64    `json:"inode,omitempty"`
+	Hash    string    `json:"hash"`
+}
+
+// Index asocia cada ruta relativa a shared con el hash de su contenido.
+// Una entrada es válida mientras el tamaño, la fecha y el inodo no cambien,
+// así se evita volver a leer archivos que no se modificaron.
+type Index struct {
+	root   string
+	file   string
+	mutex  sync.Mutex
+	byPath map[string]Entry
+	byHash map[string]map[string]bool
+	dirty  bool
+	queue  chan string
+	once   sync.Once // carga el archivo y arranca el trabajador en el primer uso
+}
+
+// Local es el índice de la carpeta shared del nodo, guardado en data/index.json
+var Local = Open("shared", filepath.Join("data", "index.json"))
+
+// Open prepara un índice para la carpeta root guardado en file. El archivo se
+// lee recién en el primer uso, así los comandos que no tocan el índice no
+// leen data/ ni arrancan el trabajador.
+func Open(root, file string) *Index {
+	return &Index{
+		root:   root,
+		file:   file,
+		byPath: make(map[string]Entry),
+		byHash: make(map[string]map[string]bool),
+		queue:  make(chan string, 1024),
+	}
+}
+
+// load carga el índice desde disco (o lo deja vacío) y arranca el trabajador
+func (ix *Index) load() {
+	data, err := os.ReadFile(ix.file)
+	if err == nil {
+		var stored map[string]Entry
+		if json.Unmarshal(data, &stored) == nil {
+			for rel, e := range stored {
+				ix.setLocked(rel, e)
+			}
+		}
+	}
+	go ix.worker()
+}
+
+// lock toma mutex después de asegurar que el índice está cargado
+func (ix *Index) lock() {
+	ix.once.Do(ix.load)
+	ix.mutex.Lock()
+}
+
+// Lookup devuelve el hash guardado si la entrada sigue vigente para info
+func (ix *Index) Lookup(rel string, info os.FileInfo) (string, bool) {
+	ix.lock()
+	defer ix.mutex.Unlock()
+	e, ok := ix.byPath[filepath.ToSlash(rel)]
+	if !ok || !matches(e, info) {
+		return "", false
+	}
+	return e.Hash, true
+}
+
+// Update registra el hash del contenido actual de un archivo
+func (ix *Index) Update(rel string, info os.FileInfo, hash string) {
+	ix.lock()
+	defer ix.mutex.Unlock()
+	ix.setLocked(filepath.ToSlash(rel), Entry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Inode:   inodeOf(info),
+		Hash:    hash,
+	})
+	ix.dirty = true
+}
+
+// Remove olvida una ruta y todo lo que estaba debajo de ella
+func (ix *Index) Remove(rel string) {
+	rel = filepath.ToSlash(rel)
+	ix.lock()
+	defer ix.mutex.Unlock()
+	for path := range ix.byPath {
+		if path == rel || (len(path) > len(rel) && path[:len(rel)+1] == rel+"/") {
+			ix.deleteLocked(path)
+			ix.dirty = true
+		}
+	}
+}
+
+// Rename mueve las entradas de una ruta (y lo que haya debajo) a su nuevo nombre.
+// El contenido no cambia, así que los hashes siguen vigentes sin releer nada.
+func (ix *Index) Rename(oldRel, newRel string) {
+	oldRel, newRel = filepath.ToSlash(oldRel), filepath.ToSlash(newRel)
+	ix.lock()
+	defer ix.mutex.Unlock()
+	moved := make(map[string]Entry)
+	for path, e := range ix.byPath {
+		if path == oldRel || (len(path) > len(oldRel) && path[:len(oldRel)+1] == oldRel+"/") {
+			moved[newRel+path[len(oldRel):]] = e
+			ix.deleteLocked(path)
+		}
+	}
+	for path, e := range moved {
+		ix.setLocked(path, e)
+		ix.dirty = true
+	}
+}
+
+// Hash devuelve el hash de un archivo, leyéndolo solo si el índice no lo tiene vigente
+func (ix *Index) Hash(rel string) (string, error) {
+	path := filepath.Join(ix.root, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if h, ok := ix.Lookup(rel, info); ok {
+		return h, nil
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	ix.Update(rel, info, hash)
+	return hash, nil
+}
+
+// FindByHash indica si ya hay un archivo local con ese contenido y devuelve su ruta.
+// Se verifica que la entrada siga vigente antes de responder.
+func (ix *Index) FindByHash(hash string) (string, bool) {
+	ix.lock()
+	var candidates []string
+	for rel := range ix.byHash[hash] {
+		candidates = append(candidates, rel)
+	}
+	ix.mutex.Unlock()
+
+	for _, rel := range candidates {
+		info, err := os.Stat(filepath.Join(ix.root, filepath.FromSlash(rel)))
+		if err != nil {
+			ix.Remove(rel)
+			continue
+		}
+		if h, ok := ix.Lookup(rel, info); ok && h == hash {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// Enqueue pide recalcular una ruta en segundo plano (por ejemplo tras un evento del vigilante)
+func (ix *Index) Enqueue(rel string) {
+	ix.once.Do(ix.load)
+	select {
+	case ix.queue <- filepath.ToSlash(rel):
+	default:
+		// Cola llena: el próximo escaneo se encargará de esta ruta
+	}
+}
+
+// Scan recorre root, calcula el hash de los archivos nuevos o modificados y
+// descarta los que ya no existen. Devuelve cuántos archivos se leyeron.
+func (ix *Index) Scan() (int, error) {
+	seen := make(map[string]bool)
+	hashed := 0
+	err := filepath.Walk(ix.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(ix.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		if _, ok := ix.Lookup(rel, info); ok {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return nil
+		}
+		ix.Update(rel, info, hash)
+		hashed++
+		return nil
+	})
+
+	ix.lock()
+	for rel := range ix.byPath {
+		if !seen[rel] {
+			ix.deleteLocked(rel)
+			ix.dirty = true
+		}
+	}
+	ix.mutex.Unlock()
+
+	if saveErr := ix.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return hashed, err
+}
+
+// Save escribe el índice a disco si cambió desde la última vez
+func (ix *Index) Save() error {
+	ix.lock()
+	if !ix.dirty {
+		ix.mutex.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(ix.byPath, "", "  ")
+	ix.dirty = false
+	ix.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ix.file), 0755); err != nil {
+		return err
+	}
+	tmp := ix.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ix.file)
+}
+
+// StartAutosave escanea una vez al iniciar y luego guarda el índice periódicamente
+func (ix *Index) StartAutosave(interval time.Duration) {
+	go func() {
+		n, err := ix.Scan()
+		if err != nil {
+			fmt.Println("⚠️ Error al indexar shared:", err)
+		} else {
+			fmt.Println("🗂️ Índice de shared actualizado:", n, "archivo(s) leídos")
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := ix.Save(); err != nil {
+				fmt.Println("⚠️ No se pudo guardar el índice:", err)
+			}
+		}
+	}()
+}
+
+func (ix *Index) worker() {
+	for rel := range ix.queue {
+		if _, err := ix.Hash(rel); err != nil && os.IsNotExist(err) {
+			ix.Remove(rel)
+		}
+	}
+}
+
+// setLocked guarda una entrada; se asume que mutex está tomado
+func (ix *Index) setLocked(rel string, e Entry) {
+	ix.deleteLocked(rel)
+	ix.byPath[rel] = e
+	if ix.byHash[e.Hash] == nil {
+		ix.byHash[e.Hash] = make(map[string]bool)
+	}
+	ix.byHash[e.Hash][rel] = true
+}
+
+// deleteLocked elimina una entrada; se asume que mutex está tomado
+func (ix *Index) deleteLocked(rel string) {
+	old, ok := ix.byPath[rel]
+	if !ok {
+		return
+	}
+	delete(ix.byPath, rel)
+	delete(ix.byHash[old.Hash], rel)
+	if len(ix.byHash[old.Hash]) == 0 {
+		delete(ix.byHash, old.Hash)
+	}
+}
+
+func matches(e Entry, info os.FileInfo) bool {
+	if e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		return false
+	}
+	inode := inodeOf(info)
+	return e.Inode == 0 || inode == 0 || e.Inode == inode
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package index
+
+import "os"
+
+// inodeOf no tiene equivalente directo en Windows; el índice usa tamaño y fecha
+func inodeOf(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package index
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf devuelve el inodo del archivo, o 0 si no está disponible
+func inodeOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
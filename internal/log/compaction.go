@@ -0,0 +1,251 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"p2pfs/internal/peer"
+)
+
+var (
+	snapshotFile = filepath.Join("data", "log.snapshot.json")
+	ackFile      = filepath.Join("data", "log.acks.json")
+)
+
+// ackTimeout es cuánto puede pasar un peer sin confirmar el log antes de dejar
+// de frenar la compactación; al volver recibe el snapshot en lugar de las
+// entradas truncadas
+const ackTimeout = 24 * time.Hour
+
+// AckState es lo último que confirmó un peer y cuándo
+type AckState struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"` // Última confirmación, o el primer envío sin respuesta
+}
+
+// PathState es el último estado conocido de una ruta en un nodo
+type PathState struct {
+	Action   string    `json:"action"`
+	OriginID int       `json:"originID"`
+	Time     time.Time `json:"time"`
+	Present  bool      `json:"present"`
+}
+
+// Snapshot resume el espacio de nombres resultante de todas las entradas
+// hasta Seq, para poder descartar esas entradas del log
+type Snapshot struct {
+	Seq       int64                        `json:"seq"`
+	Time      time.Time                    `json:"time"`
+	Namespace map[int]map[string]PathState `json:"namespace"` // nodo destino → ruta → estado
+}
+
+// GetSnapshot devuelve el último snapshot guardado
+func GetSnapshot() Snapshot {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return loadSnapshot()
+}
+
+// GetAck devuelve la última secuencia confirmada por un peer
+func GetAck(peerID int) int64 {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return loadAcks()[peerID].Seq
+}
+
+// SetAck registra la secuencia que confirmó un peer. Puede ser menor a la
+// anterior si el peer perdió su copia: el próximo intercambio la reenvía.
+func SetAck(peerID int, seq int64) error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	acks := loadAcks()
+	acks[peerID] = AckState{Seq: seq, Time: time.Now()}
+	return saveAcks(acks)
+}
+
+// noteUnacked empieza a contar el tiempo sin confirmación de un peer que nunca confirmó
+func noteUnacked(peerID int) error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	acks := loadAcks()
+	if _, ok := acks[peerID]; ok {
+		return nil
+	}
+	acks[peerID] = AckState{Time: time.Now()}
+	return saveAcks(acks)
+}
+
+// Compact integra en el snapshot las entradas que todos los peers remotos
+// ya confirmaron y las elimina del log. Los peers que llevan más de ackTimeout
+// sin confirmar no frenan la compactación. Devuelve cuántas entradas se truncaron.
+func Compact(peers []peer.PeerInfo, localID int) (int, error) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	logs := readLogs()
+	acks := loadAcks()
+	minAck := lastSeq(logs)
+	for _, p := range peers {
+		if p.ID == localID {
+			continue
+		}
+		ack, ok := acks[p.ID]
+		if ok && time.Since(ack.Time) > ackTimeout {
+			continue
+		}
+		if ack.Seq < minAck {
+			minAck = ack.Seq
+		}
+	}
+	if minAck <= 0 {
+		return 0, nil
+	}
+
+	snap := loadSnapshot()
+	var rest []LogEntry
+	truncated := 0
+	for _, e := range logs {
+		if e.Seq > minAck {
+			rest = append(rest, e)
+			continue
+		}
+		applyToSnapshot(&snap, e)
+		truncated++
+	}
+	if truncated == 0 {
+		return 0, nil
+	}
+
+	snap.Time = time.Now()
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	// El snapshot se guarda antes de truncar para no perder entradas si falla la escritura
+	if err := os.MkdirAll(filepath.Dir(snapshotFile), 0755); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(snapshotFile, data, 0644); err != nil {
+		return 0, err
+	}
+	return truncated, writeLogs(rest)
+}
+
+// StartCompaction intercambia logs con los peers y compacta periódicamente
+func StartCompaction(peerSystem *peer.Peer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := Flush(); err != nil {
+				fmt.Println("❌ Error al guardar el log de operaciones:", err)
+			}
+			for _, p := range peerSystem.Peers {
+				if p.ID == peerSystem.Local.ID {
+					continue
+				}
+				SendLogsToPeer(p)
+			}
+			n, err := Compact(peerSystem.Peers, peerSystem.Local.ID)
+			if err != nil {
+				fmt.Println("❌ Error al compactar logs:", err)
+			} else if n > 0 {
+				fmt.Println("🗜️ Log compactado:", n, "entradas integradas al snapshot")
+			}
+		}
+	}()
+}
+
+func applyToSnapshot(snap *Snapshot, e LogEntry) {
+	if snap.Namespace == nil {
+		snap.Namespace = make(map[int]map[string]PathState)
+	}
+	if snap.Namespace[e.TargetID] == nil {
+		snap.Namespace[e.TargetID] = make(map[string]PathState)
+	}
+	snap.Namespace[e.TargetID][e.FileName] = PathState{
+		Action:   e.Action,
+		OriginID: e.OriginID,
+		Time:     e.Time,
+		Present:  e.Action != "DELETE" && e.Action != "RENAME",
+	}
+	if e.NewName != "" {
+		// En un renombre el nombre viejo deja de existir; en una copia siguen los dos
+		snap.Namespace[e.TargetID][e.NewName] = PathState{
+			Action:   e.Action,
+			OriginID: e.OriginID,
+			Time:     e.Time,
+			Present:  true,
+		}
+	}
+	if e.Seq > snap.Seq {
+		snap.Seq = e.Seq
+	}
+}
+
+func loadSnapshot() Snapshot {
+	migrateOnce.Do(migrateFromShared)
+	var snap Snapshot
+	data, err := os.ReadFile(snapshotFile)
+	if err == nil {
+		_ = json.Unmarshal(data, &snap)
+	}
+	return snap
+}
+
+func loadAcks() map[int]AckState {
+	migrateOnce.Do(migrateFromShared)
+	acks := make(map[int]AckState)
+	data, err := os.ReadFile(ackFile)
+	if err != nil {
+		return acks
+	}
+	if json.Unmarshal(data, &acks) == nil {
+		return acks
+	}
+	// Formato anterior: solo la secuencia por peer
+	acks = make(map[int]AckState)
+	var legacy map[int]int64
+	if json.Unmarshal(data, &legacy) == nil {
+		for id, seq := range legacy {
+			acks[id] = AckState{Seq: seq, Time: time.Now()}
+		}
+	}
+	return acks
+}
+
+func saveAcks(acks map[int]AckState) error {
+	data, err := json.MarshalIndent(acks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ackFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(ackFile, data, 0644)
+}
+
+// migrateOnce mueve una sola vez los archivos del log que versiones anteriores
+// guardaban en shared, donde se listaban y transferían como archivos del usuario
+var migrateOnce sync.Once
+
+func migrateFromShared() {
+	for _, path := range []string{logFile, snapshotFile, ackFile} {
+		legacy := filepath.Join("shared", filepath.Base(path))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if _, err := os.Stat(legacy); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			continue
+		}
+		if err := os.Rename(legacy, path); err == nil {
+			fmt.Println("📦 Log movido:", legacy, "→", path)
+		}
+	}
+}
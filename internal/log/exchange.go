@@ -0,0 +1,206 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"p2pfs/internal/peer"
+)
+
+// El log de operaciones se intercambia con LOG_EXCHANGE y no con SYNC_LOGS: las
+// versiones anteriores ejecutan cada entrada de SYNC_LOGS (un DELETE borra el
+// archivo), mientras que un tipo de mensaje desconocido solo cierra la conexión.
+// El receptor no aplica las entradas: las integra a su vista del nodo emisor.
+
+var remoteFile = filepath.Join("data", "log.remote.json")
+
+// legacyRetry es cuánto se espera antes de volver a ofrecer el log a un peer
+// que no entendió LOG_EXCHANGE
+const legacyRetry = time.Hour
+
+var (
+	// remoteMutex protege remoteViews
+	remoteMutex sync.Mutex
+	remoteViews map[int]Snapshot // nodo emisor → espacio de nombres según su log
+
+	// unsupportedUntil recuerda hasta cuándo no ofrecer el log a peers anteriores
+	unsupportedMutex sync.Mutex
+	unsupportedUntil = make(map[int]time.Time)
+)
+
+// Init registra el manejador del intercambio de logs
+func Init() {
+	peer.RegisterHandler("LOG_EXCHANGE", handleLogExchange)
+}
+
+// SendLogsToPeer envía al peer destino las entradas que aún no ha confirmado. Si
+// el peer quedó detrás de lo ya compactado, recibe primero el snapshot.
+func SendLogsToPeer(pinfo peer.PeerInfo) {
+	unsupportedMutex.Lock()
+	skip := time.Now().Before(unsupportedUntil[pinfo.ID])
+	unsupportedMutex.Unlock()
+	if skip {
+		return
+	}
+
+	acked := GetAck(pinfo.ID)
+	snap := GetSnapshot()
+	logs := GetLogsSince(acked)
+	behind := acked < snap.Seq
+	if len(logs) == 0 && !behind {
+		return
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(pinfo.IP, pinfo.Port), 2*time.Second)
+	if err != nil {
+		fmt.Println("❌ No se pudo conectar a", pinfo.IP, "para enviar logs.")
+		return
+	}
+	defer conn.Close()
+
+	msg := map[string]interface{}{
+		"type":  "LOG_EXCHANGE",
+		"from":  peer.Local.ID,
+		"since": acked,
+		"logs":  logs,
+	}
+	if behind {
+		msg["snapshot"] = snap
+	}
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		fmt.Println("❌ Error al enviar logs:", err)
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var resp struct {
+		Type string `json:"type"`
+		Seq  int64  `json:"seq"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil || resp.Type != "LOG_ACK" {
+		if err == io.EOF {
+			// Versión anterior: cerró sin responder al tipo desconocido
+			unsupportedMutex.Lock()
+			unsupportedUntil[pinfo.ID] = time.Now().Add(legacyRetry)
+			unsupportedMutex.Unlock()
+			fmt.Println("⚠️ Maq", pinfo.ID, "no soporta LOG_EXCHANGE; se reintenta más tarde")
+		}
+		if err := noteUnacked(pinfo.ID); err != nil {
+			fmt.Println("⚠️ No se pudo guardar el estado de confirmación:", err)
+		}
+		return
+	}
+	fmt.Println("📤 Logs enviados a Maq", pinfo.ID, "desde la secuencia", acked)
+	if err := SetAck(pinfo.ID, resp.Seq); err != nil {
+		fmt.Println("⚠️ No se pudo guardar la confirmación de logs:", err)
+	}
+}
+
+// handleLogExchange integra las entradas recibidas a la vista del emisor y
+// confirma la secuencia más alta que ya tiene
+func handleLogExchange(conn net.Conn, request map[string]interface{}) {
+	from, _ := request["from"].(float64)
+	if !knownSender(conn, int(from)) {
+		_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+			"type":  "ERROR",
+			"error": "Solo un peer conocido puede enviar su log",
+		})
+		return
+	}
+
+	// Se vuelve a codificar para decodificar con tipos; el mensaje ya se leyó como mapa
+	raw, _ := json.Marshal(request)
+	var msg struct {
+		Snapshot *Snapshot  `json:"snapshot"`
+		Logs     []LogEntry `json:"logs"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		fmt.Println("❌ Formato inválido de logs:", err)
+		return
+	}
+
+	seq, err := mergeRemote(int(from), msg.Snapshot, msg.Logs)
+	if err != nil {
+		fmt.Println("⚠️ No se pudo guardar el log de Maq", int(from), ":", err)
+	}
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type": "LOG_ACK",
+		"seq":  seq,
+	})
+}
+
+// knownSender comprueba que from sea un peer configurado con la dirección de la conexión
+func knownSender(conn net.Conn, from int) bool {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+	for _, p := range peer.Peers {
+		if p.ID == from && p.IP == host {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRemote integra a la vista de from un snapshot (si lo hay) y sus entradas
+// nuevas, y devuelve la secuencia hasta la que la vista está completa
+func mergeRemote(from int, snap *Snapshot, logs []LogEntry) (int64, error) {
+	remoteMutex.Lock()
+	defer remoteMutex.Unlock()
+
+	views := loadRemoteViews()
+	view := views[from]
+	changed := false
+	if snap != nil && snap.Seq > view.Seq {
+		view = *snap
+		changed = true
+	}
+	for _, e := range logs {
+		// Solo se avanza de forma contigua: si falta una entrada se pide de nuevo
+		if e.Seq != view.Seq+1 {
+			continue
+		}
+		applyToSnapshot(&view, e)
+		changed = true
+	}
+	if !changed {
+		return view.Seq, nil
+	}
+	view.Time = time.Now()
+	views[from] = view
+
+	data, err := json.MarshalIndent(views, "", "  ")
+	if err != nil {
+		return view.Seq, err
+	}
+	if err := os.MkdirAll(filepath.Dir(remoteFile), 0755); err != nil {
+		return view.Seq, err
+	}
+	return view.Seq, os.WriteFile(remoteFile, data, 0644)
+}
+
+// GetRemoteSnapshot devuelve el espacio de nombres que describe el log recibido de un peer
+func GetRemoteSnapshot(peerID int) Snapshot {
+	remoteMutex.Lock()
+	defer remoteMutex.Unlock()
+	return loadRemoteViews()[peerID]
+}
+
+// loadRemoteViews lee las vistas guardadas; se asume que remoteMutex está tomado
+func loadRemoteViews() map[int]Snapshot {
+	if remoteViews != nil {
+		return remoteViews
+	}
+	remoteViews = make(map[int]Snapshot)
+	if data, err := os.ReadFile(remoteFile); err == nil {
+		_ = json.Unmarshal(data, &remoteViews)
+	}
+	return remoteViews
+}
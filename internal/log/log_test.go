@@ -0,0 +1,197 @@
+package log
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"p2pfs/internal/peer"
+)
+
+// useTempLog ejecuta la prueba en una carpeta temporal con el log vacío
+func useTempLog(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	reset := func() {
+		cached, loaded, logsDirty = nil, false, false
+		remoteViews = nil
+		unsupportedUntil = make(map[int]time.Time)
+	}
+	reset()
+	oldLocal, oldPeers := peer.Local, peer.Peers
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		reset()
+		peer.Local, peer.Peers = oldLocal, oldPeers
+	})
+}
+
+func appendEntries(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := AppendLog(LogEntry{Action: "CREATE", FileName: name, OriginID: 1, TargetID: 1, Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func seqs(logs []LogEntry) []int64 {
+	out := make([]int64, len(logs))
+	for i, e := range logs {
+		out[i] = e.Seq
+	}
+	return out
+}
+
+func TestCompact(t *testing.T) {
+	peers := []peer.PeerInfo{{ID: 1}, {ID: 2}, {ID: 3}}
+	old := time.Now().Add(-2 * ackTimeout)
+
+	tests := []struct {
+		name      string
+		acks      map[int]AckState
+		truncated int
+	}{
+		{"sin confirmaciones", map[int]AckState{}, 0},
+		{"hasta el mínimo confirmado", map[int]AckState{2: {Seq: 3, Time: time.Now()}, 3: {Seq: 5, Time: time.Now()}}, 3},
+		{"un peer nunca confirmó", map[int]AckState{2: {Seq: 5, Time: time.Now()}}, 0},
+		{"el que no confirma venció", map[int]AckState{2: {Seq: 4, Time: time.Now()}, 3: {Time: old}}, 4},
+		{"todos vencidos", map[int]AckState{2: {Seq: 1, Time: old}, 3: {Time: old}}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempLog(t)
+			appendEntries(t, "a", "b", "c", "d", "e")
+			if err := saveAcks(tt.acks); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := Compact(peers, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.truncated {
+				t.Fatalf("truncadas = %d, quería %d", n, tt.truncated)
+			}
+			if got := GetSnapshot().Seq; got != int64(tt.truncated) {
+				t.Errorf("snapshot hasta %d, quería %d", got, tt.truncated)
+			}
+			if got := len(GetLogs()); got != 5-tt.truncated {
+				t.Errorf("quedaron %d entradas, quería %d", got, 5-tt.truncated)
+			}
+
+			// La numeración sigue después de lo compactado
+			appendEntries(t, "f")
+			if logs := GetLogs(); logs[len(logs)-1].Seq != 6 {
+				t.Errorf("secuencias tras compactar = %v", seqs(logs))
+			}
+		})
+	}
+}
+
+// serveOnce atiende una conexión con handle, como lo haría el servidor del peer
+func serveOnce(t *testing.T, handle func(net.Conn, map[string]interface{})) peer.PeerInfo {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var request map[string]interface{}
+		if err := json.NewDecoder(conn).Decode(&request); err != nil {
+			return
+		}
+		handle(conn, request)
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return peer.PeerInfo{ID: 2, IP: "127.0.0.1", Port: port}
+}
+
+func TestLogExchange(t *testing.T) {
+	useTempLog(t)
+	peer.Local = peer.PeerInfo{ID: 1, IP: "127.0.0.1", IsLocal: true}
+	peer.Peers = []peer.PeerInfo{peer.Local}
+	appendEntries(t, "a", "b", "c")
+
+	// Primer intercambio: el receptor guarda las entradas en su vista del emisor
+	SendLogsToPeer(serveOnce(t, handleLogExchange))
+	if got := GetAck(2); got != 3 {
+		t.Fatalf("confirmado hasta %d, quería 3", got)
+	}
+	view := GetRemoteSnapshot(1)
+	if view.Seq != 3 || !view.Namespace[1]["b"].Present {
+		t.Fatalf("vista del emisor = %+v", view)
+	}
+
+	// Se compacta lo confirmado y el receptor pierde su copia: confirma menos y
+	// en el siguiente intercambio recibe el snapshot
+	appendEntries(t, "d", "e")
+	if n, _ := Compact([]peer.PeerInfo{peer.Local, {ID: 2}}, 1); n != 3 {
+		t.Fatalf("truncadas = %d, quería 3", n)
+	}
+	remoteViews = nil
+	_ = os.Remove(remoteFile)
+
+	SendLogsToPeer(serveOnce(t, handleLogExchange))
+	if got := GetAck(2); got != 0 {
+		t.Fatalf("confirmado hasta %d tras perder la vista, quería 0", got)
+	}
+	SendLogsToPeer(serveOnce(t, handleLogExchange))
+	if got := GetAck(2); got != 5 {
+		t.Fatalf("confirmado hasta %d tras el snapshot, quería 5", got)
+	}
+	view = GetRemoteSnapshot(1)
+	if view.Seq != 5 || !view.Namespace[1]["a"].Present || !view.Namespace[1]["e"].Present {
+		t.Errorf("vista tras el snapshot = %+v", view)
+	}
+}
+
+func TestLogExchangeUnknownSender(t *testing.T) {
+	useTempLog(t)
+	peer.Local = peer.PeerInfo{ID: 1, IP: "127.0.0.1", IsLocal: true}
+	peer.Peers = nil
+	appendEntries(t, "a")
+
+	SendLogsToPeer(serveOnce(t, handleLogExchange))
+	if got := GetAck(2); got != 0 {
+		t.Errorf("un peer desconocido logró que se confirmara hasta %d", got)
+	}
+	if len(GetRemoteSnapshot(1).Namespace) != 0 {
+		t.Error("se guardó el log de un peer desconocido")
+	}
+}
+
+func TestLogExchangeOldPeer(t *testing.T) {
+	useTempLog(t)
+	peer.Local = peer.PeerInfo{ID: 1, IP: "127.0.0.1", IsLocal: true}
+	appendEntries(t, "a")
+
+	// Una versión anterior cierra la conexión ante el tipo desconocido
+	var handled atomic.Int32
+	closeUnknown := func(net.Conn, map[string]interface{}) { handled.Add(1) }
+	SendLogsToPeer(serveOnce(t, closeUnknown))
+	if _, ok := loadAcks()[2]; !ok {
+		t.Error("no se empezó a contar el tiempo sin confirmación")
+	}
+
+	// No se le vuelve a ofrecer el log en cada intervalo
+	SendLogsToPeer(serveOnce(t, closeUnknown))
+	if got := handled.Load(); got != 1 {
+		t.Errorf("el peer anterior recibió el log %d veces, quería 1", got)
+	}
+}
@@ -2,68 +2,119 @@ package log
 
 import (
 	"encoding/json"
-	"fmt"
-	"net"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
-
-	"p2pfs/internal/peer"
 )
 
 type LogEntry struct {
+	Seq      int64     `json:"seq"` // Número de secuencia local, creciente
 	Time     time.Time `json:"time"`
-	Action   string    `json:"action"`   // "CREATE", "DELETE", "TRANSFER"
+	Action   string    `json:"action"` // "CREATE", "DELETE", "TRANSFER", "RELAY", "RENAME", "MKDIR", "COPY"
 	FileName string    `json:"fileName"`
+	NewName  string    `json:"newName,omitempty"` // Para "RENAME" y "COPY": ruta de destino
 	OriginID int       `json:"originID"`
 	TargetID int       `json:"targetID"`
+	Size     int64     `json:"size,omitempty"`
+	Hash     string    `json:"hash,omitempty"`    // SHA-256 del contenido, si se conoce
+	Outcome  string    `json:"outcome,omitempty"` // "ok", "pending" o "error: ..."
 }
 
-var logFile = "shared/log.json"
+// Los archivos del log no son archivos del usuario: viven en data/ y no en shared
+var logFile = filepath.Join("data", "log.json")
+
+var (
+	// logMutex serializa las lecturas y reescrituras del archivo de logs
+	logMutex sync.Mutex
+
+	// cached guarda el log ya leído; las entradas nuevas se escriben a disco en
+	// Flush para no reescribir el archivo entero en cada operación
+	cached    []LogEntry
+	loaded    bool
+	logsDirty bool
+)
 
-// AppendLog agrega una entrada al archivo de logs
+// AppendLog agrega una entrada al log; se guarda en disco con Flush
 func AppendLog(entry LogEntry) error {
-	var logs []LogEntry
-	file, err := os.ReadFile(logFile)
-	if err == nil {
-		_ = json.Unmarshal(file, &logs)
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	logs := readLogs()
+	entry.Seq = lastSeq(logs) + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
 	}
-	logs = append(logs, entry)
-	data, err := json.MarshalIndent(logs, "", "  ")
-	if err != nil {
-		return err
+	cached = append(logs, entry)
+	logsDirty = true
+	return nil
+}
+
+// Flush escribe en disco las entradas agregadas desde la última vez
+func Flush() error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if !logsDirty {
+		return nil
 	}
-	return os.WriteFile(logFile, data, 0644)
+	return writeLogs(readLogs())
 }
 
 // GetLogs devuelve todas las entradas de log
 func GetLogs() []LogEntry {
-	var logs []LogEntry
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return append([]LogEntry(nil), readLogs()...)
+}
+
+// GetLogsSince devuelve las entradas con secuencia mayor a seq
+func GetLogsSince(seq int64) []LogEntry {
+	var result []LogEntry
+	for _, e := range GetLogs() {
+		if e.Seq > seq {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// readLogs lee el archivo de logs la primera vez; se asume que logMutex está tomado
+func readLogs() []LogEntry {
+	if loaded {
+		return cached
+	}
+	migrateOnce.Do(migrateFromShared)
 	file, err := os.ReadFile(logFile)
 	if err == nil {
-		_ = json.Unmarshal(file, &logs)
+		_ = json.Unmarshal(file, &cached)
 	}
-	return logs
+	loaded = true
+	return cached
 }
 
-// SendLogsToPeer envía los logs locales al peer destino
-func SendLogsToPeer(pinfo peer.PeerInfo) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", pinfo.IP, pinfo.Port))
+// writeLogs reescribe el archivo de logs; se asume que logMutex está tomado
+func writeLogs(logs []LogEntry) error {
+	data, err := json.MarshalIndent(logs, "", "  ")
 	if err != nil {
-		fmt.Println("❌ No se pudo conectar a", pinfo.IP, "para enviar logs.")
-		return
+		return err
 	}
-	defer conn.Close()
-
-	logs := GetLogs()
-	msg := map[string]interface{}{
-		"type": "SYNC_LOGS",
-		"logs": logs,
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(logFile, data, 0644); err != nil {
+		return err
 	}
+	cached, loaded, logsDirty = logs, true, false
+	return nil
+}
 
-	err = json.NewEncoder(conn).Encode(msg)
-	if err != nil {
-		fmt.Println("❌ Error al enviar logs:", err)
-	} else {
-		fmt.Println("📤 Logs enviados a Maq", pinfo.ID)
+// lastSeq devuelve la última secuencia usada, considerando el snapshot
+func lastSeq(logs []LogEntry) int64 {
+	seq := loadSnapshot().Seq
+	for _, e := range logs {
+		if e.Seq > seq {
+			seq = e.Seq
+		}
 	}
+	return seq
 }
@@ -0,0 +1,150 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/state"
+)
+
+// handleChunkQuery indica cuáles de los fragmentos consultados ya están disponibles localmente
+func handleChunkQuery(conn net.Conn, request map[string]interface{}) {
+	var hashes []string
+	raw, _ := json.Marshal(request["hashes"])
+	_ = json.Unmarshal(raw, &hashes)
+
+	have := make([]bool, len(hashes))
+	for i, h := range hashes {
+		have[i] = chunk.Local.Has(h)
+	}
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type": "CHUNK_HAVE",
+		"have": have,
+	})
+}
+
+// handleReceiveChunked arma un archivo con los fragmentos locales y los que
+// faltaban, enviados en el mensaje
+func handleReceiveChunked(conn net.Conn, request map[string]interface{}) {
+	var msg struct {
+		Name   string            `json:"name"`
+		Hash   string            `json:"hash"`
+		Chunks []chunk.Chunk     `json:"chunks"`
+		Data   map[string][]byte `json:"data"`
+	}
+	raw, _ := json.Marshal(request)
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		sendError(conn, "Mensaje de fragmentos inválido")
+		return
+	}
+
+	path, err := deltaTarget(msg.Name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+
+	rel, _ := filepath.Rel("shared", path)
+	defer state.BeginReceive(rel)()
+	size, err := chunk.Local.Assemble(path, msg.Chunks, msg.Data, msg.Hash)
+	if err != nil {
+		fmt.Println("❌ Error al armar", msg.Name, "desde fragmentos:", err)
+		auditServer("CREATE", msg.Name, conn, size, "error: "+err.Error())
+		sendError(conn, err.Error())
+		return
+	}
+	applyReceivedMetadata(path, request, msg.Hash)
+	chunk.Local.AddChunks(rel, msg.Chunks)
+
+	fmt.Printf("📥 Archivo armado desde fragmentos: %s (%d de %d fragmentos recibidos)\n", path, len(msg.Data), len(msg.Chunks))
+	auditServer("CREATE", msg.Name, conn, size, "ok")
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":   "CHUNKED_ACK",
+		"status": "ok",
+	})
+}
+
+// handleGetChunkList responde la lista de fragmentos de un archivo local
+func handleGetChunkList(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	path, data, info, err := readShared(name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+	rel, _ := filepath.Rel("shared", path)
+	chunks := chunk.Local.AddData(rel, data)
+	hash := state.HashBytes(data)
+	state.StoreHash(rel, info, hash)
+
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":    "CHUNK_LIST",
+		"name":    name,
+		"chunks":  chunks,
+		"hash":    hash,
+		"size":    len(data),
+		"modTime": info.ModTime(),
+		"version": state.ObserveVersion(filepath.ToSlash(rel), info.ModTime()),
+	})
+}
+
+// handleGetChunks envía los fragmentos pedidos de un archivo local
+func handleGetChunks(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	var hashes []string
+	raw, _ := json.Marshal(request["hashes"])
+	_ = json.Unmarshal(raw, &hashes)
+
+	_, data, _, err := readShared(name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+	wanted := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		wanted[h] = true
+	}
+	found := make(map[string][]byte)
+	for _, c := range chunk.Split(data) {
+		if wanted[c.Hash] {
+			found[c.Hash] = data[c.Offset : c.Offset+int64(c.Size)]
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type": "CHUNKS",
+		"data": found,
+	})
+}
+
+// statShared devuelve la ruta y los datos de un archivo (no carpeta) de shared
+func statShared(name string) (string, os.FileInfo, error) {
+	path := filepath.Join("shared", filepath.Clean(name))
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("Archivo no accesible: %v", err)
+	}
+	if info.IsDir() {
+		return "", nil, fmt.Errorf("No se puede enviar una carpeta como archivo")
+	}
+	return path, info, nil
+}
+
+// readShared lee un archivo de shared validando que la ruta no salga de la carpeta
+func readShared(name string) (string, []byte, os.FileInfo, error) {
+	if _, err := deltaTarget(name); err != nil {
+		return "", nil, nil, err
+	}
+	path, info, err := statShared(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Lectura fallida: %v", err)
+	}
+	return path, data, info, nil
+}
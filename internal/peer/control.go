@@ -0,0 +1,114 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"p2pfs/internal/compress"
+	"p2pfs/internal/throttle"
+	"p2pfs/internal/transfer"
+)
+
+// Mensajes de control del nodo local (CLI u otras herramientas en la misma
+// máquina). Se rechazan si no llegan por loopback: un peer remoto no debe poder
+// cambiar la configuración de este nodo.
+
+// IsLocalControl indica si la conexión viene de la misma máquina
+func IsLocalControl(conn net.Conn) bool {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleBandwidth responde (y opcionalmente reemplaza) los límites de ancho de banda
+func handleBandwidth(conn net.Conn, request map[string]interface{}) {
+	if !IsLocalControl(conn) {
+		sendError(conn, "Solo se acepta desde el nodo local")
+		return
+	}
+	if request["type"] == "SET_BANDWIDTH" {
+		var cfg throttle.Config
+		raw, _ := json.Marshal(request["config"])
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			sendError(conn, "Configuración inválida: "+err.Error())
+			return
+		}
+		if err := throttle.Apply(cfg); err != nil {
+			sendError(conn, err.Error())
+			return
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":        "BANDWIDTH",
+		"config":      throttle.Current(),
+		"compression": compress.CurrentStats(),
+	})
+}
+
+// handleTransfers responde la lista de transferencias, después de aplicar la
+// acción pedida si es un TRANSFER_ACTION. Con WATCH_TRANSFERS la
+// conexión queda abierta y se envía un TRANSFER_EVENT por cada cambio hasta que
+// el cliente la cierre.
+func handleTransfers(conn net.Conn, request map[string]interface{}) {
+	if !IsLocalControl(conn) {
+		sendError(conn, "Solo se acepta desde el nodo local")
+		return
+	}
+	enc := json.NewEncoder(conn)
+	if request["type"] == "TRANSFER_ACTION" {
+		id, _ := request["id"].(float64)
+		var err error
+		switch request["action"] {
+		case "pause":
+			err = transfer.Pause(int64(id))
+		case "resume":
+			err = transfer.Resume(int64(id))
+		case "cancel":
+			err = transfer.Cancel(int64(id))
+		case "retry":
+			err = transfer.Retry(int64(id))
+		case "clear":
+			transfer.ClearFinished()
+		default:
+			err = fmt.Errorf("acción desconocida: %v", request["action"])
+		}
+		if err != nil {
+			sendError(conn, err.Error())
+			return
+		}
+	}
+	if request["type"] != "WATCH_TRANSFERS" {
+		_ = enc.Encode(map[string]interface{}{
+			"type":      "TRANSFERS",
+			"transfers": transfer.List(),
+		})
+		return
+	}
+
+	events, unsubscribe := transfer.Subscribe()
+	defer unsubscribe()
+	if err := enc.Encode(map[string]interface{}{
+		"type":      "TRANSFERS",
+		"transfers": transfer.List(),
+	}); err != nil {
+		return
+	}
+	// El cliente no envía nada más: cuando cierra la conexión se deja de escuchar
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		unsubscribe()
+	}()
+	for t := range events {
+		if err := enc.Encode(map[string]interface{}{
+			"type":     "TRANSFER_EVENT",
+			"transfer": t,
+		}); err != nil {
+			return
+		}
+	}
+}
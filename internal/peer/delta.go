@@ -0,0 +1,164 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/delta"
+	"p2pfs/internal/state"
+)
+
+// handleGetSignature responde la firma por bloques de la copia local de un
+// archivo, para que el emisor envíe solo las partes que cambiaron
+func handleGetSignature(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	blockSize, _ := request["blockSize"].(float64)
+
+	path, err := deltaTarget(name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{
+		"type":   "SIGNATURE",
+		"name":   name,
+		"exists": false,
+	}
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+		info, statErr := file.Stat()
+		if statErr == nil && !info.IsDir() {
+			bs := int(blockSize)
+			if bs < delta.MinBlockSize || bs > delta.MaxBlockSize {
+				bs = delta.BlockSizeFor(info.Size())
+			}
+			sig, err := delta.ComputeSignature(file, bs)
+			if err != nil {
+				sendError(conn, fmt.Sprintf("No se pudo leer %s: %v", name, err))
+				return
+			}
+			resp["exists"] = true
+			resp["signature"] = sig
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// handleReceiveDelta reconstruye un archivo a partir de la copia local y las
+// instrucciones recibidas. El resultado se escribe en un temporal, se verifica
+// su hash y recién entonces reemplaza al original.
+func handleReceiveDelta(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	expected, _ := request["hash"].(string)
+	blockSize, _ := request["blockSize"].(float64)
+
+	path, err := deltaTarget(name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+
+	var ops []delta.Op
+	raw, _ := json.Marshal(request["ops"])
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		sendError(conn, "Instrucciones de delta inválidas")
+		return
+	}
+
+	rel, _ := filepath.Rel("shared", path)
+	defer state.BeginReceive(rel)()
+	size, err := applyDelta(path, int(blockSize), ops, expected)
+	if err != nil {
+		fmt.Println("❌ Error al aplicar delta de", name, ":", err)
+		auditServer("CREATE", name, conn, size, "error: "+err.Error())
+		sendError(conn, err.Error())
+		return
+	}
+	applyReceivedMetadata(path, request, expected)
+	_ = chunk.Local.AddFile(rel)
+
+	fmt.Printf("📥 Archivo actualizado por delta: %s (%d bytes nuevos de %d)\n", path, delta.LiteralBytes(ops), size)
+	auditServer("CREATE", name, conn, size, "ok")
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":   "DELTA_ACK",
+		"status": "ok",
+	})
+}
+
+func applyDelta(path string, blockSize int, ops []delta.Op, expected string) (int64, error) {
+	base, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("no hay copia local para aplicar el delta: %w", err)
+	}
+	defer base.Close()
+	info, err := base.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// El temporal queda en la misma carpeta para que el rename sea atómico
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo crear el temporal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, sum)}
+	if err := delta.Apply(base, info.Size(), blockSize, ops, counter); err != nil {
+		tmp.Close()
+		return counter.n, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return counter.n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return counter.n, err
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); expected != "" && got != expected {
+		return counter.n, fmt.Errorf("el archivo reconstruido no coincide con el original")
+	}
+	_ = os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return counter.n, fmt.Errorf("no se pudo reemplazar %s: %w", path, err)
+	}
+	return counter.n, nil
+}
+
+// deltaTarget valida el nombre recibido y devuelve la ruta local correspondiente
+func deltaTarget(name string) (string, error) {
+	clean := filepath.Clean(name)
+	if name == "" || strings.HasPrefix(clean, "..") || filepath.IsAbs(clean) {
+		return "", fmt.Errorf("ruta fuera de la carpeta compartida")
+	}
+	return receivedPath(name), nil
+}
+
+func sendError(conn net.Conn, msg string) {
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":  "ERROR",
+		"error": msg,
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,155 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"p2pfs/internal/index"
+)
+
+// MakeDirShared crea una carpeta (y las que falten) dentro de shared
+func MakeDirShared(name string) error {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if _, err := deltaTarget(name); err != nil || name == "." {
+		return fmt.Errorf("ruta fuera de la carpeta compartida")
+	}
+	path := filepath.Join("shared", filepath.FromSlash(name))
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return fmt.Errorf("ya existe un archivo llamado %s", name)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("error al crear la carpeta %s: %w", name, err)
+	}
+	fmt.Println("📁 Carpeta creada:", name)
+	return nil
+}
+
+// CopyShared duplica un archivo o carpeta dentro de shared sin que el contenido
+// pase por la red. Los hashes ya indexados se reutilizan para la copia.
+func CopyShared(name, newName string) error {
+	name = filepath.ToSlash(filepath.Clean(name))
+	newName = filepath.ToSlash(filepath.Clean(newName))
+	if _, err := deltaTarget(name); err != nil || name == "." {
+		return fmt.Errorf("ruta fuera de la carpeta compartida")
+	}
+	if _, err := deltaTarget(newName); err != nil || newName == "." {
+		return fmt.Errorf("ruta fuera de la carpeta compartida")
+	}
+	if newName == name || strings.HasPrefix(newName, name+"/") {
+		return fmt.Errorf("no se puede copiar %s dentro de sí misma", name)
+	}
+	src := filepath.Join("shared", filepath.FromSlash(name))
+	dst := filepath.Join("shared", filepath.FromSlash(newName))
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("no se encontró %s: %w", name, err)
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("ya existe %s", newName)
+	}
+	if err := CopyTree(src, dst); err != nil {
+		return fmt.Errorf("error al copiar %s: %w", name, err)
+	}
+
+	_ = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dst, path)
+		origRel := filepath.ToSlash(filepath.Join(name, rel))
+		if orig, err := os.Stat(filepath.Join(src, rel)); err == nil {
+			if hash, ok := index.Local.Lookup(origRel, orig); ok {
+				index.Local.Update(filepath.ToSlash(filepath.Join(newName, rel)), info, hash)
+			}
+		}
+		return nil
+	})
+	fmt.Printf("📄 Copiado: %s → %s\n", name, newName)
+	return nil
+}
+
+// CopyTree copia un archivo o una carpeta completa conservando las fechas de modificación
+func CopyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil // enlaces y archivos especiales no se comparten
+		}
+		if err := copyRegularFile(path, target); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func copyRegularFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// handleMakeDir aplica MAKE_DIR {name} y responde MKDIR_ACK
+func handleMakeDir(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	err := MakeDirShared(name)
+	replyFileOp(conn, "MKDIR_ACK", err)
+	auditServer("MKDIR", name, conn, 0, outcomeOf(err))
+}
+
+// handleCopyFile aplica COPY_FILE {name, newName} y responde COPY_ACK
+func handleCopyFile(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	newName, _ := request["newName"].(string)
+	err := CopyShared(name, newName)
+	replyFileOp(conn, "COPY_ACK", err)
+	auditServerPaths("COPY", name, newName, conn, outcomeOf(err))
+}
+
+// replyFileOp responde una operación sobre shared con status "ok" o "error"
+func replyFileOp(conn net.Conn, ackType string, err error) {
+	resp := map[string]interface{}{
+		"type":   ackType,
+		"status": "ok",
+	}
+	if err != nil {
+		fmt.Println("❌", err)
+		resp["status"] = "error"
+		resp["error"] = err.Error()
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+func outcomeOf(err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return "ok"
+}
@@ -9,12 +9,33 @@ import (
 	"path/filepath"
 	"time"
 	"strings"
+	"p2pfs/internal/chunk"
+	"p2pfs/internal/compress"
 	"p2pfs/internal/state"
+	"p2pfs/internal/throttle"
 )
 
+// Local y Peers los completa LoadPeers. Hasta que se carga la configuración
+// Local.ID es 0 y Peers está vacío: SendSyncLog no envía nada y SYNC_LOGS solo
+// aplica entradas dirigidas al nodo 0.
 var Local PeerInfo
 var Peers []PeerInfo
 
+// HandlerFunc atiende un tipo de mensaje registrado desde otro paquete
+type HandlerFunc func(conn net.Conn, request map[string]interface{})
+
+// extraHandlers guarda los tipos de mensaje que atienden paquetes que dependen de peer
+var extraHandlers = make(map[string]HandlerFunc)
+
+// AuditFunc, si está definida, recibe las operaciones aplicadas del lado servidor
+// (archivos recibidos o eliminados por pedido de otro nodo)
+var AuditFunc func(action, name string, originID int, size int64, outcome string)
+
+// RegisterHandler registra un manejador para un tipo de mensaje no conocido por peer
+func RegisterHandler(msgType string, h HandlerFunc) {
+	extraHandlers[msgType] = h
+}
+
 func StartServer(port string) {
 	listener, err := net.Listen("tcp", ":"+port)
 	if err != nil {
@@ -35,6 +56,8 @@ func StartServer(port string) {
 
 func handleConnection(conn net.Conn) {
 	defer conn.Close()
+	// Los límites de ancho de banda se aplican a todo lo que se intercambia con el peer
+	conn = throttle.Wrap(conn, PeerIDByAddr(conn.RemoteAddr()))
 
 	var request map[string]interface{}
 	if err := json.NewDecoder(conn).Decode(&request); err != nil {
@@ -44,32 +67,77 @@ func handleConnection(conn net.Conn) {
 
 	switch t := request["type"].(string); t {
 	case "GET_FILES":
-		handleGetFiles(conn)
+		handleGetFiles(conn, request)
+	case "GET_CHANGES":
+		handleGetChanges(conn, request)
+	case "LIST_DIR":
+		handleListDir(conn, request)
 	case "GET_FILE":
 		name, ok := request["name"].(string)
 		if ok {
-			handleSendFile(conn, name)
+			handleSendFile(conn, name, compress.ParseAccepted(request["acceptEncoding"]))
 		}
 	case "SEND_FILE":
-		handleReceiveFile(request)
+		handleReceiveFile(conn, request)
 	case "DELETE_FILE":
 		name, ok := request["name"].(string)
 		if ok {
 			handleDeleteFile(conn, name)
 		}
+	case "RENAME_FILE":
+		handleRenameFile(conn, request)
+	case "MAKE_DIR":
+		handleMakeDir(conn, request)
+	case "COPY_FILE":
+		handleCopyFile(conn, request)
 	case "SYNC_LOGS":
-		handleSyncLogs(request)
+		handleSyncLogs(conn, request)
+	case "GET_SIGNATURE":
+		handleGetSignature(conn, request)
+	case "SEND_DELTA":
+		handleReceiveDelta(conn, request)
+	case "CHUNK_QUERY":
+		handleChunkQuery(conn, request)
+	case "SEND_CHUNKED":
+		handleReceiveChunked(conn, request)
+	case "GET_CHUNK_LIST":
+		handleGetChunkList(conn, request)
+	case "GET_CHUNKS":
+		handleGetChunks(conn, request)
+	case "GET_RANGE":
+		handleGetRange(conn, request)
+	case "GET_CAPABILITIES":
+		_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+			"type":      "CAPABILITIES",
+			"encodings": compress.Supported,
+		})
+	case "GET_BANDWIDTH", "SET_BANDWIDTH":
+		handleBandwidth(conn, request)
+	case "GET_TRANSFERS", "WATCH_TRANSFERS", "TRANSFER_ACTION":
+		handleTransfers(conn, request)
 	default:
+		if h, ok := extraHandlers[t]; ok {
+			h(conn, request)
+			return
+		}
 		fmt.Println("⚠️ Tipo de mensaje desconocido:", request["type"])
 	}
 }
 
-func handleGetFiles(conn net.Conn) {
+func handleGetFiles(conn net.Conn, request map[string]interface{}) {
 	files, err := getLocalFiles()
 	if err != nil {
 		fmt.Println("❌ No se pudieron listar archivos:", err)
 		return
 	}
+	// Los hashes se calculan solo si el cliente los pide; luego quedan guardados
+	if withHash, _ := request["withHash"].(bool); withHash {
+		for i := range files {
+			if !files[i].IsDir && files[i].Hash == "" {
+				files[i].Hash, _ = state.EnsureHash(files[i].Name)
+			}
+		}
+	}
 	fmt.Println("📦 Enviando lista de archivos:", len(files))
 	resp := map[string]interface{}{
 		"type":  "FILES_LIST",
@@ -78,7 +146,98 @@ func handleGetFiles(conn net.Conn) {
 	_ = json.NewEncoder(conn).Encode(resp)
 }
 
-func handleSendFile(conn net.Conn, name string) {
+// handleGetChanges responde solo los cambios del listado posteriores a la
+// versión que ya tiene el cliente, o el listado completo si está desfasado
+func handleGetChanges(conn net.Conn, request map[string]interface{}) {
+	since, _ := request["since"].(float64)
+	epoch, _ := request["epoch"].(float64)
+
+	if !state.JournalLive.Load() {
+		files, err := getLocalFiles()
+		if err != nil {
+			fmt.Println("❌ No se pudieron listar archivos:", err)
+			return
+		}
+		state.ApplyListing(files)
+	}
+
+	changes, version, full := state.ChangesSince(int64(epoch), int64(since))
+	resp := map[string]interface{}{
+		"type":    "FILES_CHANGES",
+		"epoch":   state.JournalEpoch,
+		"version": version,
+		"full":    full,
+		"changes": changes,
+	}
+	if full {
+		files, _ := state.ListingSnapshot()
+		resp["files"] = files
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// handleListDir lista una carpeta concreta, por páginas, a partir del listado del diario
+func handleListDir(conn net.Conn, request map[string]interface{}) {
+	dir, _ := request["path"].(string)
+	recursive, _ := request["recursive"].(bool)
+	offset, _ := request["offset"].(float64)
+	limit, _ := request["limit"].(float64)
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	dir = filepath.Clean(dir)
+	if strings.HasPrefix(dir, "..") || filepath.IsAbs(dir) {
+		_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+			"type":  "ERROR",
+			"error": "Ruta fuera de la carpeta compartida",
+		})
+		return
+	}
+
+	// Las páginas salen del listado del diario; sin vigilante se actualiza una
+	// sola vez, al pedir la primera página
+	if !state.JournalLive.Load() && offset == 0 {
+		files, err := getLocalFiles()
+		if err != nil {
+			fmt.Println("❌ No se pudieron listar archivos:", err)
+			return
+		}
+		state.ApplyListing(files)
+	}
+	files, ok := state.DirSnapshot(dir, recursive)
+	if !ok {
+		_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+			"type":  "ERROR",
+			"error": "No existe " + filepath.ToSlash(dir),
+		})
+		return
+	}
+
+	total := len(files)
+	start := int(offset)
+	if start > total {
+		start = total
+	}
+	end := start + int(limit)
+	if end > total {
+		end = total
+	}
+	page := files[start:end]
+
+	resp := map[string]interface{}{
+		"type":  "DIR_LIST",
+		"path":  filepath.ToSlash(dir),
+		"files": page,
+		"total": total,
+		"next":  end,
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// handleSendFile responde GET_FILE; el contenido va comprimido si el cliente
+// lo acepta y el tipo de archivo lo justifica
+func handleSendFile(conn net.Conn, name string, accepted []string) {
 	path := filepath.Join("shared", filepath.Clean(name))
 	info, err := os.Stat(path)
 	if err != nil {
@@ -112,69 +271,111 @@ func handleSendFile(conn net.Conn, name string) {
 		return
 	}
 
+	state.StoreHash(filepath.Clean(name), info, state.HashBytes(data))
+	chunk.Local.AddData(filepath.Clean(name), data)
+
+	payload, encoding := compress.Encode(name, data, accepted)
 	resp := map[string]interface{}{
 		"type":    "FILE_CONTENT",
 		"name":    name,
-		"content": base64.StdEncoding.EncodeToString(data),
+		"content": base64.StdEncoding.EncodeToString(payload),
+		"modTime": info.ModTime(),
+		"version": state.ObserveVersion(filepath.ToSlash(filepath.Clean(name)), info.ModTime()),
+	}
+	if encoding != "" {
+		resp["encoding"] = encoding
 	}
 	_ = json.NewEncoder(conn).Encode(resp)
 	fmt.Println("📤 Archivo enviado correctamente:", name)
 }
 
 
-func handleReceiveFile(request map[string]interface{}) {
+func handleReceiveFile(conn net.Conn, request map[string]interface{}) {
 	name, ok1 := request["name"].(string)
 	content, ok2 := request["content"].(string)
 	isDir, _ := request["isDir"].(bool)
 
-	// ✅ Nuevo comportamiento:
-	// Si el nombre tiene separadores de ruta, se considera con estructura.
-	hasPath := strings.Contains(name, "/") || strings.Contains(name, "\\")
-
 	var path string
 	if isDir {
-		path = filepath.Join("shared", name)
-		if err := os.MkdirAll(path, 0755); err != nil {
+		if err := MakeDirShared(name); err != nil {
 			fmt.Println("❌ Error al crear carpeta recibida:", err)
-		} else {
-			fmt.Println("📁 Carpeta recibida:", name)
 		}
 		return
 	}
 
 	if !ok1 || !ok2 {
 		fmt.Println("❌ Formato inválido en archivo recibido")
+		sendError(conn, "Formato inválido en archivo recibido")
 		return
 	}
 
 	// Si tiene subruta, respeta la estructura. Si no, lo guarda directo.
-	if hasPath {
-		path = filepath.Join("shared", name)
-	} else {
-		path = filepath.Join("shared", filepath.Base(name))
-	}
+	path = receivedPath(name)
 
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		fmt.Println("❌ Error al crear carpeta destino:", err)
+		sendError(conn, err.Error())
 		return
 	}
 
 	data, err := base64.StdEncoding.DecodeString(content)
+	if err == nil {
+		encoding, _ := request["encoding"].(string)
+		data, err = compress.Decode(encoding, data)
+	}
 	if err != nil {
 		fmt.Println("❌ Error al decodificar archivo:", err)
+		sendError(conn, err.Error())
 		return
 	}
 
+	rel, _ := filepath.Rel("shared", path)
+	defer state.BeginReceive(rel)()
 	err = os.WriteFile(path, data, 0644)
 	if err != nil {
 		fmt.Println("❌ Error al guardar archivo recibido:", err)
+		auditServer("CREATE", name, conn, int64(len(data)), "error: "+err.Error())
+		sendError(conn, err.Error())
 		return
 	}
 
+	applyReceivedMetadata(path, request, state.HashBytes(data))
+	chunk.Local.AddData(rel, data)
+
 	fmt.Println("📥 Archivo recibido y guardado:", path)
+	auditServer("CREATE", name, conn, int64(len(data)), "ok")
+	// Los emisores anteriores no esperan el acuse: cierran y el error se ignora
+	_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+		"type":   "FILE_ACK",
+		"status": "ok",
+	})
 }
 
 
+// receivedPath decide dónde guardar un archivo recibido.
+// ✅ Si el nombre tiene separadores de ruta, se considera con estructura.
+func receivedPath(name string) string {
+	if strings.Contains(name, "/") || strings.Contains(name, "\\") {
+		return filepath.Join("shared", name)
+	}
+	return filepath.Join("shared", filepath.Base(name))
+}
+
+// applyReceivedMetadata conserva la fecha de modificación del origen (así la
+// sincronización no interpreta la copia recibida como una modificación nueva)
+// y registra la versión y el hash del archivo recibido
+func applyReceivedMetadata(path string, request map[string]interface{}, hash string) {
+	if modStr, ok := request["modTime"].(string); ok {
+		if modTime, err := time.Parse(time.RFC3339Nano, modStr); err == nil && !modTime.IsZero() {
+			_ = os.Chtimes(path, modTime, modTime)
+		}
+	}
+	if info, err := os.Stat(path); err == nil {
+		rel, _ := filepath.Rel("shared", path)
+		state.RecordReceivedVersion(filepath.ToSlash(rel), state.ParseVersion(request["version"]), info.ModTime())
+		state.StoreHash(rel, info, hash)
+	}
+}
 
 func handleDeleteFile(conn net.Conn, name string) {
 	path := filepath.Join("shared", name)
@@ -199,6 +400,12 @@ func handleDeleteFile(conn net.Conn, name string) {
 		}
 	}
 
+	if status == "ok" {
+		auditServer("DELETE", name, conn, 0, "ok")
+	} else {
+		auditServer("DELETE", name, conn, 0, "error")
+	}
+
 	resp := map[string]interface{}{
 		"type":   "DELETE_ACK",
 		"status": status,
@@ -206,6 +413,29 @@ func handleDeleteFile(conn net.Conn, name string) {
 	_ = json.NewEncoder(conn).Encode(resp)
 }
 
+// auditServer informa una operación aplicada por pedido de otro nodo
+func auditServer(action, name string, conn net.Conn, size int64, outcome string) {
+	if AuditFunc == nil {
+		return
+	}
+	AuditFunc(action, name, PeerIDByAddr(conn.RemoteAddr()), size, outcome)
+}
+
+// PeerIDByAddr identifica el peer configurado que corresponde a una dirección remota.
+// Devuelve 0 si la IP no pertenece a ningún peer conocido.
+func PeerIDByAddr(addr net.Addr) int {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return 0
+	}
+	for _, p := range Peers {
+		if p.IP == host {
+			return p.ID
+		}
+	}
+	return 0
+}
+
 func getLocalFiles() ([]state.FileInfo, error) {
 	var files []state.FileInfo
 	dir := "shared"
@@ -215,11 +445,7 @@ func getLocalFiles() ([]state.FileInfo, error) {
 			return nil
 		}
 		rel, _ := filepath.Rel(dir, path)
-		files = append(files, state.FileInfo{
-			Name:    rel,
-			ModTime: info.ModTime(),
-			IsDir:   info.IsDir(),
-		})
+		files = append(files, state.NewFileInfo(rel, info))
 		return nil
 	})
 	if err != nil {
@@ -228,7 +454,7 @@ func getLocalFiles() ([]state.FileInfo, error) {
 	return files, nil
 }
 
-func handleSyncLogs(request map[string]interface{}) {
+func handleSyncLogs(conn net.Conn, request map[string]interface{}) {
 	rawLogs, ok := request["logs"].([]interface{})
 	if !ok {
 		fmt.Println("❌ Formato inválido de logs.")
@@ -249,11 +475,37 @@ func handleSyncLogs(request map[string]interface{}) {
 		if targetID != Local.ID {
 			continue
 		}
+		// Las entradas del log de operaciones traen su resultado y viajan por
+		// LOG_EXCHANGE; si alguna llega por acá solo describe el historial
+		if _, ok := entry["outcome"]; ok {
+			continue
+		}
 
 		switch action {
 		case "DELETE":
 			_ = os.RemoveAll(filepath.Join("shared", fileName))
 			fmt.Println("🗑️ Eliminado por log:", fileName)
+		case "MKDIR":
+			if err := MakeDirShared(fileName); err != nil {
+				fmt.Println("⚠️ Carpeta por log no creada:", err)
+			}
+		case "COPY":
+			newName, _ := entry["newName"].(string)
+			// Si la copia ya existe la aplicó antes la petición COPY_FILE
+			if _, err := os.Lstat(filepath.Join("shared", filepath.FromSlash(newName))); err == nil {
+				break
+			}
+			if err := CopyShared(fileName, newName); err != nil {
+				fmt.Println("⚠️ Copia por log no aplicada:", err)
+			}
+		case "RENAME":
+			newName, _ := entry["newName"].(string)
+			if renameApplied(fileName, newName) {
+				break
+			}
+			if err := RenameShared(fileName, newName); err != nil {
+				fmt.Println("⚠️ Renombre por log no aplicado:", err)
+			}
 		case "TRANSFER":
 			for _, peer := range Peers {
 				if peer.ID == originID {
@@ -303,27 +555,10 @@ func requestFileFromPeer(peer PeerInfo, filename string) {
 }
 
 func SendSyncLog(action, fileName string, originID, targetID int) {
-	log := map[string]interface{}{
-		"type": "SYNC_LOGS",
-		"logs": []map[string]interface{}{
-			{
-				"action":   action,
-				"fileName": fileName,
-				"originID": originID,
-				"targetID": targetID,
-			},
-		},
-	}
-
-	for _, peer := range Peers {
-		if peer.ID == Local.ID || peer.ID == targetID {
-			continue
-		}
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%s", peer.IP, peer.Port), 2*time.Second)
-		if err != nil {
-			continue
-		}
-		_ = json.NewEncoder(conn).Encode(log)
-		conn.Close()
-	}
+	sendSyncEntry(map[string]interface{}{
+		"action":   action,
+		"fileName": fileName,
+		"originID": originID,
+		"targetID": targetID,
+	}, targetID)
 }
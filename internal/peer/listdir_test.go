@@ -0,0 +1,72 @@
+package peer
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"p2pfs/internal/state"
+)
+
+// listDir envía un LIST_DIR al manejador y devuelve la respuesta
+func listDir(t *testing.T, path string) (string, []string, string) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	go handleConnection(server)
+
+	req := map[string]interface{}{"type": "LIST_DIR", "path": path, "recursive": true}
+	if err := json.NewEncoder(client).Encode(req); err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Type  string           `json:"type"`
+		Files []state.FileInfo `json:"files"`
+		Error string           `json:"error"`
+	}
+	if err := json.NewDecoder(client).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range resp.Files {
+		names = append(names, f.Name)
+	}
+	return resp.Type, names, resp.Error
+}
+
+func TestListDir(t *testing.T) {
+	chdirTemp(t)
+	if err := os.MkdirAll(filepath.Join("shared", "docs", "vacia"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", filepath.Join("docs", "b.txt")} {
+		if err := os.WriteFile(filepath.Join("shared", name), []byte("hola"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantType string
+		want     []string
+	}{
+		{"carpeta", "docs", "DIR_LIST", []string{"docs/b.txt", "docs/vacia"}},
+		{"archivo en la raíz", "a.txt", "DIR_LIST", []string{"a.txt"}},
+		{"archivo en una carpeta", "docs/b.txt", "DIR_LIST", []string{"docs/b.txt"}},
+		{"carpeta vacía", "docs/vacia", "DIR_LIST", nil},
+		{"inexistente", "nada.txt", "ERROR", nil},
+		{"fuera de shared", "../x", "ERROR", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, names, errMsg := listDir(t, tt.path)
+			if typ != tt.wantType || !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("LIST_DIR %q = %s %v %q; quería %s %v", tt.path, typ, names, errMsg, tt.wantType, tt.want)
+			}
+		})
+	}
+}
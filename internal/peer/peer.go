@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"p2pfs/internal/state"
 )
 
 type PeerInfo struct {
@@ -67,6 +69,11 @@ func LoadPeers(configPath string) (*Peer, error) {
 		Peers: peers,
 	}
 	instanciaGlobal = peer
+	// Los globales de peer reflejan la configuración cargada: a partir de aquí
+	// los logs de sincronización salen hacia los peers y se filtran por el ID real
+	Local = local
+	Peers = peers
+	state.LocalNodeID = local.ID
 	return peer, nil
 }
 
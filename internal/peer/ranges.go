@@ -0,0 +1,63 @@
+package peer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+
+	"p2pfs/internal/compress"
+)
+
+// maxRange limita cuánto se puede pedir en un solo GET_RANGE
+const maxRange = 8 * 1024 * 1024
+
+// handleGetRange envía una porción de un archivo local. Lo usan las descargas
+// desde varios peers a la vez, que piden rangos distintos a cada uno.
+func handleGetRange(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	offset, _ := request["offset"].(float64)
+	length, _ := request["length"].(float64)
+	if offset < 0 || length <= 0 || length > maxRange {
+		sendError(conn, "Rango inválido")
+		return
+	}
+
+	if _, err := deltaTarget(name); err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+	path, info, err := statShared(name)
+	if err != nil {
+		sendError(conn, err.Error())
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		sendError(conn, "Archivo no accesible: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	buf := make([]byte, int(length))
+	n, err := file.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		sendError(conn, "Lectura fallida: "+err.Error())
+		return
+	}
+
+	payload, encoding := compress.Encode(name, buf[:n], compress.ParseAccepted(request["acceptEncoding"]))
+	resp := map[string]interface{}{
+		"type":    "RANGE",
+		"name":    name,
+		"offset":  int64(offset),
+		"content": base64.StdEncoding.EncodeToString(payload),
+		"size":    info.Size(),
+		"modTime": info.ModTime(),
+	}
+	if encoding != "" {
+		resp["encoding"] = encoding
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
@@ -0,0 +1,131 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p2pfs/internal/index"
+	"p2pfs/internal/state"
+)
+
+// RenameShared renombra o mueve una ruta dentro de shared. Lo usan tanto las
+// operaciones locales como las pedidas por otros nodos: el contenido no se
+// copia y el hash indexado y el vector de versión pasan al nombre nuevo.
+func RenameShared(oldName, newName string) error {
+	oldName = filepath.ToSlash(filepath.Clean(oldName))
+	newName = filepath.ToSlash(filepath.Clean(newName))
+	if _, err := deltaTarget(oldName); err != nil {
+		return err
+	}
+	if _, err := deltaTarget(newName); err != nil {
+		return err
+	}
+	src := filepath.Join("shared", filepath.FromSlash(oldName))
+	dst := filepath.Join("shared", filepath.FromSlash(newName))
+	if oldName == "." || newName == "." {
+		return fmt.Errorf("no se puede renombrar la carpeta compartida")
+	}
+	if oldName == newName {
+		return nil
+	}
+	if strings.HasPrefix(newName, oldName+"/") {
+		return fmt.Errorf("no se puede mover %s dentro de sí misma", oldName)
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("no se encontró %s: %w", oldName, err)
+	}
+	// Solo se permite sobrescribir si es el mismo archivo con otras mayúsculas
+	if _, err := os.Stat(dst); err == nil && !strings.EqualFold(oldName, newName) {
+		return fmt.Errorf("ya existe %s", newName)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("no se pudo crear la carpeta destino: %w", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("error al renombrar %s: %w", oldName, err)
+	}
+
+	index.Local.Rename(oldName, newName)
+	state.RenameVersion(oldName, newName)
+	fmt.Printf("✏️ Renombrado: %s → %s\n", oldName, newName)
+	return nil
+}
+
+// AuditPathsFunc, si está definida, recibe las operaciones con ruta de origen y
+// destino (renombres y copias) aplicadas por pedido de otro nodo
+var AuditPathsFunc func(action, name, newName string, originID int, outcome string)
+
+// handleRenameFile aplica RENAME_FILE {name, newName} y responde RENAME_ACK
+func handleRenameFile(conn net.Conn, request map[string]interface{}) {
+	name, _ := request["name"].(string)
+	newName, _ := request["newName"].(string)
+
+	err := RenameShared(name, newName)
+	replyFileOp(conn, "RENAME_ACK", err)
+	auditServerPaths("RENAME", name, newName, conn, outcomeOf(err))
+}
+
+// auditServerPaths informa una operación con dos rutas aplicada por pedido de otro nodo
+func auditServerPaths(action, name, newName string, conn net.Conn, outcome string) {
+	if AuditPathsFunc == nil {
+		return
+	}
+	AuditPathsFunc(action, name, newName, PeerIDByAddr(conn.RemoteAddr()), outcome)
+}
+
+// renameApplied indica si un renombre ya está hecho en shared, por ejemplo
+// porque llegó antes por RENAME_FILE
+func renameApplied(oldName, newName string) bool {
+	_, errOld := os.Lstat(filepath.Join("shared", filepath.FromSlash(oldName)))
+	_, errNew := os.Lstat(filepath.Join("shared", filepath.FromSlash(newName)))
+	return os.IsNotExist(errOld) && errNew == nil
+}
+
+// SendSyncRename entrega un renombre al nodo destino por el log de
+// sincronización; lo aplica en su copia sin volver a transferir el contenido
+func SendSyncRename(oldName, newName string, originID, targetID int) {
+	SendSyncOp("RENAME", oldName, newName, originID, targetID)
+}
+
+// SendSyncOp entrega al nodo destino una operación sobre sus rutas ("RENAME",
+// "MKDIR", "COPY") y avisa al resto de los peers. Si el destino no está
+// conectado no se reintenta aquí: la operación pendiente que registró quien la
+// originó se repite al reconectarse.
+func SendSyncOp(action, name, newName string, originID, targetID int) {
+	entry := map[string]interface{}{
+		"action":   action,
+		"fileName": name,
+		"originID": originID,
+		"targetID": targetID,
+	}
+	if newName != "" {
+		entry["newName"] = newName
+	}
+	sendSyncEntry(entry, -1)
+}
+
+// sendSyncEntry envía una entrada suelta de SYNC_LOGS a todos los peers salvo
+// este nodo y skipID
+func sendSyncEntry(entry map[string]interface{}, skipID int) {
+	msg := map[string]interface{}{
+		"type": "SYNC_LOGS",
+		"logs": []map[string]interface{}{entry},
+	}
+	for _, p := range Peers {
+		if p.ID == Local.ID || p.ID == skipID {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.IP, p.Port), 2*time.Second)
+		if err != nil {
+			continue
+		}
+		_ = json.NewEncoder(conn).Encode(msg)
+		conn.Close()
+	}
+}
@@ -0,0 +1,96 @@
+package peer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chdirTemp ejecuta la prueba dentro de una carpeta temporal con shared/ vacía
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.MkdirAll("shared", 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncRenameAppliesOnTarget(t *testing.T) {
+	chdirTemp(t)
+	if err := os.WriteFile(filepath.Join("shared", "a.txt"), []byte("hola"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	nodeA := PeerInfo{ID: 1, IP: "127.0.0.1", Port: "1", IsLocal: true}
+	nodeB := PeerInfo{ID: 2, IP: "127.0.0.1", Port: port}
+	oldLocal, oldPeers := Local, Peers
+	t.Cleanup(func() { Local, Peers = oldLocal, oldPeers })
+
+	// A envía el renombre destinado a B
+	Local, Peers = nodeA, []PeerInfo{nodeA, nodeB}
+	SendSyncRename("a.txt", "docs/b.txt", nodeA.ID, nodeB.ID)
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("el nodo destino no recibió la entrada")
+	}
+
+	// B procesa lo recibido como lo haría su servidor
+	Local = nodeB
+	handleConnection(conn)
+
+	if _, err := os.Stat(filepath.Join("shared", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("a.txt sigue existiendo: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join("shared", "docs", "b.txt"))
+	if err != nil || string(data) != "hola" {
+		t.Errorf("docs/b.txt = %q, %v", data, err)
+	}
+}
+
+func TestSyncRenameAlreadyApplied(t *testing.T) {
+	chdirTemp(t)
+	if err := os.WriteFile(filepath.Join("shared", "b.txt"), []byte("hola"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		oldName, newName string
+		want             bool
+	}{
+		{"a.txt", "b.txt", true},
+		{"b.txt", "c.txt", false},
+		{"a.txt", "c.txt", false},
+	}
+	for _, tt := range tests {
+		if got := renameApplied(tt.oldName, tt.newName); got != tt.want {
+			t.Errorf("renameApplied(%q, %q) = %v, quería %v", tt.oldName, tt.newName, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -11,27 +12,126 @@ import (
 
 // FileInfo es una versión reducida para evitar importar fs
 type FileInfo struct {
-	Name    string
-	ModTime time.Time
-	IsDir   bool // ← nuevo campo para indicar si es carpeta
+	Name     string        `json:"name"`
+	ModTime  time.Time     `json:"modTime"`
+	IsDir    bool          `json:"isDir"` // ← nuevo campo para indicar si es carpeta
+	Size     int64         `json:"size"`
+	Mode     string        `json:"mode,omitempty"`     // Permisos, ej. "-rw-r--r--"
+	Hash     string        `json:"hash,omitempty"`     // SHA-256 del contenido, solo si ya se calculó
+	MimeType string        `json:"mimeType,omitempty"` // Según la extensión
+	Version  VersionVector `json:"version,omitempty"`
 }
 
-// FileCache guarda la lista de archivos por IP de nodo
-var FileCache = make(map[string][]FileInfo)
+// fileCache guarda la lista de archivos por IP de nodo. Se accede solo con
+// las funciones de abajo, porque la actualizan la sincronización, la GUI y las
+// transferencias en paralelo.
+var fileCache = make(map[string][]FileInfo)
 
-// OnlineStatus indica si un nodo está en línea por su IP
-var OnlineStatus = make(map[string]bool)
+// fileCacheMutex protege fileCache
+var fileCacheMutex sync.Mutex
+
+// CachedFiles devuelve una copia del último listado conocido de un nodo
+func CachedFiles(ip string) []FileInfo {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	return append([]FileInfo(nil), fileCache[ip]...)
+}
+
+// SetFileCache reemplaza el listado conocido de un nodo
+func SetFileCache(ip string, files []FileInfo) {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	fileCache[ip] = files
+}
+
+// AddToFileCache agrega una entrada al listado conocido de un nodo
+func AddToFileCache(ip string, f FileInfo) {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	fileCache[ip] = append(fileCache[ip], f)
+}
+
+// onlineStatus indica si un nodo está en línea por su IP. Lo escribe la
+// sincronización periódica y lo leen las transferencias, así que se accede
+// solo con IsOnline y SetOnline.
+var onlineStatus = make(map[string]bool)
+
+// onlineMutex protege onlineStatus
+var onlineMutex sync.Mutex
+
+// IsOnline indica si el nodo con esa IP respondió en la última revisión
+func IsOnline(ip string) bool {
+	onlineMutex.Lock()
+	defer onlineMutex.Unlock()
+	return onlineStatus[ip]
+}
+
+// SetOnline registra el estado de un nodo y devuelve el que tenía antes
+func SetOnline(ip string, online bool) bool {
+	onlineMutex.Lock()
+	defer onlineMutex.Unlock()
+	was := onlineStatus[ip]
+	onlineStatus[ip] = online
+	return was
+}
 
 // RemoveFileFromCache elimina un archivo del cache por IP y nombre de archivo
 func RemoveFileFromCache(ip, filename string) {
-	list := FileCache[ip]
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	list := fileCache[ip]
 	newList := []FileInfo{}
 	for _, f := range list {
 		if f.Name != filename {
 			newList = append(newList, f)
 		}
 	}
-	FileCache[ip] = newList
+	fileCache[ip] = newList
+}
+
+// RemoveTreeFromCache quita del listado conocido de un nodo lo que está dentro
+// de la carpeta dir (la carpeta queda) y devuelve las entradas quitadas
+func RemoveTreeFromCache(ip, dir string) []FileInfo {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	var kept, removed []FileInfo
+	for _, f := range fileCache[ip] {
+		if strings.HasPrefix(f.Name, dir+"/") {
+			removed = append(removed, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	fileCache[ip] = kept
+	return removed
+}
+
+// CopyInCache agrega al listado conocido de un nodo las entradas de una copia
+// de name (y de lo que haya debajo) con el nombre newName
+func CopyInCache(ip, name, newName string) {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	for _, f := range fileCache[ip] {
+		if f.Name == name || strings.HasPrefix(f.Name, name+"/") {
+			f.Name = newName + f.Name[len(name):]
+			fileCache[ip] = append(fileCache[ip], f)
+		}
+	}
+}
+
+// RenameInCache aplica un renombre al listado conocido de un nodo, incluidas las
+// entradas que estaban dentro de una carpeta renombrada
+func RenameInCache(ip, oldName, newName string) {
+	fileCacheMutex.Lock()
+	defer fileCacheMutex.Unlock()
+	list := make([]FileInfo, 0, len(fileCache[ip]))
+	for _, f := range fileCache[ip] {
+		if f.Name == oldName || strings.HasPrefix(f.Name, oldName+"/") {
+			f.Name = newName + f.Name[len(oldName):]
+		}
+		list = append(list, f)
+	}
+	fileCache[ip] = list
 }
 
 // ===============================
@@ -40,8 +140,9 @@ func RemoveFileFromCache(ip, filename string) {
 
 // PendingOperation representa una operación diferida hacia un nodo
 type PendingOperation struct {
-	Type     string // "send", "get", "delete"
+	Type     string // "send", "get", "delete", "rename", "mkdir", "copy"
 	FilePath string
+	NewPath  string // Para "rename" y "copy": ruta de destino
 	TargetID int // Nodo destinatario
 	SourceID int // Nodo origen (quien inicia la operación)
 	Flatten  bool // ✅ Nuevo campo: indica si se debe guardar sin estructura
@@ -76,6 +177,23 @@ func PeekPendingOps(peerID int) []PendingOperation {
 	return pendingOps[peerID]
 }
 
+// RemovePendingOp elimina la primera operación pendiente igual a op; devuelve false si no estaba
+func RemovePendingOp(peerID int, op PendingOperation) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	ops := pendingOps[peerID]
+	for i, o := range ops {
+		if o == op {
+			pendingOps[peerID] = append(ops[:i:i], ops[i+1:]...)
+			if len(pendingOps[peerID]) == 0 {
+				delete(pendingOps, peerID)
+			}
+			return true
+		}
+	}
+	return false
+}
+
 // GetAllPendingOps devuelve una copia de todas las operaciones pendientes
 func GetAllPendingOps() map[int][]PendingOperation {
 	mutex.Lock()
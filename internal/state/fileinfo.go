@@ -0,0 +1,97 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"p2pfs/internal/index"
+)
+
+// NewFileInfo arma la entrada de listado de una ruta relativa a shared.
+// El hash solo se incluye si ya estaba calculado: ver EnsureHash.
+func NewFileInfo(rel string, info os.FileInfo) FileInfo {
+	rel = filepath.ToSlash(rel)
+	f := FileInfo{
+		Name:    rel,
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+		Mode:    info.Mode().String(),
+	}
+	if f.IsDir {
+		return f
+	}
+	f.Size = info.Size()
+	f.MimeType = MimeTypeOf(rel)
+	f.Hash = CachedHash(rel, info)
+	f.Version = ObserveVersion(rel, f.ModTime)
+	return f
+}
+
+// MimeTypeOf deduce el tipo MIME a partir de la extensión
+func MimeTypeOf(name string) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Tipos de archivo según la extensión. La GUI elige el ícono con FileKind y las
+// transferencias no comprimen los formatos que ya vienen comprimidos.
+var fileKinds = map[string]string{
+	".txt": "text", ".md": "text", ".csv": "text", ".log": "text", ".json": "text",
+	".mp3": "audio", ".ogg": "audio", ".flac": "audio", ".m4a": "audio", ".opus": "audio",
+	".jpg": "image", ".jpeg": "image", ".png": "image", ".gif": "image", ".webp": "image",
+	".mp4": "video", ".avi": "video", ".mkv": "video", ".mov": "video", ".webm": "video",
+	".zip": "archive", ".gz": "archive", ".tgz": "archive", ".bz2": "archive", ".xz": "archive",
+	".7z": "archive", ".rar": "archive", ".zst": "archive",
+}
+
+// FileKind devuelve "text", "audio", "image", "video", "archive" o "" si no se reconoce
+func FileKind(name string) string {
+	return fileKinds[strings.ToLower(filepath.Ext(name))]
+}
+
+// IsCompressedFormat indica si el contenido ya está comprimido y no vale la pena volver a comprimirlo
+func IsCompressedFormat(name string) bool {
+	switch FileKind(name) {
+	case "audio", "image", "video", "archive":
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf", ".docx", ".xlsx", ".pptx", ".odt", ".jar", ".apk":
+		return true
+	}
+	return false
+}
+
+// ===============================
+// Hashes de contenido
+// ===============================
+
+// Los hashes viven en el índice persistente de shared (data/index.json)
+
+// CachedHash devuelve el hash indexado si el archivo no cambió desde que se calculó
+func CachedHash(rel string, info os.FileInfo) string {
+	h, _ := index.Local.Lookup(rel, info)
+	return h
+}
+
+// StoreHash guarda el hash del contenido actual de un archivo
+func StoreHash(rel string, info os.FileInfo, hash string) {
+	index.Local.Update(rel, info, hash)
+}
+
+// HashBytes calcula el hash SHA-256 en hexadecimal usado en listados e historial
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EnsureHash devuelve el hash de un archivo de shared, leyéndolo solo si el índice no lo tiene
+func EnsureHash(rel string) (string, error) {
+	return index.Local.Hash(filepath.ToSlash(rel))
+}
@@ -0,0 +1,163 @@
+package state
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ===============================
+// Diario de cambios del listado local
+// ===============================
+
+// ListingChange es un cambio del listado local con su número de versión
+type ListingChange struct {
+	Seq     int64     `json:"seq"`
+	Name    string    `json:"name"`
+	Deleted bool      `json:"deleted"`
+	Info    *FileInfo `json:"info,omitempty"`
+}
+
+// maxJournal limita cuántos cambios se recuerdan; un cliente más atrasado recibe el listado completo
+const maxJournal = 5000
+
+var (
+	journalMutex sync.Mutex
+	// JournalEpoch identifica esta ejecución del nodo: si cambia, el contador volvió a empezar
+	JournalEpoch = time.Now().UnixNano()
+	journalSeq   int64
+	journal      []ListingChange
+	listing      = make(map[string]FileInfo)
+	// JournalLive indica que el vigilante de archivos mantiene el diario al día,
+	// por lo que no hace falta volver a escanear shared para responder. Lo
+	// activa el vigilante con el servidor ya atendiendo pedidos.
+	JournalLive atomic.Bool
+)
+
+// RecordListingChange registra la creación o modificación (info != nil) o la eliminación de una ruta
+func RecordListingChange(name string, info *FileInfo) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	recordLocked(name, info)
+}
+
+// ApplyListing compara un escaneo completo con el último listado conocido y
+// registra las diferencias como cambios
+func ApplyListing(files []FileInfo) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.Name] = true
+		old, ok := listing[f.Name]
+		if !ok || !old.ModTime.Equal(f.ModTime) || old.IsDir != f.IsDir {
+			f := f
+			recordLocked(f.Name, &f)
+		}
+	}
+	for name := range listing {
+		if !seen[name] {
+			recordLocked(name, nil)
+		}
+	}
+}
+
+// ChangesSince devuelve los cambios posteriores a seq (uno por ruta, el último).
+// full es true cuando el cliente está demasiado atrasado o pertenece a otra
+// ejecución del nodo y debe reemplazar su listado por ListingSnapshot.
+func ChangesSince(epoch, seq int64) (changes []ListingChange, current int64, full bool) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	if epoch != JournalEpoch || seq > journalSeq {
+		return nil, journalSeq, true
+	}
+	if len(journal) > 0 && journal[0].Seq > seq+1 {
+		return nil, journalSeq, true
+	}
+
+	latest := make(map[string]ListingChange)
+	for _, c := range journal {
+		if c.Seq > seq {
+			latest[c.Name] = c
+		}
+	}
+	for _, c := range latest {
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+	return changes, journalSeq, false
+}
+
+// ListingSnapshot devuelve el listado completo conocido por el diario y su versión
+func ListingSnapshot() ([]FileInfo, int64) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	files := make([]FileInfo, 0, len(listing))
+	for _, f := range listing {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, journalSeq
+}
+
+// DirSnapshot devuelve del listado conocido por el diario el contenido de dir
+// ("." es la raíz de shared), ordenado por ruta. Sin recursive solo incluye los
+// hijos directos. Si dir es un archivo se devuelve solo su entrada, como hacía
+// el filtro de GET_FILES. ok es false si la ruta no está en el listado.
+func DirSnapshot(dir string, recursive bool) (files []FileInfo, ok bool) {
+	dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+	prefix := dir + "/"
+	if dir == "." || dir == "" {
+		prefix = ""
+	}
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	if prefix != "" {
+		d, found := listing[dir]
+		if !found {
+			return nil, false
+		}
+		if !d.IsDir {
+			return []FileInfo{d}, true
+		}
+	}
+	for name, f := range listing {
+		if !strings.HasPrefix(name, prefix) || name == dir {
+			continue
+		}
+		if !recursive && strings.Contains(name[len(prefix):], "/") {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, true
+}
+
+// recordLocked agrega un cambio; se asume que journalMutex está tomado
+func recordLocked(name string, info *FileInfo) {
+	if info == nil {
+		if _, ok := listing[name]; !ok {
+			return
+		}
+		delete(listing, name)
+	} else {
+		listing[name] = *info
+	}
+	journalSeq++
+	journal = append(journal, ListingChange{
+		Seq:     journalSeq,
+		Name:    name,
+		Deleted: info == nil,
+		Info:    info,
+	})
+	if len(journal) > maxJournal {
+		journal = append([]ListingChange(nil), journal[len(journal)-maxJournal:]...)
+	}
+}
@@ -0,0 +1,125 @@
+package state
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// resetJournal deja el diario vacío para la prueba y lo restaura al terminar
+func resetJournal(t *testing.T) {
+	t.Helper()
+	journalMutex.Lock()
+	oldSeq, oldJournal, oldListing := journalSeq, journal, listing
+	journalSeq, journal, listing = 0, nil, make(map[string]FileInfo)
+	journalMutex.Unlock()
+	t.Cleanup(func() {
+		journalMutex.Lock()
+		journalSeq, journal, listing = oldSeq, oldJournal, oldListing
+		journalMutex.Unlock()
+	})
+}
+
+func file(name string) *FileInfo {
+	return &FileInfo{Name: name, ModTime: time.Unix(1, 0)}
+}
+
+func changeNames(changes []ListingChange) []string {
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Name
+		if c.Deleted {
+			names[i] = "-" + c.Name
+		}
+	}
+	return names
+}
+
+func TestChangesSince(t *testing.T) {
+	resetJournal(t)
+	RecordListingChange("a.txt", file("a.txt"))     // 1
+	RecordListingChange("b.txt", file("b.txt"))     // 2
+	RecordListingChange("a.txt", file("a.txt"))     // 3
+	RecordListingChange("b.txt", nil)               // 4
+	RecordListingChange("c/d.txt", file("c/d.txt")) // 5
+	RecordListingChange("zzz.txt", nil)             // no existía: no cuenta
+
+	tests := []struct {
+		name     string
+		epoch    int64
+		since    int64
+		want     []string
+		wantFull bool
+	}{
+		{"desde cero", JournalEpoch, 0, []string{"a.txt", "-b.txt", "c/d.txt"}, false},
+		{"un cambio por ruta, el último", JournalEpoch, 1, []string{"a.txt", "-b.txt", "c/d.txt"}, false},
+		{"solo lo nuevo", JournalEpoch, 3, []string{"-b.txt", "c/d.txt"}, false},
+		{"al día", JournalEpoch, 5, []string{}, false},
+		{"otra ejecución", JournalEpoch + 1, 2, nil, true},
+		{"versión del futuro", JournalEpoch, 9, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, current, full := ChangesSince(tt.epoch, tt.since)
+			if current != 5 {
+				t.Errorf("versión actual = %d, quería 5", current)
+			}
+			if full != tt.wantFull {
+				t.Fatalf("full = %v, quería %v", full, tt.wantFull)
+			}
+			if !full && !reflect.DeepEqual(changeNames(changes), tt.want) {
+				t.Errorf("cambios = %v, quería %v", changeNames(changes), tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesSinceTruncatedJournal(t *testing.T) {
+	resetJournal(t)
+	for i := 0; i < maxJournal+10; i++ {
+		RecordListingChange(fmt.Sprintf("f%d.txt", i), file(fmt.Sprintf("f%d.txt", i)))
+	}
+	// Un cliente que quedó antes del primer cambio recordado recibe el listado completo
+	if _, _, full := ChangesSince(JournalEpoch, 1); !full {
+		t.Error("un cliente muy atrasado no recibió el listado completo")
+	}
+	if changes, _, full := ChangesSince(JournalEpoch, int64(maxJournal+5)); full || len(changes) != 5 {
+		t.Errorf("cambios recientes = %d (full %v), quería 5", len(changes), full)
+	}
+}
+
+func TestDirSnapshot(t *testing.T) {
+	resetJournal(t)
+	dir := func(name string) *FileInfo { return &FileInfo{Name: name, IsDir: true} }
+	RecordListingChange("a.txt", file("a.txt"))
+	RecordListingChange("docs", dir("docs"))
+	RecordListingChange("docs/b.txt", file("docs/b.txt"))
+	RecordListingChange("docs/sub", dir("docs/sub"))
+	RecordListingChange("docs/sub/c.txt", file("docs/sub/c.txt"))
+	RecordListingChange("docsx.txt", file("docsx.txt"))
+
+	tests := []struct {
+		dir       string
+		recursive bool
+		want      []string
+		ok        bool
+	}{
+		{".", false, []string{"a.txt", "docs", "docsx.txt"}, true},
+		{"docs", false, []string{"docs/b.txt", "docs/sub"}, true},
+		{"docs/", true, []string{"docs/b.txt", "docs/sub", "docs/sub/c.txt"}, true},
+		{"a.txt", false, []string{"a.txt"}, true},
+		{"docs/sub/c.txt", true, []string{"docs/sub/c.txt"}, true},
+		{"nada", false, nil, false},
+	}
+	for _, tt := range tests {
+		files, ok := DirSnapshot(tt.dir, tt.recursive)
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		if ok != tt.ok || !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("DirSnapshot(%q, %v) = %v, %v; quería %v, %v", tt.dir, tt.recursive, names, ok, tt.want, tt.ok)
+		}
+	}
+}
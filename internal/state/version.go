@@ -0,0 +1,345 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===============================
+// Vectores de versión por archivo
+// ===============================
+
+// VersionVector cuenta cuántas modificaciones hizo cada nodo (por ID) sobre un archivo
+type VersionVector map[int]int
+
+// Ordering es el resultado de comparar dos vectores de versión
+type Ordering int
+
+const (
+	Equal      Ordering = iota
+	Before              // el primero es un ancestro del segundo
+	After               // el primero desciende del segundo
+	Concurrent          // modificaciones independientes: conflicto
+)
+
+// Compare compara v contra o
+func (v VersionVector) Compare(o VersionVector) Ordering {
+	less, greater := false, false
+	for id, n := range v {
+		if n > o[id] {
+			greater = true
+		} else if n < o[id] {
+			less = true
+		}
+	}
+	for id, n := range o {
+		if _, ok := v[id]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// String muestra el vector como "Maq1:3 Maq2:1", ordenado por nodo
+func (v VersionVector) String() string {
+	ids := make([]int, 0, len(v))
+	for id := range v {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("Maq%d:%d", id, v[id])
+	}
+	return strings.Join(parts, " ")
+}
+
+// Merge devuelve el máximo por componente de ambos vectores
+func (v VersionVector) Merge(o VersionVector) VersionVector {
+	result := v.Copy()
+	for id, n := range o {
+		if n > result[id] {
+			result[id] = n
+		}
+	}
+	return result
+}
+
+// Copy devuelve una copia independiente del vector
+func (v VersionVector) Copy() VersionVector {
+	result := make(VersionVector, len(v))
+	for id, n := range v {
+		result[id] = n
+	}
+	return result
+}
+
+// ParseVersion convierte el vector recibido en un mensaje JSON genérico
+func ParseVersion(raw interface{}) VersionVector {
+	if raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var v VersionVector
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// LocalNodeID es el ID del nodo local, usado para incrementar los vectores
+var LocalNodeID int
+
+// versionEntry guarda el vector de un archivo local y la fecha con la que se observó
+type versionEntry struct {
+	Vector  VersionVector `json:"vector"`
+	ModTime time.Time     `json:"modTime"`
+}
+
+// Conflict es una modificación concurrente detectada durante la sincronización
+type Conflict struct {
+	Path          string        `json:"path"`
+	PeerID        int           `json:"peerID"`
+	ConflictCopy  string        `json:"conflictCopy"` // Copia local de la versión remota
+	Detected      time.Time     `json:"detected"`
+	LocalVersion  VersionVector `json:"localVersion"`
+	RemoteVersion VersionVector `json:"remoteVersion"`
+}
+
+var (
+	versionFile   = filepath.Join("data", "versions.json")
+	versionMutex  sync.Mutex
+	versions      map[string]versionEntry
+	conflicts     []Conflict
+	versionsDirty bool
+
+	// receiving cuenta las recepciones en curso por ruta: mientras un archivo
+	// recibido se escribe, observarlo no cuenta como modificación local
+	receiving = make(map[string]int)
+)
+
+type versionStore struct {
+	Versions  map[string]versionEntry `json:"versions"`
+	Conflicts []Conflict              `json:"conflicts"`
+}
+
+// ObserveVersion devuelve el vector del archivo local y lo incrementa
+// si la fecha de modificación cambió desde la última observación
+func ObserveVersion(name string, modTime time.Time) VersionVector {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+
+	entry, ok := versions[name]
+	if (ok && entry.ModTime.Equal(modTime)) || receiving[name] > 0 {
+		return entry.Vector.Copy()
+	}
+	if entry.Vector == nil {
+		entry.Vector = make(VersionVector)
+	}
+	entry.Vector[LocalNodeID]++
+	entry.ModTime = modTime
+	versions[name] = entry
+	versionsDirty = true
+	return entry.Vector.Copy()
+}
+
+// RecordReceivedVersion registra el vector de un archivo recibido de otro nodo,
+// para que la próxima observación no lo cuente como una modificación local
+func RecordReceivedVersion(name string, v VersionVector, modTime time.Time) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+
+	entry := versions[name]
+	entry.Vector = entry.Vector.Merge(v)
+	entry.ModTime = modTime
+	versions[name] = entry
+	versionsDirty = true
+}
+
+// BeginReceive marca name como en recepción hasta que se llame a la función
+// devuelta, que debe ejecutarse después de RecordReceivedVersion. Así ni el
+// watcher ni un listado cuentan la escritura como una modificación local.
+func BeginReceive(name string) func() {
+	name = filepath.ToSlash(filepath.Clean(name))
+	versionMutex.Lock()
+	receiving[name]++
+	versionMutex.Unlock()
+	return func() {
+		versionMutex.Lock()
+		if receiving[name]--; receiving[name] <= 0 {
+			delete(receiving, name)
+		}
+		versionMutex.Unlock()
+	}
+}
+
+// BumpVersion integra un vector ajeno y agrega una modificación local, de modo
+// que la versión resultante domine a ambas (usado al resolver conflictos)
+func BumpVersion(name string, other VersionVector) VersionVector {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+
+	entry := versions[name]
+	entry.Vector = entry.Vector.Merge(other)
+	entry.Vector[LocalNodeID]++
+	versions[name] = entry
+	versionsDirty = true
+	return entry.Vector.Copy()
+}
+
+// ForgetVersion descarta el vector de un archivo eliminado
+func ForgetVersion(name string) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	if _, ok := versions[name]; ok {
+		delete(versions, name)
+		versionsDirty = true
+	}
+}
+
+// RenameVersion traslada los vectores de una ruta renombrada (y de lo que haya
+// debajo), así el archivo conserva su historia de versiones con el nombre nuevo
+func RenameVersion(oldName, newName string) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	moved := make(map[string]versionEntry)
+	for name, entry := range versions {
+		if name == oldName || strings.HasPrefix(name, oldName+"/") {
+			moved[newName+name[len(oldName):]] = entry
+			delete(versions, name)
+		}
+	}
+	for name, entry := range moved {
+		versions[name] = entry
+	}
+	if len(moved) > 0 {
+		versionsDirty = true
+	}
+}
+
+// AddConflict registra un conflicto sin resolver
+func AddConflict(c Conflict) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	conflicts = append(conflicts, c)
+	_ = saveVersions()
+}
+
+// GetConflicts devuelve una copia de los conflictos sin resolver
+func GetConflicts() []Conflict {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	result := make([]Conflict, len(conflicts))
+	copy(result, conflicts)
+	return result
+}
+
+// HasConflict indica si una ruta tiene un conflicto sin resolver
+func HasConflict(path string) bool {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	for _, c := range conflicts {
+		if c.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveConflict elimina el conflicto de una ruta
+func RemoveConflict(path string) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	loadVersions()
+	var rest []Conflict
+	for _, c := range conflicts {
+		if c.Path != path {
+			rest = append(rest, c)
+		}
+	}
+	conflicts = rest
+	_ = saveVersions()
+}
+
+// loadVersions carga el almacén la primera vez; se asume que versionMutex está tomado
+func loadVersions() {
+	if versions != nil {
+		return
+	}
+	var store versionStore
+	data, err := os.ReadFile(versionFile)
+	if err == nil {
+		_ = json.Unmarshal(data, &store)
+	}
+	versions = store.Versions
+	if versions == nil {
+		versions = make(map[string]versionEntry)
+	}
+	conflicts = store.Conflicts
+}
+
+// SaveVersions escribe el almacén a disco si cambió desde la última vez
+func SaveVersions() error {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	if !versionsDirty {
+		return nil
+	}
+	return saveVersions()
+}
+
+// StartVersionAutosave guarda los vectores de versión periódicamente; los
+// conflictos se guardan en el momento porque son pocos
+func StartVersionAutosave(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SaveVersions(); err != nil {
+				fmt.Println("⚠️ No se pudieron guardar los vectores de versión:", err)
+			}
+		}
+	}()
+}
+
+// saveVersions persiste el almacén; se asume que versionMutex está tomado
+func saveVersions() error {
+	data, err := json.MarshalIndent(versionStore{Versions: versions, Conflicts: conflicts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(versionFile), 0755); err != nil {
+		return err
+	}
+	tmp := versionFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	versionsDirty = false
+	return os.Rename(tmp, versionFile)
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVersionVectorCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b VersionVector
+		want Ordering
+	}{
+		{"ambos vacíos", nil, VersionVector{}, Equal},
+		{"iguales", VersionVector{1: 2, 2: 1}, VersionVector{1: 2, 2: 1}, Equal},
+		{"ceros ausentes", VersionVector{1: 1, 2: 0}, VersionVector{1: 1}, Equal},
+		{"ancestro", VersionVector{1: 1}, VersionVector{1: 2}, Before},
+		{"nodo nuevo en el otro", VersionVector{1: 1}, VersionVector{1: 1, 2: 1}, Before},
+		{"descendiente", VersionVector{1: 3, 2: 1}, VersionVector{1: 2, 2: 1}, After},
+		{"contra vacío", VersionVector{1: 1}, nil, After},
+		{"concurrentes", VersionVector{1: 2, 2: 1}, VersionVector{1: 1, 2: 2}, Concurrent},
+		{"nodos distintos", VersionVector{1: 1}, VersionVector{2: 1}, Concurrent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Compare(tt.b); got != tt.want {
+				t.Errorf("Compare = %v, quería %v", got, tt.want)
+			}
+			// La comparación inversa tiene que ser simétrica
+			inverse := map[Ordering]Ordering{Equal: Equal, Before: After, After: Before, Concurrent: Concurrent}
+			if got := tt.b.Compare(tt.a); got != inverse[tt.want] {
+				t.Errorf("Compare inverso = %v, quería %v", got, inverse[tt.want])
+			}
+		})
+	}
+}
+
+func TestVersionVectorMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b VersionVector
+		want VersionVector
+	}{
+		{"vacíos", nil, nil, VersionVector{}},
+		{"con vacío", VersionVector{1: 2}, nil, VersionVector{1: 2}},
+		{"máximo por nodo", VersionVector{1: 3, 2: 1}, VersionVector{1: 1, 2: 4}, VersionVector{1: 3, 2: 4}},
+		{"nodos disjuntos", VersionVector{1: 1}, VersionVector{2: 2}, VersionVector{1: 1, 2: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.a.Copy()
+			got := tt.a.Merge(tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge = %v, quería %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.a.Copy(), before) {
+				t.Errorf("Merge modificó el vector original: %v", tt.a)
+			}
+			// El resultado domina a ambos vectores
+			if o := got.Compare(tt.a); o == Before || o == Concurrent {
+				t.Errorf("el resultado no domina a %v", tt.a)
+			}
+			if o := got.Compare(tt.b); o == Before || o == Concurrent {
+				t.Errorf("el resultado no domina a %v", tt.b)
+			}
+		})
+	}
+}
+
+// useTempVersions aísla el almacén de versiones en una carpeta temporal
+func useTempVersions(t *testing.T) {
+	t.Helper()
+	oldFile, oldID := versionFile, LocalNodeID
+	versionFile = filepath.Join(t.TempDir(), "versions.json")
+	versions, conflicts, versionsDirty = nil, nil, false
+	LocalNodeID = 1
+	t.Cleanup(func() {
+		versionFile, LocalNodeID = oldFile, oldID
+		versions, conflicts, versionsDirty = nil, nil, false
+	})
+}
+
+func TestObserveVersionDuringReceive(t *testing.T) {
+	useTempVersions(t)
+	t0 := time.Now()
+	ObserveVersion("a.txt", t0)
+
+	// Mientras se escribe el archivo recibido, verlo con otra fecha no es una modificación local
+	done := BeginReceive("a.txt")
+	written := t0.Add(time.Second)
+	ObserveVersion("a.txt", written)
+	remote := VersionVector{1: 1, 2: 1}
+	RecordReceivedVersion("a.txt", remote, written)
+	done()
+
+	if got := ObserveVersion("a.txt", written); got.Compare(remote) != Equal {
+		t.Errorf("versión tras recibir = %v, quería %v", got, remote)
+	}
+	if got := ObserveVersion("a.txt", written.Add(time.Second)); got.Compare(remote) != After {
+		t.Errorf("una edición posterior no incrementó la versión: %v", got)
+	}
+}
+
+func TestSaveVersionsOnlyWhenDirty(t *testing.T) {
+	useTempVersions(t)
+	for i := 0; i < 100; i++ {
+		ObserveVersion(filepath.Join("dir", string(rune('a'+i%26)), "f.txt"), time.Now())
+	}
+	if _, err := os.Stat(versionFile); !os.IsNotExist(err) {
+		t.Fatalf("se escribió el almacén sin esperar al guardado periódico: %v", err)
+	}
+	if err := SaveVersions(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(versionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.Stat(versionFile); !again.ModTime().Equal(info.ModTime()) {
+		t.Error("se reescribió el almacén sin cambios")
+	}
+
+	// Lo guardado se recupera al volver a cargar: una fecha nueva suma sobre lo anterior
+	versions = nil
+	if got := ObserveVersion(filepath.Join("dir", "a", "f.txt"), time.Time{}); got[1] < 2 {
+		t.Errorf("no se recuperó el vector guardado: %v", got)
+	}
+}
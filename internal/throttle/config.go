@@ -0,0 +1,279 @@
+package throttle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var configFile = filepath.Join("config", "bandwidth.json")
+
+// Clases de transferencia a las que se pueden aplicar ventanas horarias
+const (
+	ClassPending = "pending" // Operaciones pendientes reenviadas al reconectarse un peer
+	ClassBulk    = "bulk"    // Carpetas y relays encolados en el planificador
+)
+
+// Limit es un par de límites en KB/s; 0 significa sin límite
+type Limit struct {
+	UploadKBps   int `json:"upload_kbps"`
+	DownloadKBps int `json:"download_kbps"`
+}
+
+// Window habilita una clase de transferencias solo entre From y To (HH:MM, hora
+// local). Si To es anterior a From la ventana cruza la medianoche.
+type Window struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Class string `json:"class"`
+}
+
+// Config es el contenido de config/bandwidth.json
+type Config struct {
+	Limit
+	Peers   map[int]Limit `json:"peers,omitempty"`
+	Windows []Window      `json:"windows,omitempty"`
+}
+
+var (
+	mutex   sync.Mutex
+	current Config
+	loaded  bool
+)
+
+// Load lee config/bandwidth.json y aplica sus límites. Sin archivo no hay límites.
+// Si el archivo es inválido se sigue sin límites y se devuelve el error.
+func Load() (Config, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		setCurrent(Config{})
+		return cfg, err
+	}
+	setCurrent(cfg)
+	return cfg, nil
+}
+
+func readConfig() (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error al decodificar %s: %w", configFile, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", configFile, err)
+	}
+	return cfg, nil
+}
+
+// Current devuelve la configuración en uso
+func Current() Config {
+	mutex.Lock()
+	if !loaded {
+		mutex.Unlock()
+		if _, err := Load(); err != nil {
+			fmt.Println("⚠️ Configuración de ancho de banda inválida:", err)
+		}
+		mutex.Lock()
+	}
+	defer mutex.Unlock()
+	return current.copy()
+}
+
+// Apply valida la configuración, la guarda en config/bandwidth.json y la aplica
+// sin reiniciar las transferencias en curso
+func Apply(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		return fmt.Errorf("no se pudo guardar %s: %w", configFile, err)
+	}
+	setCurrent(cfg)
+	fmt.Println("🚦 Límites de ancho de banda actualizados")
+	return nil
+}
+
+// Validate revisa los valores y el formato de las ventanas
+func (c Config) Validate() error {
+	limits := []Limit{c.Limit}
+	for _, l := range c.Peers {
+		limits = append(limits, l)
+	}
+	for _, l := range limits {
+		if l.UploadKBps < 0 || l.DownloadKBps < 0 {
+			return fmt.Errorf("los límites no pueden ser negativos")
+		}
+	}
+	for _, w := range c.Windows {
+		if _, err := parseClock(w.From); err != nil {
+			return fmt.Errorf("ventana %s-%s: %w", w.From, w.To, err)
+		}
+		if _, err := parseClock(w.To); err != nil {
+			return fmt.Errorf("ventana %s-%s: %w", w.From, w.To, err)
+		}
+		if w.Class != ClassPending && w.Class != ClassBulk {
+			return fmt.Errorf("clase de ventana desconocida: %q", w.Class)
+		}
+	}
+	return nil
+}
+
+// Allowed indica si una clase de transferencias puede correr en el instante t.
+// Una clase sin ventanas configuradas puede correr siempre.
+func Allowed(class string, t time.Time) bool {
+	cfg := Current()
+	restricted := false
+	for _, w := range cfg.Windows {
+		if w.Class != class {
+			continue
+		}
+		restricted = true
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return !restricted
+}
+
+// NextAllowed devuelve el próximo instante, a partir de t, en que la clase puede correr
+func NextAllowed(class string, t time.Time) time.Time {
+	if Allowed(class, t) {
+		return t
+	}
+	best := time.Time{}
+	for _, w := range Current().Windows {
+		if w.Class != class {
+			continue
+		}
+		from, _ := parseClock(w.From)
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(from)
+		if !start.After(t) {
+			start = start.AddDate(0, 0, 1)
+		}
+		if best.IsZero() || start.Before(best) {
+			best = start
+		}
+	}
+	return best
+}
+
+// Contains indica si la hora de t cae dentro de la ventana
+func (w Window) Contains(t time.Time) bool {
+	from, err1 := parseClock(w.From)
+	to, err2 := parseClock(w.To)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// ParseWindows interpreta una lista como "pending=20:00-07:00,bulk=22:00-06:00"
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		class, span, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("ventana inválida %q: se espera clase=HH:MM-HH:MM", part)
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("ventana inválida %q: se espera clase=HH:MM-HH:MM", part)
+		}
+		windows = append(windows, Window{
+			From:  strings.TrimSpace(from),
+			To:    strings.TrimSpace(to),
+			Class: strings.TrimSpace(class),
+		})
+	}
+	return windows, Config{Windows: windows}.Validate()
+}
+
+// FormatWindows es la inversa de ParseWindows
+func FormatWindows(windows []Window) string {
+	parts := make([]string, len(windows))
+	for i, w := range windows {
+		parts[i] = fmt.Sprintf("%s=%s-%s", w.Class, w.From, w.To)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Format describe la configuración en pocas líneas para la CLI
+func (c Config) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Global: subida %s, bajada %s\n", formatRate(c.UploadKBps), formatRate(c.DownloadKBps))
+	ids := make([]int, 0, len(c.Peers))
+	for id := range c.Peers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		l := c.Peers[id]
+		fmt.Fprintf(&b, "Maq%d: subida %s, bajada %s\n", id, formatRate(l.UploadKBps), formatRate(l.DownloadKBps))
+	}
+	if len(c.Windows) == 0 {
+		b.WriteString("Ventanas: ninguna (todo se transfiere en cualquier horario)")
+	} else {
+		b.WriteString("Ventanas: " + FormatWindows(c.Windows))
+	}
+	return b.String()
+}
+
+func formatRate(kbps int) string {
+	if kbps == 0 {
+		return "sin límite"
+	}
+	return strconv.Itoa(kbps) + " KB/s"
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("hora inválida %q (formato HH:MM)", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (c Config) copy() Config {
+	out := c
+	out.Peers = make(map[int]Limit, len(c.Peers))
+	for id, l := range c.Peers {
+		out.Peers[id] = l
+	}
+	out.Windows = append([]Window(nil), c.Windows...)
+	return out
+}
+
+func setCurrent(cfg Config) {
+	mutex.Lock()
+	current = cfg.copy()
+	loaded = true
+	mutex.Unlock()
+	updateLimiters(cfg)
+}
@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useConfig aplica cfg solo en memoria y restaura la configuración anterior al terminar
+func useConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	old := Current()
+	setCurrent(cfg)
+	t.Cleanup(func() { setCurrent(old) })
+}
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 3, 10, hour, minute, 0, 0, time.Local)
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Window
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"pending=20:00-07:00", []Window{{From: "20:00", To: "07:00", Class: ClassPending}}, false},
+		{" bulk = 22:00 - 06:00 ,pending=01:00-02:30\n", []Window{
+			{From: "22:00", To: "06:00", Class: ClassBulk},
+			{From: "01:00", To: "02:30", Class: ClassPending},
+		}, false},
+		{"bulk", nil, true},
+		{"bulk=22:00", nil, true},
+		{"bulk=25:00-06:00", nil, true},
+		{"bulk=22-06", nil, true},
+		{"video=22:00-06:00", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWindows(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWindows(%q) error = %v, quería error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWindows(%q) = %v, quería %v", tt.spec, got, tt.want)
+		}
+		if !tt.wantErr {
+			if again, _ := ParseWindows(FormatWindows(got)); !reflect.DeepEqual(again, got) {
+				t.Errorf("FormatWindows(%v) no se vuelve a leer igual: %v", got, again)
+			}
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	day := Window{From: "09:00", To: "17:30", Class: ClassBulk}
+	night := Window{From: "22:00", To: "06:00", Class: ClassBulk}
+	tests := []struct {
+		name string
+		w    Window
+		t    time.Time
+		want bool
+	}{
+		{"diurna, antes", day, at(8, 59), false},
+		{"diurna, al abrir", day, at(9, 0), true},
+		{"diurna, adentro", day, at(12, 0), true},
+		{"diurna, al cerrar", day, at(17, 30), false},
+		{"nocturna, antes", night, at(21, 59), false},
+		{"nocturna, al abrir", night, at(22, 0), true},
+		{"nocturna, medianoche", night, at(0, 0), true},
+		{"nocturna, madrugada", night, at(5, 59), true},
+		{"nocturna, al cerrar", night, at(6, 0), false},
+		{"nocturna, mediodía", night, at(12, 0), false},
+		{"hora inválida", Window{From: "x", To: "06:00"}, at(1, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: Contains(%s) = %v, quería %v", tt.name, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestAllowedAndNextAllowed(t *testing.T) {
+	useConfig(t, Config{Windows: []Window{
+		{From: "22:00", To: "06:00", Class: ClassBulk},
+		{From: "13:00", To: "14:00", Class: ClassBulk},
+	}})
+
+	tests := []struct {
+		name  string
+		class string
+		t     time.Time
+		want  time.Time
+	}{
+		{"sin ventanas corre siempre", ClassPending, at(12, 0), at(12, 0)},
+		{"dentro de la nocturna", ClassBulk, at(23, 0), at(23, 0)},
+		{"dentro de la nocturna tras medianoche", ClassBulk, at(3, 0), at(3, 0)},
+		{"espera la ventana más próxima", ClassBulk, at(10, 0), at(13, 0)},
+		{"espera la nocturna", ClassBulk, at(15, 0), at(22, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allowed(tt.class, tt.t); got != tt.t.Equal(tt.want) {
+				t.Errorf("Allowed = %v", got)
+			}
+			if got := NextAllowed(tt.class, tt.t); !got.Equal(tt.want) {
+				t.Errorf("NextAllowed = %s, quería %s", got.Format("15:04"), tt.want.Format("15:04"))
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package throttle
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Limiter es un balde de fichas: deja pasar rate bytes por segundo con una
+// ráfaga de hasta un segundo
+type Limiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// SetRate cambia la tasa en bytes por segundo; 0 desactiva el límite
+func (l *Limiter) SetRate(bytesPerSec int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rate = float64(bytesPerSec)
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+}
+
+// Wait bloquea hasta que se puedan transferir n bytes
+func (l *Limiter) Wait(n int) {
+	l.mutex.Lock()
+	if l.rate <= 0 {
+		l.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+	}
+	l.last = now
+	// Se toman las fichas aunque falten; la deuda se paga esperando
+	l.tokens -= float64(n)
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// pair agrupa los límites de subida y bajada de un alcance (global o un peer)
+type pair struct {
+	up, down Limiter
+}
+
+var (
+	limitersMutex sync.Mutex
+	global        pair
+	perPeer       = make(map[int]*pair)
+)
+
+func updateLimiters(cfg Config) {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	global.up.SetRate(int64(cfg.UploadKBps) * 1024)
+	global.down.SetRate(int64(cfg.DownloadKBps) * 1024)
+	for id, p := range perPeer {
+		l := cfg.Peers[id]
+		p.up.SetRate(int64(l.UploadKBps) * 1024)
+		p.down.SetRate(int64(l.DownloadKBps) * 1024)
+	}
+}
+
+func peerLimiters(peerID int, cfg Config) *pair {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	p, ok := perPeer[peerID]
+	if !ok {
+		p = &pair{}
+		l := cfg.Peers[peerID]
+		p.up.SetRate(int64(l.UploadKBps) * 1024)
+		p.down.SetRate(int64(l.DownloadKBps) * 1024)
+		perPeer[peerID] = p
+	}
+	return p
+}
+
+// maxBurst limita cuánto se escribe o lee de una vez, así el límite se reparte
+// entre conexiones y los cambios de configuración se notan enseguida
+const maxBurst = 32 * 1024
+
+// Conn aplica los límites globales y los del peer a una conexión
+type Conn struct {
+	net.Conn
+	peer *pair
+}
+
+// Wrap limita la conexión con un peer. peerID 0 (desconocido) solo usa el límite global.
+func Wrap(conn net.Conn, peerID int) net.Conn {
+	return &Conn{Conn: conn, peer: peerLimiters(peerID, Current())}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := len(p) - written
+		if chunk > maxBurst {
+			chunk = maxBurst
+		}
+		global.up.Wait(chunk)
+		c.peer.up.Wait(chunk)
+		n, err := c.Conn.Write(p[written : written+chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(p) > maxBurst {
+		p = p[:maxBurst]
+	}
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		global.down.Wait(n)
+		c.peer.down.Wait(n)
+	}
+	return n, err
+}
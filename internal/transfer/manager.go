@@ -0,0 +1,580 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State es la etapa en la que está una transferencia
+type State string
+
+const (
+	Queued   State = "queued"  // Esperando un trabajador del planificador
+	Running  State = "running" // Enviando o recibiendo datos
+	Paused   State = "paused"  // Detenida por el usuario; las conexiones quedan abiertas
+	Done     State = "done"
+	Failed   State = "failed"
+	Canceled State = "canceled"
+)
+
+// ErrCanceled es el error de una transferencia cancelada por el usuario
+var ErrCanceled = errors.New("transferencia cancelada")
+
+// Tipos de transferencia
+const (
+	KindSend    = "send"    // De este nodo a un peer
+	KindReceive = "receive" // De un peer a este nodo
+	KindRelay   = "relay"   // Entre dos peers, coordinada por este nodo
+)
+
+// Transfer es la foto de una transferencia que se publica a la GUI, la CLI y la API de control
+type Transfer struct {
+	ID        int64         `json:"id"`
+	Kind      string        `json:"kind"`
+	PeerID    int           `json:"peer"`
+	Path      string        `json:"path"`
+	Total     int64         `json:"total"`
+	Done      int64         `json:"done"`
+	Rate      float64       `json:"rate"` // Bytes por segundo
+	ETA       time.Duration `json:"eta"`
+	State     State         `json:"state"`
+	Error     string        `json:"error,omitempty"`
+	Started   time.Time     `json:"started"`
+	Updated   time.Time     `json:"updated"`
+	Removed   bool          `json:"removed,omitempty"` // Solo en eventos: la transferencia dejó de listarse
+	Retryable bool          `json:"retryable,omitempty"`
+}
+
+// Progress devuelve el avance entre 0 y 1
+func (t Transfer) Progress() float64 {
+	if t.State == Done {
+		return 1
+	}
+	if t.Total <= 0 {
+		return 0
+	}
+	p := float64(t.Done) / float64(t.Total)
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// String resume la transferencia en una línea, ej. "#3 📤 Maq2 docs/a.pdf 45% 1.2 MB/s ETA 8s"
+func (t Transfer) String() string {
+	icon := map[string]string{KindSend: "📤", KindReceive: "📥", KindRelay: "🔁"}[t.Kind]
+	if icon == "" {
+		icon = "⏳"
+	}
+	line := fmt.Sprintf("#%d %s Maq%d %s", t.ID, icon, t.PeerID, t.Path)
+	switch t.State {
+	case Queued:
+		return line + " en cola"
+	case Done:
+		return line + " ✅ " + FormatBytes(t.Total)
+	case Failed:
+		return line + " ❌ " + t.Error
+	case Canceled:
+		return line + " 🚫 cancelada"
+	case Paused:
+		return line + fmt.Sprintf(" ⏸️ en pausa (%.0f%%)", t.Progress()*100)
+	}
+	line += fmt.Sprintf(" %.0f%% (%s de %s)", t.Progress()*100, FormatBytes(t.Done), FormatBytes(t.Total))
+	if t.Rate > 0 {
+		line += fmt.Sprintf(" %s/s", FormatBytes(int64(t.Rate)))
+	}
+	if t.ETA > 0 {
+		line += " ETA " + t.ETA.Round(time.Second).String()
+	}
+	return line
+}
+
+// FormatBytes muestra un tamaño en bytes con la unidad más cómoda
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// Finished indica si la transferencia ya terminó, bien o mal
+func (t Transfer) Finished() bool {
+	return t.State == Done || t.State == Failed || t.State == Canceled
+}
+
+// CanRetry indica si la transferencia terminó mal y sabe cómo volver a intentarse
+func (t Transfer) CanRetry() bool {
+	return t.Retryable && (t.State == Failed || t.State == Canceled)
+}
+
+const (
+	publishInterval = 250 * time.Millisecond // Mínimo entre eventos de avance de una misma transferencia
+	keepFinished    = 100                    // Transferencias terminadas que se conservan en la lista
+)
+
+// Manager sigue las transferencias en curso y avisa los cambios a los suscriptores
+type Manager struct {
+	mutex     sync.Mutex
+	resumed   *sync.Cond // avisa cuando una transferencia sale de la pausa o se cancela
+	nextID    int64
+	transfers map[int64]*Transfer
+	samples   map[int64]sample
+	published map[int64]time.Time
+	retries   map[int64]func() error
+	conns     map[int64]map[net.Conn]bool // conexiones abiertas, para cortarlas al cancelar
+	subs      map[int]chan Transfer
+	nextSub   int
+}
+
+// sample es la última medición usada para calcular la velocidad
+type sample struct {
+	at   time.Time
+	done int64
+}
+
+// Default es el administrador usado por todo el nodo
+var Default = NewManager()
+
+// NewManager crea un administrador vacío
+func NewManager() *Manager {
+	m := &Manager{
+		transfers: make(map[int64]*Transfer),
+		samples:   make(map[int64]sample),
+		published: make(map[int64]time.Time),
+		retries:   make(map[int64]func() error),
+		conns:     make(map[int64]map[net.Conn]bool),
+		subs:      make(map[int]chan Transfer),
+	}
+	m.resumed = sync.NewCond(&m.mutex)
+	return m
+}
+
+// Start registra una transferencia en curso; total puede ser 0 si aún no se conoce
+func Start(kind string, peerID int, path string, total int64) *Handle {
+	return Default.Start(kind, peerID, path, total)
+}
+
+// Enqueue registra un trabajo que espera en el planificador
+func Enqueue(peerID int, path string) *Handle {
+	return Default.add(Transfer{PeerID: peerID, Path: path, State: Queued})
+}
+
+// List devuelve las transferencias conocidas, las más recientes primero
+func List() []Transfer {
+	return Default.List()
+}
+
+// Subscribe devuelve un canal con cada cambio y una función para dejar de escuchar
+func Subscribe() (<-chan Transfer, func()) {
+	return Default.Subscribe()
+}
+
+// Pause, Resume, Cancel, Retry y ClearFinished actúan sobre el administrador por defecto
+func Pause(id int64) error  { return Default.Pause(id) }
+func Resume(id int64) error { return Default.Resume(id) }
+func Cancel(id int64) error { return Default.Cancel(id) }
+func Retry(id int64) error  { return Default.Retry(id) }
+func ClearFinished() int    { return Default.ClearFinished() }
+
+// Start registra una transferencia en curso
+func (m *Manager) Start(kind string, peerID int, path string, total int64) *Handle {
+	return m.add(Transfer{Kind: kind, PeerID: peerID, Path: path, Total: total, State: Running})
+}
+
+func (m *Manager) add(t Transfer) *Handle {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.nextID++
+	t.ID = m.nextID
+	t.Started = time.Now()
+	t.Updated = t.Started
+	m.transfers[t.ID] = &t
+	m.samples[t.ID] = sample{at: t.Started}
+	m.publishLocked(t.ID, true)
+	m.pruneLocked()
+	return &Handle{m: m, id: t.ID}
+}
+
+// List devuelve las transferencias conocidas, las más recientes primero
+func (m *Manager) List() []Transfer {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := make([]Transfer, 0, len(m.transfers))
+	for _, t := range m.transfers {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list
+}
+
+// Subscribe devuelve un canal con cada cambio y una función para dejar de escuchar.
+// Si el suscriptor no lee a tiempo se descartan eventos de avance intermedios.
+func (m *Manager) Subscribe() (<-chan Transfer, func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id := m.nextSub
+	m.nextSub++
+	ch := make(chan Transfer, 64)
+	m.subs[id] = ch
+	return ch, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if _, ok := m.subs[id]; ok {
+			delete(m.subs, id)
+			close(ch)
+		}
+	}
+}
+
+// publishLocked avisa a los suscriptores; se asume que mutex está tomado
+func (m *Manager) publishLocked(id int64, force bool) {
+	t, ok := m.transfers[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(m.published[id]) < publishInterval {
+		return
+	}
+	m.published[id] = now
+	m.sendLocked(*t)
+}
+
+func (m *Manager) sendLocked(t Transfer) {
+	for _, ch := range m.subs {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+}
+
+// removeLocked quita una transferencia de la lista; se asume que mutex está tomado
+func (m *Manager) removeLocked(id int64) {
+	t, ok := m.transfers[id]
+	if !ok {
+		return
+	}
+	delete(m.transfers, id)
+	delete(m.samples, id)
+	delete(m.published, id)
+	delete(m.retries, id)
+	delete(m.conns, id)
+	gone := *t
+	gone.Removed = true
+	m.sendLocked(gone)
+}
+
+// pruneLocked descarta las transferencias terminadas más viejas
+func (m *Manager) pruneLocked() {
+	var finished []int64
+	for id, t := range m.transfers {
+		if t.Finished() {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= keepFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i] < finished[j] })
+	for _, id := range finished[:len(finished)-keepFinished] {
+		m.removeLocked(id)
+	}
+}
+
+// Pause detiene una transferencia en curso: sus lecturas y escrituras esperan
+// hasta Resume. Si la pausa es larga el otro nodo puede cortar por tiempo.
+func (m *Manager) Pause(id int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t, ok := m.transfers[id]
+	if !ok {
+		return fmt.Errorf("transferencia #%d no encontrada", id)
+	}
+	if t.State != Running {
+		return fmt.Errorf("la transferencia #%d no está en curso", id)
+	}
+	t.State = Paused
+	t.Rate = 0
+	t.ETA = 0
+	m.publishLocked(id, true)
+	return nil
+}
+
+// Resume reanuda una transferencia en pausa
+func (m *Manager) Resume(id int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t, ok := m.transfers[id]
+	if !ok {
+		return fmt.Errorf("transferencia #%d no encontrada", id)
+	}
+	if t.State != Paused {
+		return fmt.Errorf("la transferencia #%d no está en pausa", id)
+	}
+	t.State = Running
+	m.samples[id] = sample{at: time.Now(), done: t.Done}
+	m.resumed.Broadcast()
+	m.publishLocked(id, true)
+	return nil
+}
+
+// Cancel corta una transferencia en curso, en pausa o en cola
+func (m *Manager) Cancel(id int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t, ok := m.transfers[id]
+	if !ok {
+		return fmt.Errorf("transferencia #%d no encontrada", id)
+	}
+	if t.Finished() {
+		return fmt.Errorf("la transferencia #%d ya terminó", id)
+	}
+	t.State = Canceled
+	t.Error = ErrCanceled.Error()
+	t.ETA = 0
+	for conn := range m.conns[id] {
+		_ = conn.Close()
+	}
+	m.resumed.Broadcast()
+	m.publishLocked(id, true)
+	return nil
+}
+
+// Retry vuelve a lanzar una transferencia fallida o cancelada. La nueva ejecución
+// se registra como otra transferencia y la anterior se quita de la lista.
+func (m *Manager) Retry(id int64) error {
+	m.mutex.Lock()
+	t, ok := m.transfers[id]
+	retry := m.retries[id]
+	if !ok || !t.CanRetry() || retry == nil {
+		m.mutex.Unlock()
+		return fmt.Errorf("la transferencia #%d no se puede reintentar", id)
+	}
+	path := t.Path
+	m.removeLocked(id)
+	m.mutex.Unlock()
+
+	go func() {
+		if err := retry(); err != nil {
+			fmt.Printf("⚠️ Reintento de %s fallido: %v\n", path, err)
+		}
+	}()
+	return nil
+}
+
+// ClearFinished quita de la lista las transferencias terminadas y devuelve cuántas eran
+func (m *Manager) ClearFinished() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	n := 0
+	for id, t := range m.transfers {
+		if t.Finished() {
+			m.removeLocked(id)
+			n++
+		}
+	}
+	return n
+}
+
+// Handle permite actualizar una transferencia. Todos sus métodos aceptan un
+// Handle nil, así las funciones que transfieren no necesitan saber si alguien las sigue.
+type Handle struct {
+	m  *Manager
+	id int64
+}
+
+// ID devuelve el identificador de la transferencia
+func (h *Handle) ID() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.id
+}
+
+// SetTotal fija el tamaño esperado en bytes
+func (h *Handle) SetTotal(total int64) {
+	h.update(func(t *Transfer) { t.Total = total }, true)
+}
+
+// Add suma bytes transferidos y recalcula la velocidad y el tiempo restante
+func (h *Handle) Add(n int64) {
+	if h == nil || n <= 0 {
+		return
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	t, ok := h.m.transfers[h.id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	t.Done += n
+	if t.Total > 0 && t.Done > t.Total {
+		// El conteo desde la red es aproximado; el total no puede quedar por debajo
+		t.Total = t.Done
+	}
+	t.Updated = now
+	s := h.m.samples[h.id]
+	if elapsed := now.Sub(s.at); elapsed >= time.Second {
+		rate := float64(t.Done-s.done) / elapsed.Seconds()
+		if t.Rate == 0 {
+			t.Rate = rate
+		} else {
+			t.Rate = 0.7*t.Rate + 0.3*rate
+		}
+		h.m.samples[h.id] = sample{at: now, done: t.Done}
+	}
+	if t.Rate > 0 && t.Total > t.Done {
+		t.ETA = time.Duration(float64(t.Total-t.Done) / t.Rate * float64(time.Second))
+	}
+	h.m.publishLocked(h.id, false)
+}
+
+// SetRetry indica cómo repetir la transferencia si falla o se cancela
+func (h *Handle) SetRetry(retry func() error) {
+	if h == nil {
+		return
+	}
+	h.m.mutex.Lock()
+	h.m.retries[h.id] = retry
+	h.m.mutex.Unlock()
+	h.update(func(t *Transfer) { t.Retryable = true }, false)
+}
+
+// Canceled indica si el usuario canceló la transferencia
+func (h *Handle) Canceled() bool {
+	if h == nil {
+		return false
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	t, ok := h.m.transfers[h.id]
+	return ok && t.State == Canceled
+}
+
+// wait bloquea mientras la transferencia esté en pausa y devuelve ErrCanceled si se canceló
+func (h *Handle) wait() error {
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	for {
+		t, ok := h.m.transfers[h.id]
+		if !ok {
+			return nil
+		}
+		switch t.State {
+		case Paused:
+			h.m.resumed.Wait()
+		case Canceled:
+			return ErrCanceled
+		default:
+			return nil
+		}
+	}
+}
+
+// Finish marca la transferencia como terminada; err nil significa éxito. Una
+// transferencia cancelada conserva ese estado aunque termine con otro error.
+func (h *Handle) Finish(err error) {
+	h.update(func(t *Transfer) {
+		t.ETA = 0
+		if t.State == Canceled {
+			return
+		}
+		if err != nil {
+			t.State = Failed
+			t.Error = err.Error()
+			return
+		}
+		t.State = Done
+		if t.Total > 0 {
+			t.Done = t.Total
+		}
+	}, true)
+}
+
+// Discard quita la transferencia de la lista, por ejemplo cuando un trabajo en
+// cola arranca y pasa a registrarse como transferencia propia
+func (h *Handle) Discard() {
+	if h == nil {
+		return
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	h.m.removeLocked(h.id)
+}
+
+func (h *Handle) update(change func(t *Transfer), force bool) {
+	if h == nil {
+		return
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	t, ok := h.m.transfers[h.id]
+	if !ok {
+		return
+	}
+	change(t)
+	t.Updated = time.Now()
+	h.m.publishLocked(h.id, force)
+}
+
+// Conn cuenta en la transferencia los bytes leídos y escritos en conn
+func (h *Handle) Conn(conn net.Conn) net.Conn {
+	if h == nil {
+		return conn
+	}
+	h.m.mutex.Lock()
+	defer h.m.mutex.Unlock()
+	if h.m.conns[h.id] == nil {
+		h.m.conns[h.id] = make(map[net.Conn]bool)
+	}
+	h.m.conns[h.id][conn] = true
+	return &trackedConn{Conn: conn, h: h}
+}
+
+type trackedConn struct {
+	net.Conn
+	h *Handle
+}
+
+// El contenido viaja en base64 dentro de JSON: 4 bytes en la red son unos 3 del
+// archivo, así el avance se compara con el tamaño real
+func wireToRaw(n int) int64 {
+	return int64(n) * 3 / 4
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	if err := c.h.wait(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(p)
+	c.h.Add(wireToRaw(n))
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	if err := c.h.wait(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	c.h.Add(wireToRaw(n))
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.h.m.mutex.Lock()
+	delete(c.h.m.conns[c.h.id], c.Conn)
+	c.h.m.mutex.Unlock()
+	return c.Conn.Close()
+}